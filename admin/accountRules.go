@@ -0,0 +1,252 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const DEFAULT_BATCH_SIZE = 50
+
+// AccountRulesAdmin manages the allowed accounts and targets of the account permissioning contract
+type AccountRulesAdmin struct {
+	*transactor
+	Address   common.Address
+	BatchSize int
+	contract  *relay.Account
+	abi       abi.ABI
+}
+
+// NewAccountRulesAdmin creates an administrator for the AccountRules contract deployed at address signing with key
+func NewAccountRulesAdmin(address common.Address, backend Backend, key *ecdsa.PrivateKey, gasLimit uint64, out io.Writer) (*AccountRulesAdmin, error) {
+	contract, err := relay.NewAccount(address, backend)
+	if err != nil {
+		msg := fmt.Sprintf("can't instance AccountRules contract %s", address.Hex())
+		return nil, errors.FailedContract.Wrapf(err, msg, -32603)
+	}
+
+	accountRulesAbi, err := abi.JSON(strings.NewReader(relay.AccountABI))
+	if err != nil {
+		return nil, errors.FailedContract.Wrapf(err, "Error decoding ABI", -32603)
+	}
+
+	return &AccountRulesAdmin{
+		transactor: newTransactor(backend, key, gasLimit, out),
+		Address:    address,
+		BatchSize:  DEFAULT_BATCH_SIZE,
+		contract:   contract,
+		abi:        accountRulesAbi,
+	}, nil
+}
+
+// GetAccounts returns the accounts allowed to send transactions
+func (admin *AccountRulesAdmin) GetAccounts() ([]common.Address, error) {
+	accounts, err := admin.contract.GetAccounts(&bind.CallOpts{From: admin.options.From})
+	if err != nil {
+		msg := fmt.Sprintf("failed get accounts from %s", admin.Address.Hex())
+		return nil, errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+	}
+	return accounts, nil
+}
+
+// GetTargets returns the destinations allowed to receive transactions
+func (admin *AccountRulesAdmin) GetTargets() ([]common.Address, error) {
+	targets, err := admin.contract.GetTargets(&bind.CallOpts{From: admin.options.From})
+	if err != nil {
+		msg := fmt.Sprintf("failed get targets from %s", admin.Address.Hex())
+		return nil, errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+	}
+	return targets, nil
+}
+
+// IsReadOnly tells whether the contract rejects changes to its lists
+func (admin *AccountRulesAdmin) IsReadOnly() (bool, error) {
+	readOnly, err := admin.contract.IsReadOnly(&bind.CallOpts{From: admin.options.From})
+	if err != nil {
+		msg := fmt.Sprintf("failed get read only mode from %s", admin.Address.Hex())
+		return false, errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+	}
+	return readOnly, nil
+}
+
+// AddAccount allows account to send transactions
+func (admin *AccountRulesAdmin) AddAccount(account common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "addAccount", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.AddAccount(opts, account)
+	}, account)
+}
+
+// AddAccounts allows several accounts to send transactions
+func (admin *AccountRulesAdmin) AddAccounts(accounts []common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "addAccounts", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.AddAccounts(opts, accounts)
+	}, accounts)
+}
+
+// RemoveAccount forbids account to send transactions
+func (admin *AccountRulesAdmin) RemoveAccount(account common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "removeAccount", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.RemoveAccount(opts, account)
+	}, account)
+}
+
+// AddTarget allows target to receive transactions
+func (admin *AccountRulesAdmin) AddTarget(target common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "addTarget", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.AddTarget(opts, target)
+	}, target)
+}
+
+// AddTargets allows several targets to receive transactions
+func (admin *AccountRulesAdmin) AddTargets(targets []common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "addTargets", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.AddTargets(opts, targets)
+	}, targets)
+}
+
+// RemoveTarget forbids target to receive transactions
+func (admin *AccountRulesAdmin) RemoveTarget(target common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "removeTarget", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.RemoveTarget(opts, target)
+	}, target)
+}
+
+// EnterReadOnly freezes the allowed accounts and targets
+func (admin *AccountRulesAdmin) EnterReadOnly() (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "enterReadOnly", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.EnterReadOnly(opts)
+	})
+}
+
+// ExitReadOnly allows changes to the allowed accounts and targets again
+func (admin *AccountRulesAdmin) ExitReadOnly() (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "exitReadOnly", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.ExitReadOnly(opts)
+	})
+}
+
+// ImportAccounts adds in batches the accounts not yet allowed on chain
+func (admin *AccountRulesAdmin) ImportAccounts(accounts []common.Address) error {
+	onChain, err := admin.GetAccounts()
+	if err != nil {
+		return err
+	}
+	missing, _ := DiffAddresses(accounts, onChain)
+	return admin.inBatches(missing, admin.AddAccounts)
+}
+
+// ImportTargets adds in batches the targets not yet allowed on chain
+func (admin *AccountRulesAdmin) ImportTargets(targets []common.Address) error {
+	onChain, err := admin.GetTargets()
+	if err != nil {
+		return err
+	}
+	missing, _ := DiffAddresses(targets, onChain)
+	return admin.inBatches(missing, admin.AddTargets)
+}
+
+func (admin *AccountRulesAdmin) inBatches(addresses []common.Address, add func([]common.Address) (*types.Receipt, error)) error {
+	if len(addresses) == 0 {
+		fmt.Fprintln(admin.Out, "nothing to import, all addresses are already on chain")
+		return nil
+	}
+
+	batchSize := admin.BatchSize
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+
+	for start := 0; start < len(addresses); start += batchSize {
+		end := start + batchSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		if _, err := add(addresses[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffAddresses returns the addresses only present in local and the addresses only present in onChain
+func DiffAddresses(local, onChain []common.Address) ([]common.Address, []common.Address) {
+	inLocal := make(map[common.Address]bool)
+	for _, address := range local {
+		inLocal[address] = true
+	}
+	inChain := make(map[common.Address]bool)
+	for _, address := range onChain {
+		inChain[address] = true
+	}
+
+	var missing, extra []common.Address
+	for _, address := range local {
+		if !inChain[address] {
+			missing = append(missing, address)
+			inChain[address] = true
+		}
+	}
+	for _, address := range onChain {
+		if !inLocal[address] {
+			extra = append(extra, address)
+			inLocal[address] = true
+		}
+	}
+	return missing, extra
+}
+
+// CSV_HEADERS are the names of the first column accepted as a header in the first row
+var CSV_HEADERS = []string{"address", "account", "target"}
+
+// ReadAddressesCSV reads the addresses in the first column of a CSV, skipping a header and blank lines. Every invalid
+// row is reported.
+func ReadAddressesCSV(reader io.Reader) ([]common.Address, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.Comment = '#'
+
+	var addresses []common.Address
+	var invalid []string
+	for first := true; ; first = false {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.FailedReadFile.Wrapf(err, "can't read CSV file", -32603)
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		value := strings.TrimSpace(record[0])
+		if value == "" || (first && isCSVHeader(value)) {
+			continue
+		}
+		if !common.IsHexAddress(value) {
+			invalid = append(invalid, fmt.Sprintf("%s at line %d", value, line))
+			continue
+		}
+		addresses = append(addresses, common.HexToAddress(value))
+	}
+	if len(invalid) > 0 {
+		return nil, errors.InvalidAddress.New("invalid address "+strings.Join(invalid, ", "), -32608)
+	}
+	return addresses, nil
+}
+
+func isCSVHeader(value string) bool {
+	for _, header := range CSV_HEADERS {
+		if strings.EqualFold(value, header) {
+			return true
+		}
+	}
+	return false
+}
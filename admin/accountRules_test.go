@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var accountRulesAddress = common.HexToAddress("0x4683519EF834572017Cb583246B717449A4B752c")
+
+func TestReadAddressesCSV(t *testing.T) {
+	content := `address,name
+0xd00e6624a73f88b39f82ab34e8bf2b4d226fd768,node
+
+# comment
+0x173cf75f0905338597fcd38f5ce13e6840b230e9,user
+`
+	addresses, err := ReadAddressesCSV(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 || addresses[1] != common.HexToAddress("0x173cf75f0905338597fcd38f5ce13e6840b230e9") {
+		t.Errorf("Addresses should be read from the first column skipping the header, got %v", addresses)
+	}
+
+	addresses, err = ReadAddressesCSV(strings.NewReader("0xd00e6624a73f88b39f82ab34e8bf2b4d226fd768\n0x173cf75f0905338597fcd38f5ce13e6840b230e9\n"))
+	if err != nil || len(addresses) != 2 {
+		t.Errorf("The first address of a CSV without header should be read, got %v %v", addresses, err)
+	}
+
+	_, err = ReadAddressesCSV(strings.NewReader("0xd00e6624a73f88b39f82ab34e8bf2b4d2\n0x173cf75f0905338597fcd38f5ce13e6840b230e9\n\nnot-an-address\n"))
+	if err == nil || !strings.Contains(err.Error(), "at line 1") || !strings.Contains(err.Error(), "not-an-address at line 4") {
+		t.Errorf("Every invalid address should be reported with its line, got %v", err)
+	}
+}
+
+func TestDiffAddresses(t *testing.T) {
+	a := common.HexToAddress("0x01")
+	b := common.HexToAddress("0x02")
+	c := common.HexToAddress("0x03")
+
+	missing, extra := DiffAddresses([]common.Address{a, b, b}, []common.Address{b, c})
+	if len(missing) != 1 || missing[0] != a {
+		t.Errorf("Only %s should be missing on chain, got %v", a.Hex(), missing)
+	}
+	if len(extra) != 1 || extra[0] != c {
+		t.Errorf("Only %s should be extra on chain, got %v", c.Hex(), extra)
+	}
+}
+
+func TestImportInBatches(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0")
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000000000000000)}}
+	backend := backends.NewSimulatedBackend(alloc, 10000000)
+	defer backend.Close()
+
+	out := new(bytes.Buffer)
+	admin, err := NewAccountRulesAdmin(accountRulesAddress, backend, key, 0, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.DryRun = true
+	admin.BatchSize = 2
+
+	var addresses []common.Address
+	for i := 1; i <= 5; i++ {
+		addresses = append(addresses, common.BigToAddress(big.NewInt(int64(i))))
+	}
+
+	err = admin.inBatches(addresses, admin.AddAccounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "method: addAccounts") != 3 {
+		t.Errorf("5 addresses should be imported in 3 batches, got %s", out.String())
+	}
+}
@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	bl "github.com/LACNetNetworks/gas-relay-signer/blockchain"
+	"github.com/LACNetNetworks/gas-relay-signer/cli"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const HELP = `commands:
+  add-node <address>
+  delete-node <address>
+  set-max-gas-block-limit <gas>
+  set-blocks-frequency <blocks>
+  set-gas-used-relayhub <gas>
+  set-account-ingress <address>
+  grant-role <role> <address>
+  revoke-role <role> <address>
+  renounce-role <role> <address>
+  has-role <role> <address>
+
+role is "admin" for DEFAULT_ADMIN_ROLE or a 32 bytes hex value
+`
+
+const ACCOUNTS_HELP = `commands:
+  list-accounts
+  list-targets
+  add-account <address>
+  remove-account <address>
+  add-target <address>
+  remove-target <address>
+  import-accounts <file.csv>
+  import-targets <file.csv>
+  diff-accounts <file.csv>
+  diff-targets <file.csv>
+  read-only [on|off]
+
+CSV files hold one address per line in the first column, after an optional
+header named address, account or target. "+" marks addresses missing on chain
+and "-" addresses only present on chain
+`
+
+// Command executes a RelayHub administration command signed with the writer key of the configuration returned by loadConfig
+func Command(loadConfig func() *model.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "admin",
+		Arguments: "<command> [arguments]",
+		Summary:   "RelayHub administration",
+		Help:      HELP,
+		MinArgs:   1,
+		MaxArgs:   -1,
+		Setup: func(flags *flag.FlagSet) cli.Runner {
+			dryRun := flags.Bool("dry-run", false, "print the encoded calldata without sending the transaction")
+			gasLimit := flags.Uint64("gas", DEFAULT_GAS_LIMIT, "gas limit of the transaction")
+			timeout := flags.Duration("timeout", DEFAULT_RECEIPT_TIMEOUT, "time to wait for the transaction receipt")
+			return func(args []string, out io.Writer) error {
+				config := loadConfig()
+				privateKey, client, err := connect(config)
+				if err != nil {
+					return err
+				}
+				defer client.Close()
+
+				admin, err := NewRelayHubAdmin(*config.Application.RelayHubContractAddress, client.GetEthclient(), privateKey, *gasLimit, out)
+				if err != nil {
+					return err
+				}
+				admin.DryRun = *dryRun
+				admin.Timeout = *timeout
+
+				return admin.Exec(args[0], args[1:])
+			}
+		},
+	}
+}
+
+// Exec runs a single administration command with its positional arguments
+func (admin *RelayHubAdmin) Exec(command string, args []string) error {
+	switch command {
+	case "add-node":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.AddNode(address)
+		return err
+	case "delete-node":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.DeleteNode(address)
+		return err
+	case "set-account-ingress":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.SetAccountIngress(address)
+		return err
+	case "set-max-gas-block-limit":
+		gas, err := gasArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.SetMaxGasBlockLimit(gas)
+		return err
+	case "set-gas-used-relayhub":
+		gas, err := gasArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.SetGasUsedRelayHub(gas)
+		return err
+	case "set-blocks-frequency":
+		if err := expectArgs(command, args, 1); err != nil {
+			return err
+		}
+		blocks, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid blocks frequency %s", args[0])
+		}
+		_, err = admin.SetBlocksFrequency(uint8(blocks))
+		return err
+	case "grant-role":
+		role, address, err := roleArguments(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.GrantRole(role, address)
+		return err
+	case "revoke-role":
+		role, address, err := roleArguments(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.RevokeRole(role, address)
+		return err
+	case "renounce-role":
+		role, address, err := roleArguments(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.RenounceRole(role, address)
+		return err
+	case "has-role":
+		role, address, err := roleArguments(command, args)
+		if err != nil {
+			return err
+		}
+		hasRole, err := admin.HasRole(role, address)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(admin.Out, hasRole)
+		return nil
+	}
+
+	return fmt.Errorf("unknown admin command %s", command)
+}
+
+func addressArgument(command string, args []string) (common.Address, error) {
+	if err := expectArgs(command, args, 1); err != nil {
+		return common.Address{}, err
+	}
+	return parseAddress(args[0])
+}
+
+func gasArgument(command string, args []string) (*big.Int, error) {
+	if err := expectArgs(command, args, 1); err != nil {
+		return nil, err
+	}
+	gas, ok := new(big.Int).SetString(args[0], 10)
+	if !ok || gas.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gas value %s", args[0])
+	}
+	return gas, nil
+}
+
+func roleArguments(command string, args []string) ([32]byte, common.Address, error) {
+	if err := expectArgs(command, args, 2); err != nil {
+		return [32]byte{}, common.Address{}, err
+	}
+	role, err := parseRole(args[0])
+	if err != nil {
+		return role, common.Address{}, err
+	}
+	address, err := parseAddress(args[1])
+	return role, address, err
+}
+
+// AccountsCommand executes an account permissioning command signed with the writer key of the configuration returned by loadConfig
+func AccountsCommand(loadConfig func() *model.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "accounts",
+		Arguments: "<command> [arguments]",
+		Summary:   "account permissioning administration",
+		Help:      ACCOUNTS_HELP,
+		MinArgs:   1,
+		MaxArgs:   -1,
+		Setup: func(flags *flag.FlagSet) cli.Runner {
+			dryRun := flags.Bool("dry-run", false, "print the encoded calldata without sending the transaction")
+			gasLimit := flags.Uint64("gas", DEFAULT_GAS_LIMIT, "gas limit of the transaction")
+			timeout := flags.Duration("timeout", DEFAULT_RECEIPT_TIMEOUT, "time to wait for the transaction receipt")
+			batchSize := flags.Int("batch", DEFAULT_BATCH_SIZE, "addresses added per transaction on imports")
+			return func(args []string, out io.Writer) error {
+				config := loadConfig()
+				if !common.IsHexAddress(config.Security.AccountContractAddress) {
+					return fmt.Errorf("invalid account smart contract address %s", config.Security.AccountContractAddress)
+				}
+
+				privateKey, client, err := connect(config)
+				if err != nil {
+					return err
+				}
+				defer client.Close()
+
+				admin, err := NewAccountRulesAdmin(common.HexToAddress(config.Security.AccountContractAddress), client.GetEthclient(), privateKey, *gasLimit, out)
+				if err != nil {
+					return err
+				}
+				admin.DryRun = *dryRun
+				admin.Timeout = *timeout
+				admin.BatchSize = *batchSize
+
+				return admin.Exec(args[0], args[1:])
+			}
+		},
+	}
+}
+
+// Exec runs a single account permissioning command with its positional arguments
+func (admin *AccountRulesAdmin) Exec(command string, args []string) error {
+	switch command {
+	case "list-accounts":
+		accounts, err := admin.GetAccounts()
+		if err != nil {
+			return err
+		}
+		printAddresses(admin.Out, "", accounts)
+		return nil
+	case "list-targets":
+		targets, err := admin.GetTargets()
+		if err != nil {
+			return err
+		}
+		printAddresses(admin.Out, "", targets)
+		return nil
+	case "add-account":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.AddAccount(address)
+		return err
+	case "remove-account":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.RemoveAccount(address)
+		return err
+	case "add-target":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.AddTarget(address)
+		return err
+	case "remove-target":
+		address, err := addressArgument(command, args)
+		if err != nil {
+			return err
+		}
+		_, err = admin.RemoveTarget(address)
+		return err
+	case "import-accounts":
+		addresses, err := csvArgument(command, args)
+		if err != nil {
+			return err
+		}
+		return admin.ImportAccounts(addresses)
+	case "import-targets":
+		addresses, err := csvArgument(command, args)
+		if err != nil {
+			return err
+		}
+		return admin.ImportTargets(addresses)
+	case "diff-accounts":
+		addresses, err := csvArgument(command, args)
+		if err != nil {
+			return err
+		}
+		onChain, err := admin.GetAccounts()
+		if err != nil {
+			return err
+		}
+		printDiff(admin.Out, addresses, onChain)
+		return nil
+	case "diff-targets":
+		addresses, err := csvArgument(command, args)
+		if err != nil {
+			return err
+		}
+		onChain, err := admin.GetTargets()
+		if err != nil {
+			return err
+		}
+		printDiff(admin.Out, addresses, onChain)
+		return nil
+	case "read-only":
+		if len(args) == 0 {
+			readOnly, err := admin.IsReadOnly()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(admin.Out, readOnly)
+			return nil
+		}
+		if err := expectArgs(command, args, 1); err != nil {
+			return err
+		}
+		switch strings.ToLower(args[0]) {
+		case "on":
+			_, err := admin.EnterReadOnly()
+			return err
+		case "off":
+			_, err := admin.ExitReadOnly()
+			return err
+		}
+		return fmt.Errorf("read-only expects on or off, got %s", args[0])
+	}
+
+	return fmt.Errorf("unknown accounts command %s", command)
+}
+
+func connect(config *model.Config) (*ecdsa.PrivateKey, *bl.Client, error) {
+	relaySignerService := new(service.RelaySignerService)
+	if err := relaySignerService.Init(config); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := crypto.HexToECDSA(config.Application.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := new(bl.Client)
+	if err := client.Connect(config.Application.NodeURL); err != nil {
+		return nil, nil, err
+	}
+	return privateKey, client, nil
+}
+
+func csvArgument(command string, args []string) ([]common.Address, error) {
+	if err := expectArgs(command, args, 1); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadAddressesCSV(file)
+}
+
+func printAddresses(out io.Writer, prefix string, addresses []common.Address) {
+	for _, address := range addresses {
+		fmt.Fprintf(out, "%s%s\n", prefix, address.Hex())
+	}
+}
+
+func printDiff(out io.Writer, local, onChain []common.Address) {
+	missing, extra := DiffAddresses(local, onChain)
+	printAddresses(out, "+ ", missing)
+	printAddresses(out, "- ", extra)
+	fmt.Fprintf(out, "%d to add, %d only on chain\n", len(missing), len(extra))
+}
+
+func expectArgs(command string, args []string, count int) error {
+	if len(args) != count {
+		return fmt.Errorf("%s expects %d argument(s), got %d", command, count, len(args))
+	}
+	return nil
+}
+
+func parseAddress(value string) (common.Address, error) {
+	if !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("invalid address %s", value)
+	}
+	return common.HexToAddress(value), nil
+}
+
+func parseRole(value string) ([32]byte, error) {
+	var role [32]byte
+	if strings.ToLower(value) == "admin" {
+		return role, nil
+	}
+
+	roleBytes, err := hexutil.Decode(value)
+	if err != nil || len(roleBytes) != 32 {
+		return role, fmt.Errorf("invalid role %s", value)
+	}
+	copy(role[:], roleBytes)
+	return role, nil
+}
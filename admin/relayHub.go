@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// RelayHubAdmin sends administrative transactions to RelayHub through the generated bindings
+type RelayHubAdmin struct {
+	*transactor
+	Address  common.Address
+	contract *relay.Relay
+	abi      abi.ABI
+}
+
+// NewRelayHubAdmin creates an administrator for the RelayHub deployed at address signing with key
+func NewRelayHubAdmin(address common.Address, backend Backend, key *ecdsa.PrivateKey, gasLimit uint64, out io.Writer) (*RelayHubAdmin, error) {
+	contract, err := relay.NewRelay(address, backend)
+	if err != nil {
+		msg := fmt.Sprintf("can't instance RelayHub contract %s", address.Hex())
+		return nil, errors.FailedContract.Wrapf(err, msg, -32603)
+	}
+
+	relayHubAbi, err := abi.JSON(strings.NewReader(relay.RelayABI))
+	if err != nil {
+		return nil, errors.FailedContract.Wrapf(err, "Error decoding ABI", -32603)
+	}
+
+	return &RelayHubAdmin{
+		transactor: newTransactor(backend, key, gasLimit, out),
+		Address:    address,
+		contract:   contract,
+		abi:        relayHubAbi,
+	}, nil
+}
+
+// AddNode registers a writer node in RelayHub
+func (admin *RelayHubAdmin) AddNode(node common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "addNode", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.AddNode(opts, node)
+	}, node)
+}
+
+// DeleteNode removes a writer node from RelayHub
+func (admin *RelayHubAdmin) DeleteNode(node common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "deleteNode", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.DeleteNode(opts, node)
+	}, node)
+}
+
+// SetMaxGasBlockLimit changes the gas available per block for all writer nodes
+func (admin *RelayHubAdmin) SetMaxGasBlockLimit(gasLimit *big.Int) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "setMaxGasBlockLimit", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.SetMaxGasBlockLimit(opts, gasLimit)
+	}, gasLimit)
+}
+
+// SetBlocksFrequency changes how many blocks are used to recalculate the gas limit
+func (admin *RelayHubAdmin) SetBlocksFrequency(blocksFrequency uint8) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "setBlocksFrequency", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.SetBlocksFrequency(opts, blocksFrequency)
+	}, blocksFrequency)
+}
+
+// SetGasUsedRelayHub changes the gas RelayHub reserves for its own execution
+func (admin *RelayHubAdmin) SetGasUsedRelayHub(gasUsed *big.Int) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "setGasUsedRelayHub", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.SetGasUsedRelayHub(opts, gasUsed)
+	}, gasUsed)
+}
+
+// SetAccountIngress changes the account permissioning contract used by RelayHub
+func (admin *RelayHubAdmin) SetAccountIngress(accountIngress common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "setAccounIngress", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.SetAccounIngress(opts, accountIngress)
+	}, accountIngress)
+}
+
+// GrantRole gives role to account
+func (admin *RelayHubAdmin) GrantRole(role [32]byte, account common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "grantRole", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.GrantRole(opts, role, account)
+	}, role, account)
+}
+
+// RevokeRole takes role away from account
+func (admin *RelayHubAdmin) RevokeRole(role [32]byte, account common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "revokeRole", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.RevokeRole(opts, role, account)
+	}, role, account)
+}
+
+// RenounceRole gives up role for the signer account
+func (admin *RelayHubAdmin) RenounceRole(role [32]byte, account common.Address) (*types.Receipt, error) {
+	return admin.execute(admin.abi, admin.Address, "renounceRole", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return admin.contract.RenounceRole(opts, role, account)
+	}, role, account)
+}
+
+// HasRole tells whether account has role
+func (admin *RelayHubAdmin) HasRole(role [32]byte, account common.Address) (bool, error) {
+	hasRole, err := admin.contract.HasRole(&bind.CallOpts{From: admin.options.From}, role, account)
+	if err != nil {
+		msg := fmt.Sprintf("failed to know if %s has role from %s", account.Hex(), admin.Address.Hex())
+		return false, errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+	}
+	return hasRole, nil
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var nodeAddress = common.HexToAddress("0xd00e6624a73f88b39f82ab34e8bf2b4d226fd768")
+var accountIngress = common.HexToAddress("0x0000000000000000000000000000000000008888")
+
+// newSimulatedAdmin deploys testdata/GasLimit.bin, relayhub/contracts/GasLimit.sol compiled with solc 0.8.21 for istanbul
+// (optimizer 200 runs). It holds the administrative functions RelayHub inherits.
+func newSimulatedAdmin(t *testing.T, out *bytes.Buffer) (*RelayHubAdmin, *backends.SimulatedBackend) {
+	key, _ := crypto.HexToECDSA("b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0")
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000000000000000)}}
+	backend := backends.NewSimulatedBackend(alloc, 10000000)
+
+	bin, err := ioutil.ReadFile(filepath.Join("testdata", "GasLimit.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayHubAbi, _ := abi.JSON(strings.NewReader(relay.RelayABI))
+	options := bind.NewKeyedTransactor(key)
+	options.GasLimit = 3000000
+	address, _, _, err := bind.DeployContract(options, relayHubAbi, common.FromHex(strings.TrimSpace(string(bin))), backend, uint8(60), accountIngress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	admin, err := NewRelayHubAdmin(address, backend, key, 0, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.Timeout = 5 * time.Second
+	return admin, backend
+}
+
+func TestDryRunPrintsCalldata(t *testing.T) {
+	out := new(bytes.Buffer)
+	admin, backend := newSimulatedAdmin(t, out)
+	defer backend.Close()
+	admin.DryRun = true
+
+	err := admin.Exec("add-node", []string{nodeAddress.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := admin.abi.Pack("addNode", nodeAddress)
+	if !strings.Contains(out.String(), "data: "+hexutil.Encode(data)) {
+		t.Errorf("Dry run should print the encoded calldata, got %s", out.String())
+	}
+
+	if nonce, _ := backend.PendingNonceAt(context.Background(), admin.options.From); nonce != 1 {
+		t.Errorf("Dry run shouldn't send any transaction")
+	}
+}
+
+func TestExecWaitsForReceipt(t *testing.T) {
+	out := new(bytes.Buffer)
+	admin, backend := newSimulatedAdmin(t, out)
+	defer backend.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+				backend.Commit()
+			}
+		}
+	}()
+
+	receipt, err := admin.SetMaxGasBlockLimit(big.NewInt(50000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt == nil || receipt.BlockNumber.Uint64() == 0 {
+		t.Fatalf("Receipt of the mined transaction should be returned")
+	}
+
+	tx, _, err := backend.TransactionByHash(context.Background(), receipt.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := admin.abi.Pack("setMaxGasBlockLimit", big.NewInt(50000000))
+	if *tx.To() != admin.Address || !bytes.Equal(tx.Data(), data) {
+		t.Errorf("Transaction should call setMaxGasBlockLimit on RelayHub")
+	}
+	if limit, err := admin.contract.GetMaxGasBlockLimit(&bind.CallOpts{}); err != nil || limit.Int64() != 50000000 {
+		t.Errorf("RelayHub max gas block limit should be updated, got %v %v", limit, err)
+	}
+
+	// only the account permissioning ingress may add nodes
+	if _, err := admin.AddNode(nodeAddress); err == nil || !strings.Contains(err.Error(), "addNode was reverted") {
+		t.Errorf("addNode from the admin account should be reverted by RelayHub, got %v", err)
+	}
+}
+
+func TestExecRejectsBadArguments(t *testing.T) {
+	admin, backend := newSimulatedAdmin(t, new(bytes.Buffer))
+	defer backend.Close()
+
+	cases := []struct {
+		command string
+		args    []string
+	}{
+		{"add-node", []string{"0x123"}},
+		{"set-blocks-frequency", []string{"300"}},
+		{"grant-role", []string{"operator", nodeAddress.Hex()}},
+		{"delete-node", nil},
+		{"unknown", nil},
+	}
+
+	for _, c := range cases {
+		if err := admin.Exec(c.command, c.args); err == nil {
+			t.Errorf("%s %v should fail", c.command, c.args)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const DEFAULT_GAS_LIMIT uint64 = 300000
+const DEFAULT_RECEIPT_TIMEOUT = 60 * time.Second
+
+// Backend is the connection needed to send a transaction and wait for its receipt
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+}
+
+// transactor signs administrative transactions, prints them on dry runs and waits for their receipts
+type transactor struct {
+	DryRun  bool
+	Timeout time.Duration
+	Out     io.Writer
+	backend Backend
+	options *bind.TransactOpts
+}
+
+func newTransactor(backend Backend, key *ecdsa.PrivateKey, gasLimit uint64, out io.Writer) *transactor {
+	if gasLimit == 0 {
+		gasLimit = DEFAULT_GAS_LIMIT
+	}
+
+	options := bind.NewKeyedTransactor(key)
+	options.GasLimit = gasLimit
+	options.GasPrice = big.NewInt(0)
+
+	return &transactor{Timeout: DEFAULT_RECEIPT_TIMEOUT, Out: out, backend: backend, options: options}
+}
+
+func (t *transactor) execute(contractAbi abi.ABI, address common.Address, method string, transact func(*bind.TransactOpts) (*types.Transaction, error), args ...interface{}) (*types.Receipt, error) {
+	if t.DryRun {
+		data, err := contractAbi.Pack(method, args...)
+		if err != nil {
+			msg := fmt.Sprintf("Error encoding %s", method)
+			return nil, errors.FailedTransaction.Wrapf(err, msg, -32603)
+		}
+		fmt.Fprintf(t.Out, "from: %s\nto: %s\nmethod: %s\ndata: %s\n", t.options.From.Hex(), address.Hex(), method, hexutil.Encode(data))
+		return nil, nil
+	}
+
+	tx, err := transact(t.options)
+	if err != nil {
+		msg := fmt.Sprintf("failed executing %s", method)
+		return nil, errors.FailedTransaction.Wrapf(err, msg, -32603)
+	}
+	fmt.Fprintf(t.Out, "%s sent: %s\n", method, tx.Hash().Hex())
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+
+	receipt, err := bind.WaitMined(ctx, t.backend, tx)
+	if err != nil {
+		msg := fmt.Sprintf("failed waiting receipt of %s", tx.Hash().Hex())
+		return nil, errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+	}
+	fmt.Fprintf(t.Out, "%s mined in block %s with status %d\n", method, receipt.BlockNumber, receipt.Status)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, errors.FailedTransaction.New(fmt.Sprintf("%s was reverted", method), -32603)
+	}
+
+	return receipt, nil
+}
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// LEVEL_INFO writes general and error messages
+	LEVEL_INFO = "info"
+	// LEVEL_ERROR writes only error messages
+	LEVEL_ERROR = "error"
+)
+
+var output io.Writer
+
+// SetLevel changes the verbosity of GeneralLogger, an empty level is info. ErrorLogger, used for failures, always writes
+func SetLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "", LEVEL_INFO:
+		GeneralLogger.SetOutput(output)
+	case LEVEL_ERROR:
+		GeneralLogger.SetOutput(ioutil.Discard)
+	default:
+		return fmt.Errorf("unknown log level %q, expected %s or %s", level, LEVEL_INFO, LEVEL_ERROR)
+	}
+	return nil
+}
+
+// ValidLevel reports whether level can be passed to SetLevel
+func ValidLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "", LEVEL_INFO, LEVEL_ERROR:
+		return true
+	}
+	return false
+}
@@ -32,6 +32,7 @@ func init() {
 		fmt.Println("Error opening file:", err)
 		os.Exit(1)
 	}
+	output = generalLog
 	GeneralLogger = log.New(generalLog, "General Logger:\t", log.Ldate|log.Ltime|log.Lshortfile)
 	ErrorLogger = log.New(generalLog, "Error Logger:\t", log.Ldate|log.Ltime|log.Lshortfile)
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
 	"github.com/LACNetNetworks/gas-relay-signer/errors"
 	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -24,9 +26,19 @@ const (
 	deployMetaTxMethod = "deployMetaTx"
 )
 
+// Readers picks the healthy node used for reads and is told about the nodes failing them
+type Readers interface {
+	ReaderURL() string
+	ReportError(url string, err error)
+	Status() []model.NodeStatus
+}
+
 // Client to manage connection to Ethereum
 type Client struct {
-	client *ethclient.Client
+	client    *ethclient.Client
+	reader    *ethclient.Client
+	readerURL string
+	readers   Readers
 }
 
 // GetEthclient ...
@@ -48,42 +60,109 @@ func (ec *Client) Connect(nodeURL string) error {
 	return nil
 }
 
+// ConnectReaders connects sends to the writer node and reads to the healthy node picked by readers
+func (ec *Client) ConnectReaders(writerURL string, readers Readers) error {
+	err := ec.Connect(writerURL)
+	if err != nil {
+		return err
+	}
+	ec.readers = readers
+
+	readerURL := readers.ReaderURL()
+	if readerURL == writerURL {
+		return nil
+	}
+
+	reader, err := ethclient.Dial(readerURL)
+	if err != nil {
+		readers.ReportError(readerURL, err)
+		log.ErrorLogger.Println("Can't connect to node", readerURL, "reading from the writer node:", err)
+		return nil
+	}
+	ec.reader = reader
+	ec.readerURL = readerURL
+	return nil
+}
+
+// readClient is the connection used for calls that don't depend on the writer node state
+func (ec *Client) readClient() *ethclient.Client {
+	if ec.reader != nil {
+		return ec.reader
+	}
+	return ec.client
+}
+
+func (ec *Client) readFailed(err error) {
+	if ec.readers != nil && ec.reader != nil && isNodeError(err) {
+		ec.readers.ReportError(ec.readerURL, err)
+	}
+}
+
+// isNodeError tells transport and RPC failures apart from answers of the contracts, such as reverts, that any node would give
+func isNodeError(err error) bool {
+	if err == ethereum.NotFound || err == bind.ErrNoCode {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return !strings.Contains(message, "revert") && !strings.HasPrefix(message, "abi:")
+}
+
 // Close ethereum connection
 func (ec *Client) Close() {
 	ec.client.Close()
+	if ec.reader != nil {
+		ec.reader.Close()
+	}
 }
 
-// ConfigTransaction from ethereum address contract
-func (ec *Client) ConfigTransaction(key *ecdsa.PrivateKey, gasLimit uint64, pending bool) (*bind.TransactOpts, error) {
+// NewTransactOpts signs zero gas price transactions with key, a zero gasLimit is estimated when sending
+func NewTransactOpts(key *ecdsa.PrivateKey, gasLimit uint64, nonce uint64) *bind.TransactOpts {
 	auth := bind.NewKeyedTransactor(key)
 
-	var nonce uint64
-	var err error
-
-	if pending {
-		nonce, err = ec.client.PendingNonceAt(context.Background(), auth.From)
-		if err != nil {
-			msg := fmt.Sprintf("can't get pending nonce for:%s", auth.From)
-			err = errors.FailedConfigTransaction.Wrapf(err, msg, -32603)
-			return nil, err
-		}
-	} else {
-		nonce, err = ec.client.NonceAt(context.Background(), auth.From, nil)
-		if err != nil {
-			msg := fmt.Sprintf("can't get latest nonce for:%s", auth.From)
-			err = errors.FailedConfigTransaction.Wrapf(err, msg, -32604)
-			return nil, err
-		}
-	}
-
-	auth.Nonce = big.NewInt(int64(nonce))
+	auth.Nonce = new(big.Int).SetUint64(nonce)
 	auth.Value = big.NewInt(0) // in wei
 	auth.GasLimit = gasLimit   // in units
 	auth.GasPrice = big.NewInt(0)
 
 	log.GeneralLogger.Printf("OptionsTransaction=[From:0x%x,nonce:%d,gasPrice:%s,gasLimit:%d", auth.From, nonce, auth.GasPrice, auth.GasLimit)
 
-	return auth, nil
+	return auth
+}
+
+// GetChainID ...
+func (ec *Client) GetChainID() (*big.Int, error) {
+	chainID, err := ec.client.ChainID(context.Background())
+	if err != nil {
+		err = errors.CallBlockchainFailed.Wrapf(err, "failed get chain id", -32603)
+		return nil, err
+	}
+	return chainID, nil
+}
+
+// GetPendingNonce ...
+func (ec *Client) GetPendingNonce(address common.Address) (uint64, error) {
+	nonce, err := ec.client.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		msg := fmt.Sprintf("can't get pending nonce for:%s", address)
+		err = errors.FailedConfigTransaction.Wrapf(err, msg, -32603)
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// EstimatePrivacyMarkerGas of the transaction a node sends to the privacy precompile with the enclave key as payload
+func (ec *Client) EstimatePrivacyMarkerGas(from, precompileAddress common.Address, payloadSize int) (uint64, error) {
+	// non zero bytes are the worst case of the calldata cost of the unknown enclave key
+	payload := bytes.Repeat([]byte{0xff}, payloadSize)
+
+	gas, err := ec.client.EstimateGas(context.Background(), ethereum.CallMsg{From: from, To: &precompileAddress, Data: payload})
+	if err != nil {
+		msg := fmt.Sprintf("failed to estimate privacy marker transaction gas on %s", precompileAddress.Hex())
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return 0, err
+	}
+
+	return gas, nil
 }
 
 // SendMetatransaction into blockchain
@@ -163,8 +242,9 @@ func (ec *Client) GenerateTransaction(options *bind.TransactOpts, to *common.Add
 
 // GetTransactionReceipt ...
 func (ec *Client) GetTransactionReceipt(transactionHash common.Hash) (*types.Receipt, error) {
-	receipt, err := ec.client.TransactionReceipt(context.Background(), transactionHash)
+	receipt, err := ec.readClient().TransactionReceipt(context.Background(), transactionHash)
 	if err != nil {
+		ec.readFailed(err)
 		msg := fmt.Sprintf("failed get transaction receipt %s", transactionHash.Hex())
 		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
 		return nil, err
@@ -179,7 +259,7 @@ func (ec *Client) GetTransactionReceipt(transactionHash common.Hash) (*types.Rec
 
 // GetTransactionCount ...
 func (ec *Client) GetTransactionCount(contractAddress common.Address, address common.Address, nodeAddress common.Address) (*big.Int, error) {
-	contract, err := relay.NewRelay(contractAddress, ec.client)
+	contract, err := relay.NewRelay(contractAddress, ec.readClient())
 	if err != nil {
 		msg := fmt.Sprintf("can't instance RelayHub contract %s", contractAddress)
 		err = errors.FailedContract.Wrapf(err, msg, -32603)
@@ -192,6 +272,7 @@ func (ec *Client) GetTransactionCount(contractAddress common.Address, address co
 
 	if err != nil {
 		msg := fmt.Sprintf("failed get transaction count for %s", address.Hex())
+		ec.readFailed(err)
 		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
 		return nil, err
 	}
@@ -249,6 +330,19 @@ func (ec *Client) GetBlockByNumber(contractAddress common.Address, blockNumber *
 	return block, gasLimit.Uint64(), nil
 }
 
+// GetLatestHeader ...
+func (ec *Client) GetLatestHeader() (*types.Header, error) {
+	header, err := ec.readClient().HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		ec.readFailed(err)
+		msg := "failed get latest block header"
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	return header, nil
+}
+
 // GetGasLimit ...
 func (ec *Client) GetGasLimit(contractAddress, nodeAddress common.Address) (*big.Int, error) {
 	contract, err := relay.NewRelay(contractAddress, ec.client)
@@ -339,7 +433,7 @@ func (ec *Client) GetCurrentGasLimit(contractAddress common.Address) (*big.Int,
 
 // AccountPermitted ...
 func (ec *Client) AccountPermitted(contractAddress, senderAddress common.Address) (bool, error) {
-	contract, err := relay.NewAccount(contractAddress, ec.client)
+	contract, err := relay.NewAccount(contractAddress, ec.readClient())
 	if err != nil {
 		msg := fmt.Sprintf("can't instance RelayHub contract %s", contractAddress)
 		err = errors.FailedContract.Wrapf(err, msg, -32603)
@@ -352,9 +446,185 @@ func (ec *Client) AccountPermitted(contractAddress, senderAddress common.Address
 
 	if err != nil {
 		msg := fmt.Sprintf("failed to know if account is permitted from %s", contractAddress.Hex())
+		ec.readFailed(err)
 		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
 		return false, err
 	}
 
 	return isPermitted, nil
 }
+
+// GetGasUsedLastBlocks ...
+func (ec *Client) GetGasUsedLastBlocks(contractAddress common.Address) (*big.Int, error) {
+	contract, err := relay.NewRelay(contractAddress, ec.client)
+	if err != nil {
+		msg := fmt.Sprintf("can't instance RelayHub contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	log.GeneralLogger.Println("RelayHub Contract instanced:", contractAddress.Hex())
+
+	gasUsed, err := contract.GetGasUsedLastBlocks(&bind.CallOpts{})
+
+	if err != nil {
+		msg := fmt.Sprintf("failed get gas used last blocks from %s", contractAddress.Hex())
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	return gasUsed, nil
+}
+
+// GetNodes ...
+func (ec *Client) GetNodes(contractAddress common.Address) (*big.Int, error) {
+	contract, err := relay.NewRelay(contractAddress, ec.client)
+	if err != nil {
+		msg := fmt.Sprintf("can't instance RelayHub contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	log.GeneralLogger.Println("RelayHub Contract instanced:", contractAddress.Hex())
+
+	nodes, err := contract.GetNodes(&bind.CallOpts{})
+
+	if err != nil {
+		msg := fmt.Sprintf("failed get nodes from %s", contractAddress.Hex())
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// DestinationPermitted ...
+func (ec *Client) DestinationPermitted(contractAddress, targetAddress common.Address) (bool, error) {
+	contract, err := relay.NewAccount(contractAddress, ec.readClient())
+	if err != nil {
+		msg := fmt.Sprintf("can't instance AccountRules contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return false, err
+	}
+
+	log.GeneralLogger.Println("AccountPermissioning Contract instanced:", contractAddress.Hex())
+
+	isPermitted, err := contract.DestinationPermitted(&bind.CallOpts{}, targetAddress)
+
+	if err != nil {
+		msg := fmt.Sprintf("failed to know if destination is permitted from %s", contractAddress.Hex())
+		ec.readFailed(err)
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return false, err
+	}
+
+	return isPermitted, nil
+}
+
+// TransactionAllowed ...
+func (ec *Client) TransactionAllowed(contractAddress, senderAddress, targetAddress common.Address, value, gasPrice *big.Int, gasLimit uint64, payload []byte) (bool, error) {
+	contract, err := relay.NewAccount(contractAddress, ec.readClient())
+	if err != nil {
+		msg := fmt.Sprintf("can't instance AccountRules contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return false, err
+	}
+
+	log.GeneralLogger.Println("AccountPermissioning Contract instanced:", contractAddress.Hex())
+
+	// transactionAllowed is a view in the permissioning contract but it is bound as a transactor
+	var isAllowed bool
+	caller := relay.AccountCallerRaw{Contract: &contract.AccountCaller}
+	err = caller.Call(&bind.CallOpts{}, &isAllowed, "transactionAllowed", senderAddress, targetAddress, value, gasPrice, new(big.Int).SetUint64(gasLimit), payload)
+
+	if err != nil {
+		msg := fmt.Sprintf("failed to know if transaction is allowed from %s", contractAddress.Hex())
+		ec.readFailed(err)
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return false, err
+	}
+
+	return isAllowed, nil
+}
+
+// GetAccounts ...
+func (ec *Client) GetAccounts(contractAddress common.Address) ([]common.Address, error) {
+	contract, err := relay.NewAccount(contractAddress, ec.readClient())
+	if err != nil {
+		msg := fmt.Sprintf("can't instance AccountRules contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	accounts, err := contract.GetAccounts(&bind.CallOpts{})
+
+	if err != nil {
+		msg := fmt.Sprintf("failed get accounts from %s", contractAddress.Hex())
+		ec.readFailed(err)
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// GetTargets ...
+func (ec *Client) GetTargets(contractAddress common.Address) ([]common.Address, error) {
+	contract, err := relay.NewAccount(contractAddress, ec.readClient())
+	if err != nil {
+		msg := fmt.Sprintf("can't instance AccountRules contract %s", contractAddress)
+		err = errors.FailedContract.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	targets, err := contract.GetTargets(&bind.CallOpts{})
+
+	if err != nil {
+		msg := fmt.Sprintf("failed get targets from %s", contractAddress.Hex())
+		ec.readFailed(err)
+		err = errors.CallBlockchainFailed.Wrapf(err, msg, -32603)
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// GetRelayHubAddress returns the RelayHub the proxy deployed at proxyAddress forwards to
+func (ec *Client) GetRelayHubAddress(proxyAddress common.Address) (common.Address, error) {
+	contract, err := relay.NewRelayHubProxy(proxyAddress, ec.client)
+	if err != nil {
+		msg := fmt.Sprintf("can't instance RelayHub proxy contract %s", proxyAddress.Hex())
+		return common.Address{}, errors.FailedContract.Wrapf(err, msg, -32603)
+	}
+
+	relayHubAddress, err := contract.GetRelayHub(&bind.CallOpts{})
+	if err != nil {
+		msg := fmt.Sprintf("failed get RelayHub address from proxy %s", proxyAddress.Hex())
+		return common.Address{}, errors.CallBlockchainFailed.Wrapf(err, msg, -32610)
+	}
+	if relayHubAddress == (common.Address{}) {
+		msg := fmt.Sprintf("proxy %s has no RelayHub address", proxyAddress.Hex())
+		return common.Address{}, errors.InvalidAddress.New(msg, -32610)
+	}
+	return relayHubAddress, nil
+}
+
+// SubscribeContractLogs sends every log emitted by contractAddress to logs
+func (ec *Client) SubscribeContractLogs(contractAddress common.Address, logs chan<- types.Log) (ethereum.Subscription, error) {
+	query := ethereum.FilterQuery{Addresses: []common.Address{contractAddress}}
+	subscription, err := ec.client.SubscribeFilterLogs(context.Background(), query, logs)
+	if err != nil {
+		msg := fmt.Sprintf("can't subscribe to the logs of %s", contractAddress.Hex())
+		return nil, errors.FailedConnection.Wrapf(err, msg, -32100)
+	}
+	return subscription, nil
+}
+
+// SubscribeNewHeads sends the header of every new block to headers
+func (ec *Client) SubscribeNewHeads(headers chan<- *types.Header) (ethereum.Subscription, error) {
+	subscription, err := ec.client.SubscribeNewHead(context.Background(), headers)
+	if err != nil {
+		return nil, errors.FailedConnection.Wrapf(err, "can't subscribe to new block headers", -32100)
+	}
+	return subscription, nil
+}
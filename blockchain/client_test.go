@@ -0,0 +1,35 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+func TestIsNodeError(t *testing.T) {
+	nodeErrors := []error{
+		errors.New("dial tcp 127.0.0.1:4545: connect: connection refused"),
+		errors.New("502 Bad Gateway"),
+		errors.New("Internal error"),
+	}
+	for _, err := range nodeErrors {
+		if !isNodeError(err) {
+			t.Errorf("%s should be a node error", err)
+		}
+	}
+
+	contractErrors := []error{
+		ethereum.NotFound,
+		bind.ErrNoCode,
+		errors.New("execution reverted: not allowed"),
+		errors.New("Execution reverted"),
+		errors.New("abi: attempting to unmarshall an empty string while arguments are expected"),
+	}
+	for _, err := range contractErrors {
+		if isNodeError(err) {
+			t.Errorf("%s should not be a node error", err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package relay
+
+import (
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// RelayHubProxyABI is the input ABI used to generate the binding from.
+const RelayHubProxyABI = "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_newRelayHub\",\"type\":\"address\"}],\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"inputs\":[],\"name\":\"getMsgSender\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getRelayHub\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_newRelayHub\",\"type\":\"address\"}],\"name\":\"setRelayHub\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
+
+// RelayHubProxy is an auto generated Go binding around an Ethereum contract.
+type RelayHubProxy struct {
+	RelayHubProxyCaller     // Read-only binding to the contract
+	RelayHubProxyTransactor // Write-only binding to the contract
+	RelayHubProxyFilterer   // Log filterer for contract events
+}
+
+// RelayHubProxyCaller is an auto generated read-only Go binding around an Ethereum contract.
+type RelayHubProxyCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RelayHubProxyTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type RelayHubProxyTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RelayHubProxyFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type RelayHubProxyFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RelayHubProxySession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type RelayHubProxySession struct {
+	Contract     *RelayHubProxy    // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// RelayHubProxyCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type RelayHubProxyCallerSession struct {
+	Contract *RelayHubProxyCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts        // Call options to use throughout this session
+}
+
+// RelayHubProxyTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type RelayHubProxyTransactorSession struct {
+	Contract     *RelayHubProxyTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts        // Transaction auth options to use throughout this session
+}
+
+// RelayHubProxyRaw is an auto generated low-level Go binding around an Ethereum contract.
+type RelayHubProxyRaw struct {
+	Contract *RelayHubProxy // Generic contract binding to access the raw methods on
+}
+
+// RelayHubProxyCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type RelayHubProxyCallerRaw struct {
+	Contract *RelayHubProxyCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// RelayHubProxyTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type RelayHubProxyTransactorRaw struct {
+	Contract *RelayHubProxyTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewRelayHubProxy creates a new instance of RelayHubProxy, bound to a specific deployed contract.
+func NewRelayHubProxy(address common.Address, backend bind.ContractBackend) (*RelayHubProxy, error) {
+	contract, err := bindRelayHubProxy(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &RelayHubProxy{RelayHubProxyCaller: RelayHubProxyCaller{contract: contract}, RelayHubProxyTransactor: RelayHubProxyTransactor{contract: contract}, RelayHubProxyFilterer: RelayHubProxyFilterer{contract: contract}}, nil
+}
+
+// NewRelayHubProxyCaller creates a new read-only instance of RelayHubProxy, bound to a specific deployed contract.
+func NewRelayHubProxyCaller(address common.Address, caller bind.ContractCaller) (*RelayHubProxyCaller, error) {
+	contract, err := bindRelayHubProxy(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RelayHubProxyCaller{contract: contract}, nil
+}
+
+// NewRelayHubProxyTransactor creates a new write-only instance of RelayHubProxy, bound to a specific deployed contract.
+func NewRelayHubProxyTransactor(address common.Address, transactor bind.ContractTransactor) (*RelayHubProxyTransactor, error) {
+	contract, err := bindRelayHubProxy(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RelayHubProxyTransactor{contract: contract}, nil
+}
+
+// NewRelayHubProxyFilterer creates a new log filterer instance of RelayHubProxy, bound to a specific deployed contract.
+func NewRelayHubProxyFilterer(address common.Address, filterer bind.ContractFilterer) (*RelayHubProxyFilterer, error) {
+	contract, err := bindRelayHubProxy(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &RelayHubProxyFilterer{contract: contract}, nil
+}
+
+// bindRelayHubProxy binds a generic wrapper to an already deployed contract.
+func bindRelayHubProxy(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(RelayHubProxyABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RelayHubProxy *RelayHubProxyRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _RelayHubProxy.Contract.RelayHubProxyCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RelayHubProxy *RelayHubProxyRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.RelayHubProxyTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RelayHubProxy *RelayHubProxyRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.RelayHubProxyTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RelayHubProxy *RelayHubProxyCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _RelayHubProxy.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RelayHubProxy *RelayHubProxyTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RelayHubProxy *RelayHubProxyTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetRelayHub is a free data retrieval call binding the contract method 0x7bdf2ec7.
+//
+// Solidity: function getRelayHub() view returns(address)
+func (_RelayHubProxy *RelayHubProxyCaller) GetRelayHub(opts *bind.CallOpts) (common.Address, error) {
+	var (
+		ret0 = new(common.Address)
+	)
+	out := ret0
+	err := _RelayHubProxy.contract.Call(opts, out, "getRelayHub")
+	return *ret0, err
+}
+
+// GetRelayHub is a free data retrieval call binding the contract method 0x7bdf2ec7.
+//
+// Solidity: function getRelayHub() view returns(address)
+func (_RelayHubProxy *RelayHubProxySession) GetRelayHub() (common.Address, error) {
+	return _RelayHubProxy.Contract.GetRelayHub(&_RelayHubProxy.CallOpts)
+}
+
+// GetRelayHub is a free data retrieval call binding the contract method 0x7bdf2ec7.
+//
+// Solidity: function getRelayHub() view returns(address)
+func (_RelayHubProxy *RelayHubProxyCallerSession) GetRelayHub() (common.Address, error) {
+	return _RelayHubProxy.Contract.GetRelayHub(&_RelayHubProxy.CallOpts)
+}
+
+// GetMsgSender is a paid mutator transaction binding the contract method 0x7a6ce2e1.
+//
+// Solidity: function getMsgSender() returns(address)
+func (_RelayHubProxy *RelayHubProxyTransactor) GetMsgSender(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RelayHubProxy.contract.Transact(opts, "getMsgSender")
+}
+
+// GetMsgSender is a paid mutator transaction binding the contract method 0x7a6ce2e1.
+//
+// Solidity: function getMsgSender() returns(address)
+func (_RelayHubProxy *RelayHubProxySession) GetMsgSender() (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.GetMsgSender(&_RelayHubProxy.TransactOpts)
+}
+
+// GetMsgSender is a paid mutator transaction binding the contract method 0x7a6ce2e1.
+//
+// Solidity: function getMsgSender() returns(address)
+func (_RelayHubProxy *RelayHubProxyTransactorSession) GetMsgSender() (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.GetMsgSender(&_RelayHubProxy.TransactOpts)
+}
+
+// SetRelayHub is a paid mutator transaction binding the contract method 0x7bb05264.
+//
+// Solidity: function setRelayHub(address _newRelayHub) returns()
+func (_RelayHubProxy *RelayHubProxyTransactor) SetRelayHub(opts *bind.TransactOpts, _newRelayHub common.Address) (*types.Transaction, error) {
+	return _RelayHubProxy.contract.Transact(opts, "setRelayHub", _newRelayHub)
+}
+
+// SetRelayHub is a paid mutator transaction binding the contract method 0x7bb05264.
+//
+// Solidity: function setRelayHub(address _newRelayHub) returns()
+func (_RelayHubProxy *RelayHubProxySession) SetRelayHub(_newRelayHub common.Address) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.SetRelayHub(&_RelayHubProxy.TransactOpts, _newRelayHub)
+}
+
+// SetRelayHub is a paid mutator transaction binding the contract method 0x7bb05264.
+//
+// Solidity: function setRelayHub(address _newRelayHub) returns()
+func (_RelayHubProxy *RelayHubProxyTransactorSession) SetRelayHub(_newRelayHub common.Address) (*types.Transaction, error) {
+	return _RelayHubProxy.Contract.SetRelayHub(&_RelayHubProxy.TransactOpts, _newRelayHub)
+}
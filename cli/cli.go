@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// PROGRAM is the name of the executable shown in the usage of every command
+const PROGRAM = "gas-relay-signer"
+
+// Runner runs a command with its positional arguments once its flags are parsed
+type Runner func(args []string, out io.Writer) error
+
+// Command is a subcommand of the relay signer, Execute parses its flags and prints its usage the same way for every command
+type Command struct {
+	// Name selects the command, e.g. decode-tx
+	Name string
+	// Arguments describes the positional arguments in the usage, e.g. <rawTx>
+	Arguments string
+	// Summary is the line listed by help
+	Summary string
+	// Help is printed by help <command> after the summary, before the flags
+	Help string
+	// MinArgs and MaxArgs bound the positional arguments, a negative MaxArgs accepts any number
+	MinArgs int
+	MaxArgs int
+	// Setup defines the flags of the command and returns the function running it
+	Setup func(flags *flag.FlagSet) Runner
+}
+
+// Execute runs the command named by the first of args with the rest of them, "help [command]" prints the usage of
+// every command or of one of them
+func Execute(program *flag.FlagSet, commands []*Command, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		Usage(out, program, commands)
+		return fmt.Errorf("missing command")
+	}
+	name, args := args[0], args[1:]
+
+	if name == "help" {
+		if len(args) == 0 {
+			Usage(out, program, commands)
+			return nil
+		}
+		command := find(commands, args[0])
+		if command == nil {
+			Usage(out, program, commands)
+			return fmt.Errorf("unknown command %s", args[0])
+		}
+		flags := newFlagSet(command, out)
+		command.Setup(flags)
+		flags.Usage()
+		return nil
+	}
+
+	command := find(commands, name)
+	if command == nil {
+		Usage(out, program, commands)
+		return fmt.Errorf("unknown command %s", name)
+	}
+	flags := newFlagSet(command, out)
+	run := command.Setup(flags)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() < command.MinArgs || (command.MaxArgs >= 0 && flags.NArg() > command.MaxArgs) {
+		expected := command.Arguments
+		if expected == "" {
+			expected = "no arguments"
+		}
+		flags.Usage()
+		return fmt.Errorf("%s expects %s, got %d argument(s)", name, expected, flags.NArg())
+	}
+	return run(flags.Args(), out)
+}
+
+// Usage prints the commands and the flags of program shared by all of them
+func Usage(out io.Writer, program *flag.FlagSet, commands []*Command) {
+	fmt.Fprintf(out, "usage: %s [flags] <command> [arguments]\n\ncommands:\n", PROGRAM)
+	table := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	for _, command := range commands {
+		fmt.Fprintf(table, "  %s\t%s\n", strings.TrimSpace(command.Name+" "+command.Arguments), command.Summary)
+	}
+	fmt.Fprintf(table, "  %s\t%s\n", "help [command]", "show the usage of every command or of one command")
+	table.Flush()
+	fmt.Fprintf(out, "\nflags:\n")
+	program.SetOutput(out)
+	program.PrintDefaults()
+}
+
+func newFlagSet(command *Command, out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+		usage := PROGRAM + " " + command.Name
+		if hasFlags {
+			usage += " [flags]"
+		}
+		fmt.Fprintf(out, "usage: %s\n\n%s\n", strings.TrimSpace(usage+" "+command.Arguments), command.Summary)
+		if command.Help != "" {
+			fmt.Fprintf(out, "\n%s", command.Help)
+		}
+		if hasFlags {
+			fmt.Fprintf(out, "\nflags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+func find(commands []*Command, name string) *Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
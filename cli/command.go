@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/LACNetNetworks/gas-relay-signer/client"
+	conf "github.com/LACNetNetworks/gas-relay-signer/config"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sha "golang.org/x/crypto/sha3"
+)
+
+// DecodeTxCommand shows what the relay signer relays for a raw transaction
+func DecodeTxCommand() *Command {
+	return &Command{
+		Name:      "decode-tx",
+		Arguments: "<rawTx>",
+		Summary:   "show the sender and the signingData relayed for a raw transaction",
+		MinArgs:   1,
+		MaxArgs:   1,
+		Setup: func(flags *flag.FlagSet) Runner {
+			return RunDecodeTx
+		},
+	}
+}
+
+// SignPayloadCommand signs a meta transaction payload with the flags of RunSignPayload
+func SignPayloadCommand() *Command {
+	return &Command{
+		Name:    "sign-payload",
+		Summary: "hash and sign a meta transaction payload like the RelayHub expects",
+		Help: `signs keccak256(from, to, keccak256(data), gas, nonce) as an Ethereum signed message,
+without --to the hash of a contract deployment is signed
+`,
+		Setup: func(flags *flag.FlagSet) Runner {
+			key := flags.String("key", "", "hex private key, WRITER_KEY by default")
+			from := flags.String("from", "", "signing address, the address of the key by default")
+			to := flags.String("to", "", "destination address, empty for a contract deployment")
+			data := flags.String("data", "0x", "hex encoded function call or bytecode")
+			gasLimit := flags.Uint64("gas", 0, "gas limit")
+			nonce := flags.Uint64("nonce", 0, "RelayHub nonce of the signing address")
+			return func(args []string, out io.Writer) error {
+				return RunSignPayload(*key, *from, *to, *data, *gasLimit, *nonce, out)
+			}
+		},
+	}
+}
+
+// ReceiptCommand fetches a receipt with the relay signer initialized from the configuration returned by loadConfig
+func ReceiptCommand(loadConfig func() *model.Config) *Command {
+	return &Command{
+		Name:      "receipt",
+		Arguments: "<txHash>",
+		Summary:   "show the receipt of a relayed transaction with its revert reason",
+		MinArgs:   1,
+		MaxArgs:   1,
+		Setup: func(flags *flag.FlagSet) Runner {
+			return func(args []string, out io.Writer) error {
+				return RunReceipt(loadConfig(), args, out)
+			}
+		},
+	}
+}
+
+// ConfigCommand validates the file given or the one set in path by --config, used by serve
+func ConfigCommand(path *string) *Command {
+	return &Command{
+		Name:      "config",
+		Arguments: "check [file]",
+		Summary:   "validate a configuration file and its environment overrides",
+		MinArgs:   1,
+		MaxArgs:   2,
+		Setup: func(flags *flag.FlagSet) Runner {
+			return func(args []string, out io.Writer) error {
+				return RunConfig(*path, args, out)
+			}
+		},
+	}
+}
+
+// RunDecodeTx decodes a raw transaction the way eth_sendRawTransaction does before relaying it
+func RunDecodeTx(args []string, out io.Writer) error {
+	tx, err := service.GetTransaction(strings.TrimPrefix(args[0], "0x"))
+	if err != nil {
+		return err
+	}
+	v, r, s := tx.RawSignatureValues()
+	if v == nil || r == nil || s == nil || v.Sign() == 0 {
+		return fmt.Errorf("%s is not a signed transaction", args[0])
+	}
+
+	message, err := tx.AsMessage(types.NewEIP155Signer(tx.ChainId()))
+	if err != nil {
+		return err
+	}
+	signingData, err := service.SigningData(tx)
+	if err != nil {
+		return err
+	}
+
+	to := "contract creation"
+	if tx.To() != nil {
+		to = tx.To().Hex()
+	}
+	fmt.Fprintf(out, "hash:           %s\n", tx.Hash().Hex())
+	fmt.Fprintf(out, "from:           %s\n", message.From().Hex())
+	fmt.Fprintf(out, "to:             %s\n", to)
+	fmt.Fprintf(out, "nonce:          %d\n", tx.Nonce())
+	fmt.Fprintf(out, "gasLimit:       %d\n", tx.Gas())
+	fmt.Fprintf(out, "gasPrice:       %s\n", tx.GasPrice())
+	fmt.Fprintf(out, "value:          %s\n", tx.Value())
+	fmt.Fprintf(out, "chainId:        %s\n", tx.ChainId())
+	fmt.Fprintf(out, "data:           %s\n", hexutil.Encode(tx.Data()))
+	fmt.Fprintf(out, "v:              %d\n", v)
+	fmt.Fprintf(out, "r:              0x%064x\n", r)
+	fmt.Fprintf(out, "s:              0x%064x\n", s)
+	fmt.Fprintf(out, "metaTxGasLimit: %d\n", service.MetaTxGasLimit(tx))
+	fmt.Fprintf(out, "signingData:    %s\n", hexutil.Encode(signingData))
+	return nil
+}
+
+// RunSignPayload hashes and signs a meta transaction payload with service.Hash and service.SignPayload
+func RunSignPayload(key, from, to, data string, gasLimit, nonce uint64, out io.Writer) error {
+	if key == "" {
+		key = os.Getenv(service.ENVIRONMENT_KEY_NAME)
+	}
+	key = strings.TrimPrefix(key, "0x")
+	privateKey, err := crypto.HexToECDSA(key)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %s", err)
+	}
+
+	if from == "" {
+		from = crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	} else if !common.IsHexAddress(from) {
+		return fmt.Errorf("invalid address %s", from)
+	}
+
+	var destination *common.Address
+	if to != "" {
+		if !common.IsHexAddress(to) {
+			return fmt.Errorf("invalid address %s", to)
+		}
+		address := common.HexToAddress(to)
+		destination = &address
+	}
+
+	payload, err := hexutil.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid data: %s", err)
+	}
+
+	d := sha.NewLegacyKeccak256()
+	d.Write(payload)
+	hash := service.Hash(from, destination, d.Sum(nil), fmt.Sprint(gasLimit), fmt.Sprint(nonce))
+
+	signature, err := service.SignPayload(key, from, destination, payload, gasLimit, nonce)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "hash:      %s\n", hexutil.Encode(hash))
+	fmt.Fprintf(out, "signature: %s\n", hexutil.Encode(signature))
+	return nil
+}
+
+// RunReceipt prints the receipt of a relayed transaction as the relay signer returns it, with the revert reason decoded
+func RunReceipt(config *model.Config, args []string, out io.Writer) error {
+	relaySignerService := service.NewRelaySignerService(nil)
+	if err := relaySignerService.Init(config); err != nil {
+		return err
+	}
+	response := relaySignerService.GetTransactionReceipt(json.RawMessage("1"), args[0])
+	if response.Error != nil {
+		return response.Error
+	}
+
+	var receipt map[string]interface{}
+	if err := json.Unmarshal(response.Result, &receipt); err != nil {
+		return err
+	}
+	if receipt == nil {
+		return fmt.Errorf("receipt of %s not found", args[0])
+	}
+
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, string(data))
+
+	if revertReason, ok := receipt["revertReason"].(string); ok {
+		output, err := hexutil.Decode(revertReason)
+		if err != nil {
+			return err
+		}
+		if reason, ok := client.DecodeRevertReason(output); ok {
+			fmt.Fprintln(out, "revert reason:", reason)
+		}
+	}
+	return nil
+}
+
+// RunConfig executes a configuration command, check validates the file given or the one used by serve
+func RunConfig(path string, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("unknown config command, expected check")
+	}
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	source := conf.NewSource(path)
+	if _, err := source.Load(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "configuration %s is valid\n", source.Path())
+	return nil
+}
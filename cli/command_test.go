@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const testKey = "b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0"
+
+func outputField(t *testing.T, output, field string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, field+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, field+":"))
+		}
+	}
+	t.Fatalf("%s missing from output:\n%s", field, output)
+	return ""
+}
+
+func TestDecodeTx(t *testing.T) {
+	privateKey, _ := crypto.HexToECDSA(testKey)
+	to := common.HexToAddress("0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B")
+	tx, err := types.SignTx(types.NewTransaction(7, to, big.NewInt(0), 100000, big.NewInt(0), []byte{0xca, 0xfe}), types.NewEIP155Signer(big.NewInt(648529)), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := rlp.EncodeToBytes(tx)
+
+	var out bytes.Buffer
+	if err := RunDecodeTx([]string{hexutil.Encode(raw)}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if from := outputField(t, out.String(), "from"); from != crypto.PubkeyToAddress(privateKey.PublicKey).Hex() {
+		t.Errorf("unexpected sender %s", from)
+	}
+	signingData, _ := service.SigningData(tx)
+	if data := outputField(t, out.String(), "signingData"); data != hexutil.Encode(signingData) {
+		t.Errorf("unexpected signingData %s", data)
+	}
+	if gas := outputField(t, out.String(), "metaTxGasLimit"); gas != "400210" {
+		t.Errorf("unexpected meta transaction gas limit %s", gas)
+	}
+
+	if err := RunDecodeTx([]string{"0x1234"}, &out); err == nil {
+		t.Error("expected an error decoding a malformed transaction")
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"sign-payload", "-key", testKey, "-to", "0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B", "-data", "0xcafe", "-gas", "100000", "-nonce", "3"}
+	if err := Execute(flag.NewFlagSet(PROGRAM, flag.ContinueOnError), []*Command{SignPayloadCommand()}, args, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, _ := hexutil.Decode(outputField(t, out.String(), "hash"))
+	signature, _ := hexutil.Decode(outputField(t, out.String(), "signature"))
+	if len(signature) != 65 || (signature[64] != 27 && signature[64] != 28) {
+		t.Fatalf("unexpected signature %x", signature)
+	}
+
+	prefixed := crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n32"), hash)
+	signature[64] -= 27
+	publicKey, err := crypto.SigToPub(prefixed, signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, _ := crypto.HexToECDSA(testKey)
+	if crypto.PubkeyToAddress(*publicKey) != crypto.PubkeyToAddress(privateKey.PublicKey) {
+		t.Error("signature doesn't recover the signing address")
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.toml")
+	ioutil.WriteFile(valid, []byte("[application]\nnodeURL = \"http://localhost:4545\"\ncontractAddress = \"0x39Ec8898eAD9d5995858EC4eEfA47ccC9DDe9cf0\"\nport = 9001\n"), 0600)
+	invalid := filepath.Join(dir, "invalid.toml")
+	ioutil.WriteFile(invalid, []byte("[application]\nnodeURL = \"localhost\"\n"), 0600)
+
+	var out bytes.Buffer
+	if err := RunConfig("", []string{"check", valid}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "is valid") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+
+	err := RunConfig(invalid, []string{"check"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "application.nodeURL") {
+		t.Errorf("expected the invalid node URL to be reported, got %v", err)
+	}
+}
+
+func TestExecuteUsage(t *testing.T) {
+	program := flag.NewFlagSet(PROGRAM, flag.ContinueOnError)
+	program.String("config", "", "path of the configuration file")
+	path := ""
+	commands := []*Command{DecodeTxCommand(), SignPayloadCommand(), ConfigCommand(&path)}
+
+	var out bytes.Buffer
+	if err := Execute(program, commands, []string{"help"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "decode-tx <rawTx>") || !strings.Contains(out.String(), "-config") {
+		t.Errorf("help should list the commands and the shared flags, got\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := Execute(program, commands, []string{"help", "sign-payload"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "usage: gas-relay-signer sign-payload [flags]") || !strings.Contains(out.String(), "-nonce") {
+		t.Errorf("help of a command should print its usage and flags, got\n%s", out.String())
+	}
+
+	out.Reset()
+	err := Execute(program, commands, []string{"decode-tx", "--verbose", "0x00"}, &out)
+	if err == nil || !strings.Contains(out.String(), "usage: gas-relay-signer decode-tx <rawTx>") {
+		t.Errorf("an unknown flag should fail with the usage of the command, got %v\n%s", err, out.String())
+	}
+
+	out.Reset()
+	err = Execute(program, commands, []string{"decode-tx"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "decode-tx expects <rawTx>") || !strings.Contains(out.String(), "usage: gas-relay-signer decode-tx") {
+		t.Errorf("missing arguments should fail with the usage of the command, got %v\n%s", err, out.String())
+	}
+
+	if err := Execute(program, commands, []string{"deploy"}, &out); err == nil || err.Error() != "unknown command deploy" {
+		t.Errorf("expected an unknown command error, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+// Package client submits transactions to a LACChain relay signer from Go programs.
+// It appends the writer node address and expiration trailer to the payload, signs the transaction
+// with the user key and reads the receipts the relay signer returns for relayed transactions.
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DEFAULT_EXPIRATION is how long after signing the writer node may still relay a transaction
+	DEFAULT_EXPIRATION = 20 * time.Minute
+	// GAS_ESTIMATE_MARGIN is the percentage added to the gas estimated for transactions sent with Send
+	GAS_ESTIMATE_MARGIN uint64 = 20
+	// DEFAULT_POLL_INTERVAL is the time between receipt queries of WaitReceipt
+	DEFAULT_POLL_INTERVAL = time.Second
+)
+
+var trailerArguments abi.Arguments
+
+func init() {
+	addressType, _ := abi.NewType("address", "", nil)
+	uint256Type, _ := abi.NewType("uint256", "", nil)
+	trailerArguments = abi.Arguments{{Type: addressType}, {Type: uint256Type}}
+}
+
+// Client sends the transactions of one account through a relay signer
+type Client struct {
+	rpc         *rpc.Client
+	key         *ecdsa.PrivateKey
+	From        common.Address
+	NodeAddress common.Address
+	// ChainID signs EIP-155 transactions when set, homestead transactions otherwise
+	ChainID    *big.Int
+	Expiration time.Duration
+	// GasLimit of the transactions sent with Send, estimated for every transaction when 0
+	GasLimit     uint64
+	PollInterval time.Duration
+}
+
+// Receipt is a receipt returned by the relay signer, Status is 0 when the relayed transaction reverted
+type Receipt struct {
+	*types.Receipt
+	// RevertReason is the raw revert output of a reverted relayed transaction
+	RevertReason []byte
+}
+
+// Reason decodes the revert reason, empty when the transaction didn't revert or the output isn't a standard error
+func (receipt *Receipt) Reason() string {
+	reason, _ := DecodeRevertReason(receipt.RevertReason)
+	return reason
+}
+
+// Dial connects to the relay signer at relayURL to send transactions signed with key through the writer node nodeAddress
+func Dial(relayURL string, key *ecdsa.PrivateKey, nodeAddress common.Address) (*Client, error) {
+	rpcClient, err := rpc.Dial(relayURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(rpcClient, key, nodeAddress), nil
+}
+
+// NewClient creates a client using an existing RPC connection to the relay signer
+func NewClient(rpcClient *rpc.Client, key *ecdsa.PrivateKey, nodeAddress common.Address) *Client {
+	return &Client{
+		rpc:          rpcClient,
+		key:          key,
+		From:         crypto.PubkeyToAddress(key.PublicKey),
+		NodeAddress:  nodeAddress,
+		Expiration:   DEFAULT_EXPIRATION,
+		PollInterval: DEFAULT_POLL_INTERVAL,
+	}
+}
+
+// Close the connection to the relay signer
+func (client *Client) Close() {
+	client.rpc.Close()
+}
+
+// Trailer encodes the writer node address and the expiration timestamp appended to every payload
+func (client *Client) Trailer(now time.Time) ([]byte, error) {
+	expiration := big.NewInt(now.Add(client.Expiration).Unix())
+	return trailerArguments.Pack(client.NodeAddress, expiration)
+}
+
+// BuildTransaction appends the trailer to data and signs the transaction, a nil to deploys data as a contract
+func (client *Client) BuildTransaction(nonce uint64, to *common.Address, value *big.Int, gasLimit uint64, data []byte) (*types.Transaction, error) {
+	trailer, err := client.Trailer(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	payload := append(common.CopyBytes(data), trailer...)
+
+	if value == nil {
+		value = new(big.Int)
+	}
+	var tx *types.Transaction
+	if to != nil {
+		tx = types.NewTransaction(nonce, *to, value, gasLimit, new(big.Int), payload)
+	} else {
+		tx = types.NewContractCreation(nonce, value, gasLimit, new(big.Int), payload)
+	}
+
+	var signer types.Signer = types.HomesteadSigner{}
+	if client.ChainID != nil {
+		signer = types.NewEIP155Signer(client.ChainID)
+	}
+	return types.SignTx(tx, signer, client.key)
+}
+
+// PendingNonce returns the next RelayHub nonce of the account, counting the transactions not mined yet
+func (client *Client) PendingNonce(ctx context.Context) (uint64, error) {
+	var nonce hexutil.Uint64
+	err := client.rpc.CallContext(ctx, &nonce, "eth_getTransactionCount", client.From, "pending")
+	return uint64(nonce), err
+}
+
+// SendTransaction submits a signed transaction and returns the hash of the relay transaction wrapping it
+func (client *Client) SendTransaction(ctx context.Context, tx *types.Transaction) (common.Hash, error) {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var hash common.Hash
+	err = client.rpc.CallContext(ctx, &hash, "eth_sendRawTransaction", hexutil.Encode(raw))
+	return hash, err
+}
+
+// EstimateGas asks the node, through the relay signer, for the gas of data with its trailer plus GAS_ESTIMATE_MARGIN percent
+func (client *Client) EstimateGas(ctx context.Context, to *common.Address, data []byte) (uint64, error) {
+	trailer, err := client.Trailer(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	call := map[string]interface{}{
+		"from": client.From,
+		"data": hexutil.Bytes(append(common.CopyBytes(data), trailer...)),
+	}
+	if to != nil {
+		call["to"] = to
+	}
+
+	var gas hexutil.Uint64
+	if err := client.rpc.CallContext(ctx, &gas, "eth_estimateGas", call); err != nil {
+		return 0, err
+	}
+	return uint64(gas) + uint64(gas)*GAS_ESTIMATE_MARGIN/100, nil
+}
+
+// Send builds, signs and submits a transaction with the next pending nonce, and GasLimit or the estimated gas when it is 0
+func (client *Client) Send(ctx context.Context, to *common.Address, data []byte) (common.Hash, error) {
+	nonce, err := client.PendingNonce(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gasLimit := client.GasLimit
+	if gasLimit == 0 {
+		gasLimit, err = client.EstimateGas(ctx, to, data)
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+	tx, err := client.BuildTransaction(nonce, to, nil, gasLimit, data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return client.SendTransaction(ctx, tx)
+}
+
+// Receipt returns the receipt of a relay transaction, nil while it is pending
+func (client *Client) Receipt(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	var raw json.RawMessage
+	err := client.rpc.CallContext(ctx, &raw, "eth_getTransactionReceipt", hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	receipt := &Receipt{Receipt: new(types.Receipt)}
+	if err := json.Unmarshal(raw, receipt.Receipt); err != nil {
+		return nil, err
+	}
+	var reverted struct {
+		RevertReason *hexutil.Bytes `json:"revertReason"`
+	}
+	if err := json.Unmarshal(raw, &reverted); err != nil {
+		return nil, err
+	}
+	if reverted.RevertReason != nil {
+		receipt.RevertReason = *reverted.RevertReason
+	}
+	return receipt, nil
+}
+
+// WaitReceipt polls the receipt of a relay transaction until it is mined or ctx is done
+func (client *Client) WaitReceipt(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	ticker := time.NewTicker(client.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := client.Receipt(ctx, hash)
+		if err != nil || receipt != nil {
+			return receipt, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("receipt of %s not available: %w", hash.Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const testKey = "b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0"
+
+var relayHash = common.HexToHash("0x5e6b1e4e7e3e1f2f4f77b6d8b4a6e39e0d8e1b2f5d4f2a0c1b9e8d7c6b5a4f3e")
+
+var revertedReceipt = `{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001","blockNumber":"0x10","contractAddress":null,"cumulativeGasUsed":"0x5208","gasUsed":"0x5208","logs":[],"logsBloom":"0x` + strings.Repeat("0", 512) + `","status":"0x0","transactionHash":"0x5e6b1e4e7e3e1f2f4f77b6d8b4a6e39e0d8e1b2f5d4f2a0c1b9e8d7c6b5a4f3e","transactionIndex":"0x0","revertReason":"0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000c6e6f7420616c6c6f776564210000000000000000000000000000000000000000"}`
+
+// fakeRelay answers like a relay signer and keeps the raw transactions it receives
+type fakeRelay struct {
+	*httptest.Server
+	lock      sync.Mutex
+	sent      []string
+	receipts  int
+	estimates []map[string]string
+}
+
+func newFakeRelay() *fakeRelay {
+	relay := new(fakeRelay)
+	relay.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&message)
+
+		relay.lock.Lock()
+		defer relay.lock.Unlock()
+		var result string
+		switch message.Method {
+		case "eth_getTransactionCount":
+			result = `"0x7"`
+			if string(message.Params[1]) != `"pending"` {
+				result = `"0x0"`
+			}
+		case "eth_estimateGas":
+			var call map[string]string
+			_ = json.Unmarshal(message.Params[0], &call)
+			relay.estimates = append(relay.estimates, call)
+			result = `"0x186a0"`
+		case "eth_sendRawTransaction":
+			var raw string
+			_ = json.Unmarshal(message.Params[0], &raw)
+			relay.sent = append(relay.sent, raw)
+			result = `"` + relayHash.Hex() + `"`
+		case "eth_getTransactionReceipt":
+			relay.receipts++
+			result = "null"
+			if relay.receipts > 1 {
+				result = revertedReceipt
+			}
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(message.ID) + `,"result":` + result + `}`))
+	}))
+	return relay
+}
+
+func TestSend(t *testing.T) {
+	relay := newFakeRelay()
+	defer relay.Close()
+
+	key, _ := crypto.HexToECDSA(testKey)
+	nodeAddress := common.HexToAddress("0xd00e6624a73f88b39f82ab34e8bf2b4d226fd768")
+	client, err := Dial(relay.URL, key, nodeAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.ChainID = big.NewInt(648529)
+
+	to := common.HexToAddress("0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B")
+	before := time.Now()
+	hash, err := client.Send(context.Background(), &to, []byte{0xca, 0xfe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != relayHash {
+		t.Errorf("expected the relay transaction hash, got %s", hash.Hex())
+	}
+
+	if len(relay.sent) != 1 {
+		t.Fatalf("expected one raw transaction, got %d", len(relay.sent))
+	}
+	raw, _ := hexutil.Decode(relay.sent[0])
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		t.Fatal(err)
+	}
+	sender, err := types.Sender(types.NewEIP155Signer(client.ChainID), tx)
+	if err != nil || sender != client.From {
+		t.Errorf("unexpected sender %s %v", sender.Hex(), err)
+	}
+	if len(relay.estimates) != 1 || relay.estimates[0]["to"] != strings.ToLower(to.Hex()) || !strings.HasPrefix(relay.estimates[0]["data"], "0xcafe") {
+		t.Errorf("expected the gas of the payload to be estimated, got %v", relay.estimates)
+	}
+	if tx.Nonce() != 7 || tx.Gas() != 120000 || *tx.To() != to {
+		t.Errorf("unexpected transaction nonce %d gas %d to %s", tx.Nonce(), tx.Gas(), tx.To().Hex())
+	}
+
+	data := tx.Data()
+	if len(data) != 2+64 || hex.EncodeToString(data[:2]) != "cafe" {
+		t.Fatalf("unexpected payload %x", data)
+	}
+	if common.BytesToAddress(data[2:34]) != nodeAddress {
+		t.Errorf("unexpected node address in trailer %x", data[2:34])
+	}
+	expiration := new(big.Int).SetBytes(data[34:]).Int64()
+	if expiration < before.Add(DEFAULT_EXPIRATION).Unix() || expiration > time.Now().Add(DEFAULT_EXPIRATION).Unix() {
+		t.Errorf("unexpected expiration %d", expiration)
+	}
+
+	client.GasLimit = 50000
+	if _, err := client.Send(context.Background(), &to, []byte{0xca, 0xfe}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = hexutil.Decode(relay.sent[1])
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Gas() != 50000 || len(relay.estimates) != 1 {
+		t.Errorf("expected the gas limit set by the caller without estimating, got %d after %d estimates", tx.Gas(), len(relay.estimates))
+	}
+}
+
+func TestWaitReceipt(t *testing.T) {
+	relay := newFakeRelay()
+	defer relay.Close()
+
+	key, _ := crypto.HexToECDSA(testKey)
+	client, err := Dial(relay.URL, key, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.PollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	receipt, err := client.WaitReceipt(ctx, relayHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed || receipt.Reason() != "not allowed!" {
+		t.Errorf("unexpected receipt status %d reason %q", receipt.Status, receipt.Reason())
+	}
+	if relay.receipts != 2 {
+		t.Errorf("expected the pending receipt to be polled again, got %d queries", relay.receipts)
+	}
+}
+
+func TestDecodeRevertReason(t *testing.T) {
+	output, _ := hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000c" +
+		"6e6f7420616c6c6f776564210000000000000000000000000000000000000000")
+	if reason, ok := DecodeRevertReason(output); !ok || reason != "not allowed!" {
+		t.Errorf("unexpected revert reason %q", reason)
+	}
+
+	panicOutput, _ := hex.DecodeString("4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011")
+	if reason, ok := DecodeRevertReason(panicOutput); !ok || reason != "panic code 0x11" {
+		t.Errorf("unexpected panic reason %q", reason)
+	}
+
+	if _, ok := DecodeRevertReason([]byte{0x01}); ok {
+		t.Error("expected short output not to decode")
+	}
+}
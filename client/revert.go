@@ -0,0 +1,43 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	revertErrorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	revertPanicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// DecodeRevertReason returns the message of a revert output encoded as Error(string), or the code of a Panic(uint256)
+func DecodeRevertReason(output []byte) (string, bool) {
+	if len(output) < 4 {
+		return "", false
+	}
+	var typeName string
+	switch {
+	case bytes.Equal(output[:4], revertErrorSelector):
+		typeName = "string"
+	case bytes.Equal(output[:4], revertPanicSelector):
+		typeName = "uint256"
+	default:
+		return "", false
+	}
+
+	argumentType, err := abi.NewType(typeName, "", nil)
+	if err != nil {
+		return "", false
+	}
+	values, err := abi.Arguments{{Type: argumentType}}.UnpackValues(output[4:])
+	if err != nil || len(values) != 1 {
+		return "", false
+	}
+	if reason, ok := values[0].(string); ok {
+		return reason, true
+	}
+	return fmt.Sprintf("panic code 0x%x", values[0]), true
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// ENV_PREFIX prefixes the environment variables overriding configuration keys, e.g. RELAYSIGNER_APPLICATION_NODEURL
+const ENV_PREFIX = "RELAYSIGNER"
+
+// DEFAULT_CONFIG_NAME is the file name searched in the working directory when no path is given
+const DEFAULT_CONFIG_NAME = "config"
+
+// CONFIG_RELOAD_DELAY lets editors finish writing the file before it is read again
+const CONFIG_RELOAD_DELAY = 500 * time.Millisecond
+
+// Source is a configuration file plus its environment overrides
+type Source struct {
+	path string
+}
+
+// NewSource creates a source reading path, or config.toml (or any format viper supports) in the working directory when path is empty
+func NewSource(path string) *Source {
+	return &Source{path: path}
+}
+
+// Path returns the configuration file in use, known after the first Load
+func (source *Source) Path() string {
+	return source.path
+}
+
+// Load reads the file, applies the environment overrides and validates the result
+func (source *Source) Load() (*model.Config, error) {
+	v := viper.New()
+	if source.path != "" {
+		v.SetConfigFile(source.path)
+	} else {
+		v.SetConfigName(DEFAULT_CONFIG_NAME)
+		v.AddConfigPath(".")
+	}
+	v.SetEnvPrefix(ENV_PREFIX)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	bindEnv(v, reflect.TypeOf(model.Config{}), "")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.FailedReadFile.Wrapf(err, "couldn't load config", -32602)
+	}
+	source.path = v.ConfigFileUsed()
+
+	var c model.Config
+	err := v.Unmarshal(&c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		stringToAddressHook,
+	)))
+	if err != nil {
+		return nil, errors.InvalidConfig.Wrapf(err, "couldn't read config %s", -32602, source.path)
+	}
+
+	if err := Validate(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// bindEnv registers every key of the configuration so it can be overridden even when the file doesn't set it.
+// Lists of tables such as [[tiers]] have no single key and can only be set in the file.
+func bindEnv(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if field.Type.Kind() == reflect.Struct {
+			bindEnv(v, field.Type, key)
+			continue
+		}
+		v.BindEnv(key)
+	}
+}
+
+func stringToAddressHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(common.Address{}) {
+		return data, nil
+	}
+	return common.HexToAddress(data.(string)), nil
+}
+
+// Watch loads the configuration again on SIGHUP or when the file changes and hands it to apply, an invalid configuration is logged and ignored
+func (source *Source) Watch(done <-chan interface{}, apply func(*model.Config) error) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.ErrorLogger.Println("can't watch the configuration file, reload on SIGHUP only:", err)
+	} else {
+		defer watcher.Close()
+		// watch the directory so files replaced by rename or a mounted ConfigMap update are also detected
+		if err := watcher.Add(filepath.Dir(source.path)); err != nil {
+			log.ErrorLogger.Println("can't watch", filepath.Dir(source.path), ":", err)
+		}
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	file := filepath.Clean(source.path)
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-done:
+			log.GeneralLogger.Println("quit signal received...exiting from watching the configuration")
+			return
+		case <-hangup:
+			reload = time.After(0)
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(event.Name) == file || filepath.Base(event.Name) == "..data" {
+				reload = time.After(CONFIG_RELOAD_DELAY)
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			log.ErrorLogger.Println("configuration watcher error:", err)
+		case <-reload:
+			reload = nil
+			c, err := source.Load()
+			if err != nil {
+				log.ErrorLogger.Println("configuration reload failed, keeping the previous one:", err)
+				continue
+			}
+			if err := apply(c); err != nil {
+				log.ErrorLogger.Println("configuration reload failed, keeping the previous one:", err)
+				continue
+			}
+			log.GeneralLogger.Println("configuration reloaded from", source.path)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+const testConfig = `
+[application]
+nodeURL = "http://localhost:4545"
+contractAddress = "0x39Ec8898eAD9d5995858EC4eEfA47ccC9DDe9cf0"
+port = 9001
+
+[rateLimit]
+enabled = true
+ipRate = 20
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "relay.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvironmentOverrides(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	t.Setenv("RELAYSIGNER_APPLICATION_NODEURL", "http://besu:8545")
+	t.Setenv("RELAYSIGNER_RATELIMIT_IPRATE", "5")
+	t.Setenv("RELAYSIGNER_RPCPOLICY_DENY", "admin_*,debug_*")
+
+	source := NewSource(path)
+	c, err := source.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Path() != path {
+		t.Errorf("expected path %s, got %s", path, source.Path())
+	}
+	if c.Application.NodeURL != "http://besu:8545" {
+		t.Errorf("nodeURL not overridden: %s", c.Application.NodeURL)
+	}
+	if c.RateLimit.IPRate != 5 || !c.RateLimit.Enabled {
+		t.Errorf("unexpected rate limit %+v", c.RateLimit)
+	}
+	if !reflect.DeepEqual(c.RPCPolicy.Deny, []string{"admin_*", "debug_*"}) {
+		t.Errorf("key missing from the file not overridden: %v", c.RPCPolicy.Deny)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+[application]
+nodeURL = "localhost"
+contractAddress = "0x1"
+port = "http"
+
+[rateLimit]
+ipRate = -1
+
+[tls]
+enabled = true
+minVersion = "1.4"
+
+[queue]
+order = "lifo"
+
+[[tiers]]
+name = "critical"
+reservedShare = 1.5
+tenants = ["registry"]
+
+[[tiers]]
+name = "batch"
+tenants = ["registry"]
+
+[log]
+level = "verbose"
+`)
+	_, err := NewSource(path).Load()
+	if err == nil {
+		t.Fatal("expected an invalid configuration")
+	}
+	for _, key := range []string{"application.nodeURL", "application.contractAddress", "application.port", "rateLimit.ipRate", "tls.certFile", "tls.keyFile", "tls.minVersion", "queue.order", "tiers.reservedShare", "tiers.tenants", "log.level"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("%s not reported in %s", key, err)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	current := &model.Config{Application: model.ApplicationConfig{NodeURL: "http://localhost:4545", Key: "writer"}}
+	next := &model.Config{
+		Application: model.ApplicationConfig{NodeURL: "http://localhost:4545"},
+		RateLimit:   model.RateLimitConfig{Enabled: true, IPRate: 1},
+		Log:         model.LogConfig{Level: "error"},
+	}
+	if sections := RestartRequired(current, next); len(sections) != 0 {
+		t.Errorf("safe changes reported as needing a restart: %v", sections)
+	}
+
+	next.Application.Port = "9002"
+	next.Auth.Enabled = true
+	if sections := RestartRequired(current, next); !reflect.DeepEqual(sections, []string{"application", "auth"}) {
+		t.Errorf("unexpected sections %v", sections)
+	}
+}
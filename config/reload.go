@@ -0,0 +1,35 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+// RestartRequired lists the sections of next that differ from current in fields only read at startup,
+// everything but rate limits, the RPC method policy, API keys and JWT settings, privacy groups and the log level
+func RestartRequired(current, next *model.Config) []string {
+	a, b := startupFields(current), startupFields(next)
+	var sections []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			sections = append(sections, va.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return sections
+}
+
+func startupFields(c *model.Config) model.Config {
+	fields := *c
+	// set by the service at startup, never read from the file
+	fields.Application.Key = ""
+	fields.Application.RelayHubContractAddress = nil
+	fields.RateLimit = model.RateLimitConfig{}
+	fields.RPCPolicy = model.RPCPolicyConfig{}
+	fields.Auth = model.AuthConfig{Enabled: c.Auth.Enabled}
+	fields.Privacy.EnforceGroups = false
+	fields.Privacy.Groups = nil
+	fields.Log = model.LogConfig{}
+	return fields
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var tlsVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+var queueOrders = []string{"fifo", "priority"}
+
+// problems collects every invalid key so a single run reports all of them
+type problems []string
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) url(key, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		p.add(key, "%q is not a valid URL", value)
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	p.add(key, "scheme of %q must be one of %s", value, strings.Join(schemes, ", "))
+}
+
+func (p *problems) address(key, value string) {
+	if !common.IsHexAddress(value) {
+		p.add(key, "%q is not a hex address", value)
+	}
+}
+
+func (p *problems) file(key, value string) {
+	if _, err := os.Stat(value); err != nil {
+		p.add(key, "can't read %q: %s", value, err)
+	}
+}
+
+func (p *problems) notNegative(key string, value float64) {
+	if value < 0 {
+		p.add(key, "must not be negative, got %v", value)
+	}
+}
+
+func (p *problems) pairs(key string, values []string, format string) {
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			p.add(key, "entry %q must be %s", value, format)
+		}
+	}
+}
+
+// Validate checks the configuration and returns every problem found, prefixed with its key
+func Validate(c *model.Config) error {
+	var p problems
+
+	if c.Application.NodeURL == "" {
+		p.add("application.nodeURL", "is required")
+	} else {
+		p.url("application.nodeURL", c.Application.NodeURL, "http", "https")
+	}
+	if c.Application.WSURL != "" {
+		p.url("application.wsURL", c.Application.WSURL, "ws", "wss")
+	}
+	p.address("application.contractAddress", c.Application.ContractAddress)
+	if port, err := strconv.Atoi(c.Application.Port); err != nil || port < 1 || port > 65535 {
+		p.add("application.port", "%q is not a port number", c.Application.Port)
+	}
+
+	if c.Security.PermissionsEnabled {
+		p.address("security.accountContractAddress", c.Security.AccountContractAddress)
+	}
+	p.notNegative("security.permissionsCacheStaleness", float64(c.Security.PermissionsCacheStaleness))
+	p.notNegative("health.maxHeaderAge", float64(c.Health.MaxHeaderAge))
+	p.notNegative("admin.historySize", float64(c.Admin.HistorySize))
+
+	p.notNegative("rateLimit.ipRate", c.RateLimit.IPRate)
+	p.notNegative("rateLimit.ipBurst", float64(c.RateLimit.IPBurst))
+	p.notNegative("rateLimit.apiKeyRate", c.RateLimit.APIKeyRate)
+	p.notNegative("rateLimit.apiKeyBurst", float64(c.RateLimit.APIKeyBurst))
+	p.notNegative("rateLimit.senderRate", c.RateLimit.SenderRate)
+	p.notNegative("rateLimit.senderBurst", float64(c.RateLimit.SenderBurst))
+	p.notNegative("rateLimit.gasQuotaWindow", float64(c.RateLimit.GasQuotaWindow))
+
+	if c.Auth.Enabled {
+		if len(c.Auth.APIKeys) == 0 && c.Auth.JWTSecret == "" && c.Auth.JWKSFile == "" {
+			p.add("auth", "enabled without apiKeys, jwtSecret or jwksFile, no request could authenticate")
+		}
+		p.pairs("auth.apiKeys", c.Auth.APIKeys, "tenant:key")
+		if c.Auth.JWKSFile != "" {
+			p.file("auth.jwksFile", c.Auth.JWKSFile)
+		}
+	}
+
+	if c.TLS.Enabled {
+		for _, file := range [][2]string{{"tls.certFile", c.TLS.CertFile}, {"tls.keyFile", c.TLS.KeyFile}} {
+			if file[1] == "" {
+				p.add(file[0], "is required when TLS is enabled")
+			} else {
+				p.file(file[0], file[1])
+			}
+		}
+		if c.TLS.ClientCAFile != "" {
+			p.file("tls.clientCAFile", c.TLS.ClientCAFile)
+		} else if c.TLS.RequireClientCert {
+			p.add("tls.clientCAFile", "is required when requireClientCert is set")
+		}
+		if c.TLS.MinVersion != "" && !contains(tlsVersions, c.TLS.MinVersion) {
+			p.add("tls.minVersion", "%q must be one of %s", c.TLS.MinVersion, strings.Join(tlsVersions, ", "))
+		}
+	}
+
+	for _, upstream := range c.Proxy.Upstreams {
+		p.url("proxy.upstreams", upstream, "http", "https")
+	}
+	if len(c.Proxy.Priorities) > len(c.Proxy.Upstreams) {
+		p.add("proxy.priorities", "has %d entries for %d upstreams", len(c.Proxy.Priorities), len(c.Proxy.Upstreams))
+	}
+	p.notNegative("proxy.dialTimeout", float64(c.Proxy.DialTimeout))
+	p.notNegative("proxy.responseTimeout", float64(c.Proxy.ResponseTimeout))
+	p.notNegative("proxy.idleConnTimeout", float64(c.Proxy.IdleConnTimeout))
+	if c.Proxy.Retries != nil {
+		p.notNegative("proxy.retries", float64(*c.Proxy.Retries))
+	}
+	p.notNegative("proxy.healthCheckInterval", float64(c.Proxy.HealthCheckInterval))
+	p.notNegative("proxy.maxErrors", float64(c.Proxy.MaxErrors))
+
+	if c.Privacy.PrecompileAddress != "" {
+		p.address("privacy.precompileAddress", c.Privacy.PrecompileAddress)
+	}
+	if c.Privacy.FlexiblePrecompile != "" {
+		p.address("privacy.flexiblePrecompileAddress", c.Privacy.FlexiblePrecompile)
+	}
+	p.notNegative("privacy.accountingRetries", float64(c.Privacy.AccountingRetries))
+	p.notNegative("privacy.accountingRetryDelay", float64(c.Privacy.AccountingRetryDelay))
+	p.pairs("privacy.groups", c.Privacy.Groups, "tenant:groupId")
+
+	p.notNegative("deduplication.window", float64(c.Deduplication.Window))
+	p.notNegative("queue.maxDepth", float64(c.Queue.MaxDepth))
+	p.notNegative("queue.maxWait", float64(c.Queue.MaxWait))
+	if c.Queue.Order != "" && !contains(queueOrders, c.Queue.Order) {
+		p.add("queue.order", "%q must be one of %s", c.Queue.Order, strings.Join(queueOrders, ", "))
+	}
+
+	tiers := make(map[string]bool)
+	tenants := make(map[string]string)
+	var reserved float64
+	for _, tier := range c.Tiers {
+		if tier.Name == "" {
+			p.add("tiers.name", "is required")
+		} else if tiers[tier.Name] {
+			p.add("tiers.name", "%q is used by more than one tier", tier.Name)
+		}
+		tiers[tier.Name] = true
+		if tier.ReservedShare < 0 || tier.ReservedShare > 1 {
+			p.add("tiers.reservedShare", "of %q must be between 0 and 1, got %v", tier.Name, tier.ReservedShare)
+		}
+		reserved += tier.ReservedShare
+		for _, tenant := range tier.Tenants {
+			if other, ok := tenants[tenant]; ok {
+				p.add("tiers.tenants", "%q belongs to %q and %q", tenant, other, tier.Name)
+			}
+			tenants[tenant] = tier.Name
+		}
+	}
+	if reserved > 1 {
+		p.add("tiers.reservedShare", "reserved shares add up to %v, more than the whole allowance", reserved)
+	}
+
+	if !log.ValidLevel(c.Log.Level) {
+		p.add("log.level", "%q must be %s or %s", c.Log.Level, log.LEVEL_INFO, log.LEVEL_ERROR)
+	}
+
+	if len(p) > 0 {
+		return errors.InvalidConfig.New("invalid configuration:\n  "+strings.Join(p, "\n  "), -32602)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const BEARER_PREFIX = "Bearer "
+
+// AdminController exposes RelayHub and gas allowance state to operators
+type AdminController struct {
+	Config             *model.Config
+	RelaySignerService *service.RelaySignerService
+}
+
+// Init controller
+func (controller *AdminController) Init(config *model.Config, relaySignerService *service.RelaySignerService) {
+	controller.Config = config
+	controller.RelaySignerService = relaySignerService
+}
+
+// Status returns the node allowance, the gas used in the current block and the hub-wide limits
+func (controller *AdminController) Status(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	status, err := controller.RelaySignerService.GetRelayHubStatus()
+	if err != nil {
+		writeAdminError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeAdminResponse(w, status)
+}
+
+// History returns the recent relayed and rejected transactions, newest first
+func (controller *AdminController) History(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	writeAdminResponse(w, controller.RelaySignerService.GetRelayHistory())
+}
+
+// Limits returns the state of the rate limiters and the gas quotas
+func (controller *AdminController) Limits(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	writeAdminResponse(w, controller.RelaySignerService.GetLimiterState())
+}
+
+// Nodes returns the health of the upstream nodes used for reads
+func (controller *AdminController) Nodes(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	writeAdminResponse(w, controller.RelaySignerService.GetNodeStatus())
+}
+
+// Queue returns the depth and the counters of the admission queue, or the place of the raw transaction in the hash parameter
+func (controller *AdminController) Queue(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		writeAdminResponse(w, controller.RelaySignerService.GetQueueStatus())
+		return
+	}
+
+	transaction, queued := controller.RelaySignerService.GetQueuedTransaction(common.HexToHash(hash))
+	if !queued {
+		writeAdminError(w, http.StatusNotFound, errors.New("transaction is not queued"))
+		return
+	}
+	writeAdminResponse(w, transaction)
+}
+
+// Transactions returns the relay transaction of the raw transaction in the hash parameter, when it was relayed
+// within the deduplication window
+func (controller *AdminController) Transactions(w http.ResponseWriter, r *http.Request) {
+	if !controller.authorize(w, r) {
+		return
+	}
+
+	transaction, relayed := controller.RelaySignerService.GetRelayedTransaction(common.HexToHash(r.URL.Query().Get("hash")))
+	if !relayed {
+		writeAdminError(w, http.StatusNotFound, errors.New("transaction was not relayed within the deduplication window"))
+		return
+	}
+	writeAdminResponse(w, transaction)
+}
+
+func (controller *AdminController) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+
+	token := controller.Config.Admin.Token
+	header := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(header, BEARER_PREFIX) ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, BEARER_PREFIX)), []byte(token)) != 1 {
+		log.GeneralLogger.Println("Unauthorized admin request from", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func writeAdminResponse(w http.ResponseWriter, response interface{}) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func writeAdminError(w http.ResponseWriter, statusCode int, err error) {
+	log.ErrorLogger.Println(err)
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+const DEFAULT_AUTH_API_KEY_HEADER = "X-API-Key"
+
+type tenantContextKey struct{}
+
+// Authenticate rejects requests without a valid API key or JWT and stores the tenant in the request context
+func (controller *RelayController) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearerToken := ""
+		if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, BEARER_PREFIX) {
+			bearerToken = strings.TrimPrefix(authorization, BEARER_PREFIX)
+		}
+
+		tenant, err := controller.RelaySignerService.Authenticate(r.Header.Get(controller.authAPIKeyHeader()), bearerToken)
+		if err != nil {
+			log.GeneralLogger.Println("Unauthorized request from", controller.clientIP(r), ":", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(handleError(nil, err))
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant)))
+	}
+}
+
+// TenantFromRequest returns the tenant set by Authenticate, nil if the request is anonymous
+func TenantFromRequest(r *http.Request) *model.Tenant {
+	tenant, _ := r.Context().Value(tenantContextKey{}).(*model.Tenant)
+	return tenant
+}
+
+func (controller *RelayController) authAPIKeyHeader() string {
+	header := controller.RelaySignerService.CurrentConfig().Auth.APIKeyHeader
+	if header == "" {
+		return DEFAULT_AUTH_API_KEY_HEADER
+	}
+	return header
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+)
+
+// HealthController serves liveness and readiness probes for load balancers
+type HealthController struct {
+	Config             *model.Config
+	RelaySignerService *service.RelaySignerService
+}
+
+// Init controller
+func (controller *HealthController) Init(config *model.Config, relaySignerService *service.RelaySignerService) {
+	controller.Config = config
+	controller.RelaySignerService = relaySignerService
+}
+
+// Liveness answers 200 while the process is able to serve HTTP
+func (controller *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, http.StatusOK, controller.RelaySignerService.CheckLiveness())
+}
+
+// Readiness answers 200 only when the relay signer is able to relay transactions
+func (controller *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	status := controller.RelaySignerService.CheckReadiness()
+
+	statusCode := http.StatusOK
+	if status.Status != service.STATUS_READY {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeHealthStatus(w, statusCode, status)
+}
+
+func writeHealthStatus(w http.ResponseWriter, statusCode int, status *model.HealthStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.ErrorLogger.Println("Error trying to marshall a health status")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
@@ -0,0 +1,381 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	customErrors "github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/rpc"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const DISTRIBUTE_RAW_TRANSACTION = "priv_distributeRawTransaction"
+const FIND_PRIVACY_GROUP = "priv_findPrivacyGroup"
+const GET_EEA_TRANSACTION_COUNT = "priv_getEeaTransactionCount"
+
+// privacyGroupParams is the position of the privacy group id in the params of the priv_ methods scoped to a group
+var privacyGroupParams = map[string]int{
+	"priv_call":                0,
+	"priv_getCode":             0,
+	"priv_getLogs":             0,
+	"priv_newFilter":           0,
+	"priv_uninstallFilter":     0,
+	"priv_getFilterChanges":    0,
+	"priv_getFilterLogs":       0,
+	"priv_deletePrivacyGroup":  0,
+	"priv_debugGetStateRoot":   0,
+	"priv_getTransactionCount": 1,
+}
+
+// privacyMarkerParams are the priv_ methods taking the hash of a privacy marker transaction, scoped to the group of its private transaction
+var privacyMarkerParams = map[string]bool{
+	"priv_getTransactionReceipt": true,
+	"priv_getPrivateTransaction": true,
+}
+
+// groupFreeMethods are the priv_ methods that don't reveal the state of any privacy group, every other one is denied
+// when it can't be checked against the groups of the tenant
+var groupFreeMethods = map[string]bool{
+	"priv_getPrivacyPrecompileAddress": true,
+}
+
+// processPrivMethod checks the privacy group of priv_ requests before forwarding them to Besu
+func processPrivMethod(relaySignerService *service.RelaySignerService, proxy *ReverseProxy, rpcMessage rpc.JsonrpcMessage, tenant *model.Tenant, w http.ResponseWriter, r *http.Request) {
+	log.GeneralLogger.Println("Is a private method:", rpcMessage.Method)
+
+	switch rpcMessage.Method {
+	case DISTRIBUTE_RAW_TRANSACTION:
+		// the privacy marker transaction is sent later by the client as a public transaction, accounted as any other
+		_, err := verifyPrivateRawTransaction(relaySignerService, rpcMessage, tenant)
+		if err != nil {
+			data := handleError(rpcMessage.ID, err)
+			w.Write(data)
+			return
+		}
+	case FIND_PRIVACY_GROUP:
+		response := forwardBuffered(proxy, r, rpcMessage)
+		filterPrivacyGroups(relaySignerService, tenant, response)
+		response.writeTo(w)
+		return
+	default:
+		if groupFreeMethods[rpcMessage.Method] || !relaySignerService.PrivacyGroupsEnforced() {
+			break
+		}
+		group, found, err := privateMethodGroup(relaySignerService, rpcMessage)
+		if err != nil {
+			data := handleError(rpcMessage.ID, err)
+			w.Write(data)
+			return
+		}
+		if !found {
+			// the node doesn't know the transaction either
+			data, _ := json.Marshal(rpc.JsonrpcMessage{Version: "2.0", ID: rpcMessage.ID, Result: json.RawMessage("null")})
+			w.Write(data)
+			return
+		}
+		if !relaySignerService.PrivacyGroupAllowed(tenant, group) {
+			data := handleError(rpcMessage.ID, privacyGroupNotAllowed())
+			w.Write(data)
+			return
+		}
+	}
+
+	log.GeneralLogger.Println("forward to Besu->Orion")
+	forward(proxy, w, r, rpcMessage)
+}
+
+// privateMethodGroup returns the privacy group a priv_ request reads, found is false for a transaction unknown to the node
+func privateMethodGroup(relaySignerService *service.RelaySignerService, rpcMessage rpc.JsonrpcMessage) (group string, found bool, err error) {
+	var params []json.RawMessage
+	json.Unmarshal(rpcMessage.Params, &params)
+
+	if index, ok := privacyGroupParams[rpcMessage.Method]; ok {
+		if len(params) <= index || json.Unmarshal(params[index], &group) != nil {
+			return "", false, errors.New("privacy group id is missing")
+		}
+		return group, true, nil
+	}
+
+	if rpcMessage.Method == GET_EEA_TRANSACTION_COUNT {
+		// the legacy group Besu derives from privateFrom and privateFor
+		tx := new(model.PrivateTransaction)
+		if len(params) < 3 || json.Unmarshal(params[1], &tx.PrivateFrom) != nil || json.Unmarshal(params[2], &tx.PrivateFor) != nil {
+			return "", false, errors.New("privateFrom and privateFor are missing")
+		}
+		return service.PrivacyGroup(tx), true, nil
+	}
+
+	if privacyMarkerParams[rpcMessage.Method] {
+		var hash string
+		if len(params) == 0 || json.Unmarshal(params[0], &hash) != nil {
+			return "", false, errors.New("transaction hash is missing")
+		}
+		return relaySignerService.PrivateTransactionGroup(hash, rpcMessage.ID)
+	}
+
+	return "", false, customErrors.NotPermitted.New("method is not allowed when privacy groups are enforced", service.PRIVACY_GROUP_NOT_ALLOWED_ERROR_CODE)
+}
+
+func verifyPrivateRawTransaction(relaySignerService *service.RelaySignerService, rpcMessage rpc.JsonrpcMessage, tenant *model.Tenant) (*model.PrivateTransaction, error) {
+	var params []string
+	err := json.Unmarshal(rpcMessage.Params, &params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 || len(params[0]) < 2 {
+		return nil, errors.New("raw private transaction is missing")
+	}
+
+	tx, err := service.GetPrivateTransaction(params[0][2:])
+	if err != nil {
+		return nil, err
+	}
+
+	log.GeneralLogger.Println("Private transaction From:", tx.From.Hex(), "Privacy group:", service.PrivacyGroup(tx))
+
+	err = relaySignerService.VerifyPrivateTransaction(tx, tenant, rpcMessage.ID)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// filterPrivacyGroups removes the groups the tenant is not allowed to use from a priv_findPrivacyGroup response
+func filterPrivacyGroups(relaySignerService *service.RelaySignerService, tenant *model.Tenant, response *responseBuffer) {
+	var result rpc.JsonrpcMessage
+	if json.Unmarshal(response.body.Bytes(), &result) != nil || result.Result == nil {
+		return
+	}
+
+	var groups []map[string]interface{}
+	if json.Unmarshal(result.Result, &groups) != nil {
+		return
+	}
+
+	allowed := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		if id, ok := group["privacyGroupId"].(string); ok && relaySignerService.PrivacyGroupAllowed(tenant, id) {
+			allowed = append(allowed, group)
+		}
+	}
+
+	data, err := json.Marshal(allowed)
+	if err != nil {
+		return
+	}
+	result.Result = data
+	filtered, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	response.body.Reset()
+	response.body.Write(filtered)
+	response.header.Del("Content-Length")
+}
+
+func privacyGroupNotAllowed() error {
+	return customErrors.NotPermitted.New("privacy group is not allowed", service.PRIVACY_GROUP_NOT_ALLOWED_ERROR_CODE)
+}
+
+// processPrivateRawTransaction forwards an eea_ transaction and charges its privacy marker transaction gas once the node accepted it
+func processPrivateRawTransaction(relaySignerService *service.RelaySignerService, proxy *ReverseProxy, rpcMessage rpc.JsonrpcMessage, tenant *model.Tenant, w http.ResponseWriter, r *http.Request) {
+	log.GeneralLogger.Println("Is a private send Transaction")
+
+	tx, err := verifyPrivateRawTransaction(relaySignerService, rpcMessage, tenant)
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+
+	if relaySignerService.IsFlexiblePrivateTransaction(tx) {
+		processFlexiblePrivateTransaction(relaySignerService, proxy, rpcMessage, tx, tenant, w, r)
+		return
+	}
+
+	gasUsed, err := relaySignerService.EstimatePrivateTransactionGas()
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+
+	charge, err := relaySignerService.AllowSender(tx.From, gasUsed)
+	if err != nil {
+		writeLimitError(w, rpcMessage.ID, err)
+		return
+	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			relaySignerService.RefundSender(charge)
+		}
+	}()
+
+	relayHub := relaySignerService.RelayHubAddress()
+	lock.Lock()
+	isCorrectGasLimit, _, err := relaySignerService.VerifyGasLimit(relayHub, gasUsed, tenantID(tenant), rpcMessage.ID)
+	lock.Unlock()
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+	if !isCorrectGasLimit {
+		err := errors.New("transaction gas limit exceeds block gas limit")
+		relaySignerService.RecordRelay(model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: tx.From.Hex(), To: tx.To, Nonce: tx.Nonce, GasLimit: gasUsed, Error: err.Error()})
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+
+	log.GeneralLogger.Println("forward to Besu->Orion")
+	// the response is inspected before being returned, so it must not be compressed
+	response := forwardBuffered(proxy, r, rpcMessage)
+
+	var result rpc.JsonrpcMessage
+	if json.Unmarshal(response.body.Bytes(), &result) == nil && result.Error == nil && result.Result != nil {
+		accepted = true
+		record := model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: tx.From.Hex(), To: tx.To, Nonce: tx.Nonce, GasLimit: gasUsed}
+		json.Unmarshal(result.Result, &record.TransactionHash)
+		relaySignerService.RecordRelay(record)
+		relaySignerService.AccountPrivateTransaction(relayHub, record)
+	} else {
+		log.GeneralLogger.Println("private transaction was rejected by the node, no gas accounted")
+	}
+
+	response.writeTo(w)
+}
+
+// processFlexiblePrivateTransaction distributes a private transaction of a flexible privacy group and relays
+// its privacy marker transaction through the RelayHub instead of letting the node send it
+func processFlexiblePrivateTransaction(relaySignerService *service.RelaySignerService, proxy *ReverseProxy, rpcMessage rpc.JsonrpcMessage, tx *model.PrivateTransaction, tenant *model.Tenant, w http.ResponseWriter, r *http.Request) {
+	log.GeneralLogger.Println("Is a flexible privacy group transaction, relay its privacy marker transaction")
+
+	var metaTxGasLimit uint64 = uint64((service.ENCLAVE_KEY_SIZE*105)+300000) + tx.GasLimit
+
+	charge, err := relaySignerService.AllowSender(tx.From, metaTxGasLimit)
+	if err != nil {
+		writeLimitError(w, rpcMessage.ID, err)
+		return
+	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			relaySignerService.RefundSender(charge)
+		}
+	}()
+
+	// the relay lock is only held while reserving the gas and the RelayHub nonce, not across the node round trips
+	relayHub := relaySignerService.RelayHubAddress()
+	lock.Lock()
+	isCorrectGasLimit, _, err := relaySignerService.VerifyGasLimit(relayHub, metaTxGasLimit, tenantID(tenant), rpcMessage.ID)
+	lock.Unlock()
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+	if !isCorrectGasLimit {
+		err := errors.New("transaction gas limit exceeds block gas limit")
+		relaySignerService.RecordRelay(model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: tx.From.Hex(), To: tx.To, Nonce: tx.Nonce, GasLimit: metaTxGasLimit, Error: err.Error()})
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+
+	distribute := rpc.JsonrpcMessage{Version: rpcMessage.Version, ID: rpcMessage.ID, Method: DISTRIBUTE_RAW_TRANSACTION, Params: rpcMessage.Params}
+	body, err := json.Marshal(distribute)
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	response := forwardBuffered(proxy, r, distribute)
+
+	var result rpc.JsonrpcMessage
+	var enclaveKey hexutil.Bytes
+	if json.Unmarshal(response.body.Bytes(), &result) != nil || result.Error != nil || json.Unmarshal(result.Result, &enclaveKey) != nil {
+		log.GeneralLogger.Println("private transaction was not distributed, nothing relayed")
+		response.writeTo(w)
+		return
+	}
+
+	lock.Lock()
+	marker, err := relaySignerService.ReserveFlexiblePrivacyMarker(relayHub, enclaveKey, tx.GasLimit)
+	lock.Unlock()
+	if err != nil {
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
+
+	message := relaySignerService.SendFlexiblePrivacyMarker(rpcMessage.ID, marker, metaTxGasLimit)
+	record := model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: tx.From.Hex(), To: tx.To, Nonce: tx.Nonce, GasLimit: metaTxGasLimit}
+	if message.Error != nil {
+		lock.Lock()
+		relaySignerService.ReleaseFlexiblePrivacyMarker(marker)
+		lock.Unlock()
+		record.Error = message.Error.Error()
+	} else {
+		accepted = true
+		json.Unmarshal(message.Result, &record.TransactionHash)
+	}
+	relaySignerService.RecordRelay(record)
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		data = handleError(rpcMessage.ID, err)
+	}
+	w.Write(data)
+}
+
+// forwardBuffered proxies the request keeping the response to inspect it
+func forwardBuffered(proxy *ReverseProxy, r *http.Request, rpcMessage rpc.JsonrpcMessage) *responseBuffer {
+	// the response is inspected before being returned, so it must not be compressed
+	r.Header.Del("Accept-Encoding")
+	response := newResponseBuffer()
+	forward(proxy, response, r, rpcMessage)
+	return response
+}
+
+// responseBuffer keeps a proxied response so it can be inspected before being sent to the client
+type responseBuffer struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (response *responseBuffer) Header() http.Header {
+	return response.header
+}
+
+func (response *responseBuffer) Write(data []byte) (int, error) {
+	return response.body.Write(data)
+}
+
+func (response *responseBuffer) WriteHeader(statusCode int) {
+	response.statusCode = statusCode
+}
+
+func (response *responseBuffer) writeTo(w http.ResponseWriter) {
+	for key, values := range response.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.statusCode)
+	w.Write(response.body.Bytes())
+}
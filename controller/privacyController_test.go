@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/rpc"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const allowedGroup = "A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo="
+const otherGroup = "Ko2bVqD+nNlNYL5EE7y3IdOnviftjiizpjRt+HTuFBs="
+const allowedMarker = "0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc"
+const otherMarker = "0x2d3f8a6b1c4e5f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8"
+const enclaveKey = "0x5f8d0b8e7a0fc1b3b0c0f0c3d4c1a3b2b1c0d0e0f1a2b3c4d5e6f708192a3b4c"
+
+var getRelayHubSelector = hexutil.Encode(crypto.Keccak256([]byte("getRelayHub()"))[:4])
+
+// fakeBesu stands in for a Besu node with Tessera, answering the calls made by the relay signer
+type fakeBesu struct {
+	*httptest.Server
+	lock    sync.Mutex
+	methods []string
+	sent    []string
+}
+
+func newFakeBesu() *fakeBesu {
+	besu := new(fakeBesu)
+	besu.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message rpc.JsonrpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&message)
+
+		besu.lock.Lock()
+		besu.methods = append(besu.methods, message.Method)
+		if message.Method == "eth_sendRawTransaction" {
+			var params []string
+			_ = json.Unmarshal(message.Params, &params)
+			besu.sent = append(besu.sent, params...)
+		}
+		besu.lock.Unlock()
+
+		var result string
+		switch message.Method {
+		case "eth_call":
+			result = `"0x0000000000000000000000000000000000000000000000000000000000989680"`
+			if strings.Contains(string(message.Params), getRelayHubSelector) {
+				result = `"0x000000000000000000000000ff6d55d01fb12695ea00c071ad8af3ce44cf3a91"`
+			}
+		case "eth_chainId":
+			result = `"0x9e551"`
+		case "eth_estimateGas":
+			result = `"0x5408"`
+		case "eth_getTransactionCount":
+			result = `"0x6"`
+		case "eth_getCode":
+			result = `"0x6080"`
+		case "eth_sendRawTransaction", "eea_sendRawTransaction":
+			result = `"0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc"`
+		case "priv_distributeRawTransaction":
+			result = `"` + enclaveKey + `"`
+		case "priv_getPrivateTransaction":
+			result = "null"
+			if strings.Contains(string(message.Params), allowedMarker) {
+				result = `{"privateFrom":"A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo=","privacyGroupId":"` + allowedGroup + `"}`
+			} else if strings.Contains(string(message.Params), otherMarker) {
+				result = `{"privateFrom":"A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo=","privacyGroupId":"` + otherGroup + `"}`
+			}
+		case "priv_findPrivacyGroup":
+			result = `[{"privacyGroupId":"` + allowedGroup + `","type":"PANTHEON"},{"privacyGroupId":"` + otherGroup + `","type":"PANTHEON"}]`
+		default:
+			result = `"0x"`
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(message.ID) + `,"result":` + result + `}`))
+	}))
+	return besu
+}
+
+func (besu *fakeBesu) called(method string) int {
+	besu.lock.Lock()
+	defer besu.lock.Unlock()
+	count := 0
+	for _, called := range besu.methods {
+		if called == method {
+			count++
+		}
+	}
+	return count
+}
+
+// await waits for the background calls of method to reach count
+func (besu *fakeBesu) await(method string, count int) bool {
+	deadline := time.Now().Add(time.Second)
+	for besu.called(method) < count {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func signPrivateTransaction(t *testing.T, key *ecdsa.PrivateKey, chainID int64, privacyGroup string) string {
+	group, _ := base64.StdEncoding.DecodeString(privacyGroup)
+	privateFrom, _ := base64.StdEncoding.DecodeString("A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo=")
+	to := common.HexToAddress("0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1")
+
+	fields := []interface{}{uint64(1), big.NewInt(0), uint64(3000000), to.Bytes(), big.NewInt(0), []byte{0x60, 0x57}}
+	preimage := append(append([]interface{}{}, fields...), big.NewInt(chainID), uint(0), uint(0), privateFrom, group, []byte(model.RESTRICTED))
+	encoded, _ := rlp.EncodeToBytes(preimage)
+
+	signature, err := crypto.Sign(crypto.Keccak256(encoded), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := big.NewInt(chainID*2 + 35 + int64(signature[64]))
+	signed := append(fields, v, new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:64]), privateFrom, group, []byte(model.RESTRICTED))
+	raw, _ := rlp.EncodeToBytes(signed)
+	return hexutil.Encode(raw)
+}
+
+func newPrivacyController(t *testing.T, besu *fakeBesu, flexible bool) http.HandlerFunc {
+	t.Setenv("WRITER_KEY", "0xb3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0")
+
+	config := &model.Config{
+		Application: model.ApplicationConfig{NodeURL: besu.URL, ContractAddress: "0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B"},
+		Auth:        model.AuthConfig{Enabled: true, APIKeys: []string{"acme:acme-key"}},
+		Privacy:     model.PrivacyConfig{EnforceGroups: true, Groups: []string{"acme:" + allowedGroup}, AccountingRetryDelay: 1, FlexibleMetaTx: flexible},
+	}
+	relaySignerService := new(service.RelaySignerService)
+	if err := relaySignerService.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	controller := new(RelayController)
+	if err := controller.Init(config, relaySignerService); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan interface{})
+	go relaySignerService.ProcessPrivateAccounting(done)
+	t.Cleanup(func() { close(done) })
+	return controller.Authenticate(controller.SignTransaction)
+}
+
+func callRelay(handler http.HandlerFunc, method string, params string) string {
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":`+params+`}`))
+	request.Header.Set("X-API-Key", "acme-key")
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return strings.TrimSpace(recorder.Body.String())
+}
+
+func TestPrivateRawTransaction(t *testing.T) {
+	besu := newFakeBesu()
+	defer besu.Close()
+	handler := newPrivacyController(t, besu, false)
+
+	key, _ := crypto.GenerateKey()
+
+	tx, err := service.GetPrivateTransaction(signPrivateTransaction(t, key, 648529, allowedGroup)[2:])
+	if err != nil || tx.From != crypto.PubkeyToAddress(key.PublicKey) || service.PrivacyGroup(tx) != allowedGroup {
+		t.Fatalf("Sender and privacy group should be recovered from the private transaction, got %v %v", tx, err)
+	}
+
+	response := callRelay(handler, "eea_sendRawTransaction", `["`+signPrivateTransaction(t, key, 648529, allowedGroup)+`"]`)
+	if response != `{"jsonrpc":"2.0","id":1,"result":"0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc"}` {
+		t.Errorf("Private transaction to an allowed group should be forwarded, got %s", response)
+	}
+	if besu.called("eea_sendRawTransaction") != 1 || !besu.await("eth_sendRawTransaction", 1) {
+		t.Errorf("Private transaction should be forwarded and its gas accounted, got %v", besu.methods)
+	}
+
+	response = callRelay(handler, "eea_sendRawTransaction", `["`+signPrivateTransaction(t, key, 648529, otherGroup)+`"]`)
+	if response != `{"jsonrpc":"2.0","id":1,"error":{"code":-32613,"message":"privacy group is not allowed"}}` {
+		t.Errorf("Private transaction to another group should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_distributeRawTransaction", `["`+signPrivateTransaction(t, key, 648529, otherGroup)+`"]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_distributeRawTransaction") != 0 {
+		t.Errorf("Distribution to another group should be rejected, got %s", response)
+	}
+
+	if besu.called("eea_sendRawTransaction") != 1 || besu.called("eth_sendRawTransaction") != 1 {
+		t.Errorf("Rejected private transactions shouldn't reach the node, got %v", besu.methods)
+	}
+}
+
+func TestPrivacyGroupMethods(t *testing.T) {
+	besu := newFakeBesu()
+	defer besu.Close()
+	handler := newPrivacyController(t, besu, false)
+
+	response := callRelay(handler, "priv_findPrivacyGroup", `[["A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo="]]`)
+	if response != `{"jsonrpc":"2.0","id":1,"result":[{"privacyGroupId":"`+allowedGroup+`","type":"PANTHEON"}]}` {
+		t.Errorf("Groups of other tenants should be filtered, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_call", `["`+otherGroup+`",{"to":"0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1"},"latest"]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_call") != 0 {
+		t.Errorf("Calls to another group should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_call", `["`+allowedGroup+`",{"to":"0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1"},"latest"]`)
+	if response != `{"jsonrpc":"2.0","id":1,"result":"0x"}` {
+		t.Errorf("Calls to an allowed group should be forwarded, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_debugGetStateRoot", `["`+otherGroup+`","latest"]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_debugGetStateRoot") != 0 {
+		t.Errorf("State root of another group should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_getEeaTransactionCount", `["0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1","A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo=",["Ko2bVqD+nNlNYL5EE7y3IdOnviftjiizpjRt+HTuFBs="]]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_getEeaTransactionCount") != 0 {
+		t.Errorf("Transaction count of a legacy group not allowed should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_createPrivacyGroup", `[{"addresses":["A1aVtMxLCUHmBVHXoZzzBgPbW/wj5axDpW9X8l91SGo="]}]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_createPrivacyGroup") != 0 {
+		t.Errorf("Methods without a group check should be rejected, got %s", response)
+	}
+}
+
+func TestPrivateTransactionMethods(t *testing.T) {
+	besu := newFakeBesu()
+	defer besu.Close()
+	handler := newPrivacyController(t, besu, false)
+
+	response := callRelay(handler, "priv_getTransactionReceipt", `["`+otherMarker+`"]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_getTransactionReceipt") != 0 {
+		t.Errorf("Receipt of a transaction of another group should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_getPrivateTransaction", `["`+otherMarker+`"]`)
+	if !strings.Contains(response, "-32613") || besu.called("priv_getPrivateTransaction") != 2 {
+		t.Errorf("Private transaction of another group should be rejected, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_getTransactionReceipt", `["`+allowedMarker+`"]`)
+	if response != `{"jsonrpc":"2.0","id":1,"result":"0x"}` || besu.called("priv_getTransactionReceipt") != 1 {
+		t.Errorf("Receipt of a transaction of an allowed group should be forwarded, got %s", response)
+	}
+
+	response = callRelay(handler, "priv_getTransactionReceipt", `["0x1111111111111111111111111111111111111111111111111111111111111111"]`)
+	if response != `{"jsonrpc":"2.0","id":1,"result":null}` || besu.called("priv_getTransactionReceipt") != 1 {
+		t.Errorf("Receipt of an unknown transaction should be null, got %s", response)
+	}
+}
+
+func TestFlexiblePrivateTransaction(t *testing.T) {
+	besu := newFakeBesu()
+	defer besu.Close()
+	handler := newPrivacyController(t, besu, true)
+
+	key, _ := crypto.GenerateKey()
+
+	var response rpc.JsonrpcMessage
+	_ = json.Unmarshal([]byte(callRelay(handler, "eea_sendRawTransaction", `["`+signPrivateTransaction(t, key, 648529, allowedGroup)+`"]`)), &response)
+	if response.Error != nil || response.Result == nil {
+		t.Fatalf("Flexible privacy group transaction should be relayed, got %s", response.String())
+	}
+
+	if besu.called("priv_distributeRawTransaction") != 1 || besu.called("eea_sendRawTransaction") != 0 {
+		t.Errorf("Private transaction should only be distributed, got %v", besu.methods)
+	}
+	if besu.called("eth_sendRawTransaction") != 1 {
+		t.Fatalf("Privacy marker transaction should be relayed through the RelayHub, got %v", besu.methods)
+	}
+
+	var relayed types.Transaction
+	if err := rlp.DecodeBytes(hexutil.MustDecode(besu.sent[0]), &relayed); err != nil {
+		t.Fatal(err)
+	}
+	relayHubABI, _ := abi.JSON(strings.NewReader(relay.RelayABI))
+	args, err := relayHubABI.Methods["relayMetaTx"].Inputs.UnpackValues(relayed.Data()[4:])
+	if err != nil || *relayed.To() != common.HexToAddress("0xff6d55d01fb12695ea00c071ad8af3ce44cf3a91") {
+		t.Fatalf("Privacy marker transaction should be a relayMetaTx to the RelayHub, got %v %v", relayed.To(), err)
+	}
+	var marker model.RawTransaction
+	if err := rlp.DecodeBytes(args[1].([]byte), &marker.Data); err != nil {
+		t.Fatal(err)
+	}
+	if *marker.Data.Recipient != common.HexToAddress(service.DEFAULT_FLEXIBLE_PRIVACY_PRECOMPILE_ADDRESS) || hexutil.Encode(marker.Data.Payload) != enclaveKey {
+		t.Errorf("Privacy marker transaction should send the enclave key to the flexible privacy precompile, got %s %x", marker.Data.Recipient.Hex(), marker.Data.Payload)
+	}
+
+	response = rpc.JsonrpcMessage{}
+	_ = json.Unmarshal([]byte(callRelay(handler, "eea_sendRawTransaction", `["`+signPrivateTransaction(t, key, 648529, otherGroup)+`"]`)), &response)
+	if response.Error == nil || besu.called("priv_distributeRawTransaction") != 1 {
+		t.Errorf("Flexible transaction to another group should be rejected before distribution")
+	}
+}
@@ -7,14 +7,15 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/LACNetNetworks/gas-relay-signer/audit"
 	"github.com/LACNetNetworks/gas-relay-signer/model"
 	"github.com/LACNetNetworks/gas-relay-signer/rpc"
 	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/rlp"
 )
 
 const PENDING = "PENDING"
@@ -25,7 +26,7 @@ func processGetTransactionReceipt(relaySignerService *service.RelaySignerService
 	var params []string
 	err := json.Unmarshal(rpcMessage.Params, &params)
 	if err != nil {
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println(err)
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
@@ -34,7 +35,7 @@ func processGetTransactionReceipt(relaySignerService *service.RelaySignerService
 	response := relaySignerService.GetTransactionReceipt(rpcMessage.ID, params[0][2:])
 	data, err := json.Marshal(response)
 	if err != nil {
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println(err)
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
@@ -48,7 +49,7 @@ func processTransactionCount(relaySignerService *service.RelaySignerService, rpc
 	var params []string
 	err := json.Unmarshal(rpcMessage.Params, &params)
 	if err != nil {
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println(err)
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
@@ -56,24 +57,25 @@ func processTransactionCount(relaySignerService *service.RelaySignerService, rpc
 	}
 
 	var response *rpc.JsonrpcMessage
+	relayHub := relaySignerService.RelayHubAddress()
 
 	if len(params) > 1 {
 		if strings.ToUpper(params[1]) == PENDING {
-			response = relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], true)
+			response = relaySignerService.GetTransactionCount(rpcMessage.ID, relayHub, params[0], true)
 		} else if strings.ToUpper(params[1]) == LATEST {
-			response = relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], false)
+			response = relaySignerService.GetTransactionCount(rpcMessage.ID, relayHub, params[0], false)
 		} else {
 			err := errors.New("parameter not defined, only pending or latest are allowed")
 			data := handleError(rpcMessage.ID, err)
 			w.Write(data)
 		}
 	} else {
-		response = relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], false)
+		response = relaySignerService.GetTransactionCount(rpcMessage.ID, relayHub, params[0], false)
 	}
 
 	data, err := json.Marshal(response)
 	if err != nil {
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println(err)
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
@@ -82,7 +84,7 @@ func processTransactionCount(relaySignerService *service.RelaySignerService, rpc
 	w.Write(data)
 }
 
-func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMessage rpc.JsonrpcMessage, w http.ResponseWriter) {
+func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMessage rpc.JsonrpcMessage, tenant *model.Tenant, w http.ResponseWriter, req *http.Request) {
 	log.GeneralLogger.Println("Is a rawTransaction")
 	var params []string
 	err := json.Unmarshal(rpcMessage.Params, &params)
@@ -114,6 +116,22 @@ func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMe
 		return
 	}
 
+	relayHash, err := relaySignerService.ClaimTransaction(req.Context(), decodeTransaction.Hash())
+	if err != nil {
+		writeLimitError(w, rpcMessage.ID, err)
+		return
+	}
+	if relayHash != nil {
+		result := new(rpc.JsonrpcMessage)
+		result.ID = rpcMessage.ID
+		data, _ := json.Marshal(result.Response(relayHash))
+		w.Write(data)
+		return
+	}
+	defer func() {
+		relaySignerService.CompleteTransaction(decodeTransaction.Hash(), relayHash)
+	}()
+
 	if relaySignerService.Config.Security.PermissionsEnabled {
 		isSenderPermitted, err := relaySignerService.VerifySender(message.From(), rpcMessage.ID)
 		if err != nil {
@@ -127,20 +145,44 @@ func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMe
 			w.Write(data)
 			return
 		}
+
+		err = relaySignerService.VerifyTransaction(message.From(), decodeTransaction.To(), decodeTransaction.Value(), decodeTransaction.GasPrice(), decodeTransaction.Gas(), decodeTransaction.Data(), rpcMessage.ID)
+		if err != nil {
+			data := handleError(rpcMessage.ID, err)
+			w.Write(data)
+			return
+		}
 	}
 
-	var metaTxGasLimit uint64 = uint64((len(decodeTransaction.Data())*105)+300000) + decodeTransaction.Gas()
+	metaTxGasLimit := service.MetaTxGasLimit(decodeTransaction)
 
-	lock.Lock()
+	charge, err := relaySignerService.AllowSender(message.From(), metaTxGasLimit)
+	if err != nil {
+		writeLimitError(w, rpcMessage.ID, err)
+		return
+	}
+	defer func() {
+		if relayHash == nil {
+			relaySignerService.RefundSender(charge)
+		}
+	}()
+
+	// the transaction is checked and relayed against the same RelayHub even when it changes meanwhile
+	relayHub := relaySignerService.RelayHubAddress()
+	queued := model.QueuedTransaction{Hash: decodeTransaction.Hash(), Tenant: tenantID(tenant), From: message.From().Hex(), Nonce: decodeTransaction.Nonce(), GasLimit: metaTxGasLimit}
+	ticket, isCorrectGasLimit, err := verifyQueuedGasLimit(relaySignerService, relayHub, queued, rpcMessage.ID)
+	defer relaySignerService.LeaveQueue(ticket)
 	defer lock.Unlock()
-	isCorrectGasLimit, err := relaySignerService.VerifyGasLimit(metaTxGasLimit, rpcMessage.ID)
 	if err != nil {
-		data := handleError(rpcMessage.ID, err)
-		w.Write(data)
+		if _, ok := err.(*service.RateLimitError); ok {
+			relaySignerService.RecordRelay(model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: message.From().Hex(), To: decodeTransaction.To(), Nonce: decodeTransaction.Nonce(), GasLimit: metaTxGasLimit, Error: err.Error()})
+		}
+		writeLimitError(w, rpcMessage.ID, err)
 		return
 	}
 	if !isCorrectGasLimit {
 		err := errors.New("transaction gas limit exceeds block gas limit")
+		relaySignerService.RecordRelay(model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: message.From().Hex(), To: decodeTransaction.To(), Nonce: decodeTransaction.Nonce(), GasLimit: metaTxGasLimit, Error: err.Error()})
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
 		return
@@ -164,15 +206,7 @@ func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMe
 	copy(r[:], rBytes)
 	copy(s[:], sBytes)
 
-	var signingDataTx *model.RawTransaction
-
-	if decodeTransaction.To() != nil {
-		signingDataTx = model.NewTransaction(decodeTransaction.Nonce(), *decodeTransaction.To(), decodeTransaction.Value(), decodeTransaction.Gas(), decodeTransaction.GasPrice(), decodeTransaction.Data())
-	} else {
-		signingDataTx = model.NewContractCreation(decodeTransaction.Nonce(), decodeTransaction.Value(), decodeTransaction.Gas(), decodeTransaction.GasPrice(), decodeTransaction.Data())
-	}
-
-	signingDataRLP, err := rlp.EncodeToBytes(signingDataTx.Data)
+	signingDataRLP, err := service.SigningData(decodeTransaction)
 	if err != nil {
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
@@ -180,10 +214,19 @@ func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMe
 		return
 	}
 
-	response := relaySignerService.SendMetatransaction(rpcMessage.ID, decodeTransaction.To(), metaTxGasLimit, signingDataRLP, uint8(v.Uint64()), r, s, message.From().Hex(), decodeTransaction.Nonce())
+	response := relaySignerService.SendMetatransaction(rpcMessage.ID, relayHub, decodeTransaction.To(), metaTxGasLimit, signingDataRLP, uint8(v.Uint64()), r, s, message.From().Hex(), decodeTransaction.Nonce())
+	record := model.RelayRecord{Time: time.Now(), Tenant: tenantID(tenant), From: message.From().Hex(), To: decodeTransaction.To(), Nonce: decodeTransaction.Nonce(), GasLimit: metaTxGasLimit}
+	if response.Error != nil {
+		record.Error = response.Error.Error()
+	} else {
+		json.Unmarshal(response.Result, &record.TransactionHash)
+		relayHash = new(common.Hash)
+		json.Unmarshal(response.Result, relayHash)
+	}
+	relaySignerService.RecordRelay(record)
 	data, err := json.Marshal(response)
 	if err != nil {
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println(err)
 		err := errors.New("internal error")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
@@ -191,3 +234,44 @@ func processRawTransaction(relaySignerService *service.RelaySignerService, rpcMe
 	}
 	w.Write(data)
 }
+
+// verifyQueuedGasLimit verifies the gas limit of transaction against the allowance of relayHub left in the current block
+// for the tier of its tenant, the lock is held when it returns. With the admission queue enabled, a transaction that
+// doesn't fit, or that may not pass the queued ones, enters the queue and waits for its turn and, when it still doesn't
+// fit, for the next block. The ticket is nil unless the transaction entered the queue.
+func verifyQueuedGasLimit(relaySignerService *service.RelaySignerService, relayHub common.Address, transaction model.QueuedTransaction, id json.RawMessage) (*service.QueueTicket, bool, error) {
+	lock.Lock()
+	if !relaySignerService.QueueWaiting(transaction.Tenant) {
+		isCorrectGasLimit, nodeGasLimit, err := relaySignerService.VerifyGasLimit(relayHub, transaction.GasLimit, transaction.Tenant, id)
+		if err != nil || isCorrectGasLimit || !relaySignerService.CanQueue(transaction.Tenant, transaction.GasLimit, nodeGasLimit) {
+			return nil, isCorrectGasLimit, err
+		}
+	}
+	lock.Unlock()
+
+	ticket, err := relaySignerService.EnterQueue(transaction)
+	if err != nil {
+		lock.Lock()
+		return nil, false, err
+	}
+	for {
+		err := relaySignerService.AwaitTurn(ticket)
+		lock.Lock()
+		if err != nil {
+			return ticket, false, err
+		}
+
+		isCorrectGasLimit, nodeGasLimit, err := relaySignerService.VerifyGasLimit(relayHub, transaction.GasLimit, transaction.Tenant, id)
+		if err != nil || isCorrectGasLimit || !relaySignerService.DeferToNextBlock(ticket, transaction.GasLimit, nodeGasLimit) {
+			return ticket, isCorrectGasLimit, err
+		}
+		lock.Unlock()
+	}
+}
+
+func tenantID(tenant *model.Tenant) string {
+	if tenant == nil {
+		return ""
+	}
+	return tenant.ID
+}
@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/rpc"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/LACNetNetworks/gas-relay-signer/service/servicetest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var destination = common.HexToAddress("0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1")
+
+// newFakeChainController returns the raw transaction handler of a relay on chain, configure may be nil
+func newFakeChainController(t *testing.T, chain *servicetest.FakeChainBackend, configure func(config *model.Config)) (http.HandlerFunc, *service.RelaySignerService) {
+	relaySignerService, config := servicetest.NewService(t, chain, func(config *model.Config) {
+		config.Security = model.SecurityConfig{PermissionsEnabled: true, AccountContractAddress: "0x8d2e4b8d3ea0bc1e4ba3f4a1e7d2a4c0e1b46a20"}
+		if configure != nil {
+			configure(config)
+		}
+	})
+	controller := new(RelayController)
+	if err := controller.Init(config, relaySignerService); err != nil {
+		t.Fatal(err)
+	}
+	return controller.SignTransaction, relaySignerService
+}
+
+func signRawTransaction(t *testing.T, key *ecdsa.PrivateKey, nonce uint64) string {
+	tx, err := types.SignTx(types.NewTransaction(nonce, destination, big.NewInt(0), 100000, big.NewInt(0), []byte{0xca, 0xfe}), types.HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := rlp.EncodeToBytes(tx)
+	return hexutil.Encode(raw)
+}
+
+func callFakeChain(t *testing.T, handler http.HandlerFunc, method string, params string) rpc.JsonrpcMessage {
+	var response rpc.JsonrpcMessage
+	if err := json.Unmarshal([]byte(callRelay(handler, method, params)), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestProcessRawTransaction(t *testing.T) {
+	tests := []struct {
+		name         string
+		permitted    bool
+		nodeGasLimit uint64
+		err          error
+		wantError    string
+	}{
+		{name: "relays permitted sender", permitted: true, nodeGasLimit: 1 << 62},
+		{name: "rejects sender not permitted", permitted: false, nodeGasLimit: 1 << 62, wantError: "account sender is not permitted to send transactions"},
+		{name: "rejects when node allowance is used", permitted: true, nodeGasLimit: 1, wantError: "transaction gas limit exceeds block gas limit"},
+		{name: "returns chain failures", permitted: true, nodeGasLimit: 1 << 62, err: errors.New("node unavailable"), wantError: "node unavailable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := servicetest.NewFakeChainBackend(test.nodeGasLimit)
+			handler, _ := newFakeChainController(t, chain, nil)
+			chain.Err = test.err
+
+			key, _ := crypto.GenerateKey()
+			if test.permitted {
+				chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+			}
+
+			response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+signRawTransaction(t, key, 0)+`"]`)
+			if test.wantError != "" {
+				if response.Error == nil || !strings.Contains(response.Error.Error(), test.wantError) {
+					t.Errorf("Transaction should be rejected with %q, got %s", test.wantError, response.String())
+				}
+				if len(chain.Relayed()) != 0 {
+					t.Errorf("Rejected transaction shouldn't be relayed, got %v", chain.Relayed())
+				}
+				return
+			}
+
+			var hash common.Hash
+			if response.Error != nil || json.Unmarshal(response.Result, &hash) != nil {
+				t.Fatalf("Transaction should be relayed, got %s", response.String())
+			}
+			if relayed := chain.Relayed(); len(relayed) != 1 || relayed[0] != hash {
+				t.Errorf("Relay transaction hash should be returned, got %s want %v", hash.Hex(), relayed)
+			}
+		})
+	}
+}
+
+func TestRejectedTransactionKeepsSenderQuota(t *testing.T) {
+	chain := servicetest.NewFakeChainBackend(1)
+	key, _ := crypto.GenerateKey()
+	chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+	handler, relaySignerService := newFakeChainController(t, chain, func(config *model.Config) {
+		config.RateLimit = model.RateLimitConfig{Enabled: true, SenderRate: 0.001, SenderBurst: 1, SenderGasQuota: 1 << 40, GasQuotaWindow: 60}
+	})
+
+	for i := 0; i < 2; i++ {
+		response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+signRawTransaction(t, key, 0)+`"]`)
+		if response.Error == nil || !strings.Contains(response.Error.Error(), "transaction gas limit exceeds block gas limit") {
+			t.Fatalf("Transaction should be rejected by the node allowance and not by the sender limit, got %s", response.String())
+		}
+	}
+	if used := relaySignerService.GetLimiterState().SenderGasUsed[crypto.PubkeyToAddress(key.PublicKey).Hex()]; used != 0 {
+		t.Errorf("Rejected transactions shouldn't use the sender gas quota, got %d", used)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	chain := servicetest.NewFakeChainBackend(1 << 62)
+	key, _ := crypto.GenerateKey()
+	handler, relaySignerService := newFakeChainController(t, chain, func(config *model.Config) {
+		config.Deduplication = model.DeduplicationConfig{Enabled: true}
+	})
+	raw := signRawTransaction(t, key, 0)
+	tx, _ := service.GetTransaction(raw[2:])
+
+	// a rejected submission is relayed again once the sender is permitted
+	if response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw+`"]`); response.Error == nil {
+		t.Fatalf("Transaction of a sender not permitted should be rejected, got %s", response.String())
+	}
+	chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+
+	var hashes [2]common.Hash
+	for i := range hashes {
+		response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw+`"]`)
+		if response.Error != nil || json.Unmarshal(response.Result, &hashes[i]) != nil {
+			t.Fatalf("Transaction should be relayed, got %s", response.String())
+		}
+	}
+	if relayed := chain.Relayed(); len(relayed) != 1 || hashes[0] != relayed[0] || hashes[1] != relayed[0] {
+		t.Errorf("Resubmitted transaction should return the original relay transaction, got %v want %v", hashes, relayed)
+	}
+	if transaction, ok := relaySignerService.GetRelayedTransaction(tx.Hash()); !ok || transaction.RelayHash != hashes[0] || transaction.Duplicates != 1 {
+		t.Errorf("Raw transaction should map to its relay transaction, got %+v", transaction)
+	}
+}
+
+func TestDeduplicationCancelledRetry(t *testing.T) {
+	chain := servicetest.NewFakeChainBackend(1 << 62)
+	key, _ := crypto.GenerateKey()
+	chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+	handler, relaySignerService := newFakeChainController(t, chain, func(config *model.Config) {
+		config.Deduplication = model.DeduplicationConfig{Enabled: true}
+	})
+	raw := signRawTransaction(t, key, 0)
+	tx, _ := service.GetTransaction(raw[2:])
+
+	// another submission of the same raw transaction is being relayed
+	if claimed, err := relaySignerService.ClaimTransaction(context.Background(), tx.Hash()); claimed != nil || err != nil {
+		t.Fatalf("First submission should be relayed, got %v %v", claimed, err)
+	}
+
+	// the client of the retry gives up while it waits for the first submission
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["`+raw+`"]}`)).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	var response rpc.JsonrpcMessage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.ErrorCode() != service.RATE_LIMIT_ERROR_CODE || recorder.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Cancelled retry should get a retryable error, got %s %v", response.String(), recorder.Header())
+	}
+
+	relaySignerService.CompleteTransaction(tx.Hash(), nil)
+	if response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw+`"]`); response.Error != nil || len(chain.Relayed()) != 1 {
+		t.Errorf("Retry should be relayed once the first submission is rejected, got %s", response.String())
+	}
+}
+
+func TestRelayedTransactionQueries(t *testing.T) {
+	chain := servicetest.NewFakeChainBackend(1 << 62)
+	handler, _ := newFakeChainController(t, chain, nil)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	chain.Permit(sender)
+
+	response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+signRawTransaction(t, key, 0)+`"]`)
+	var relayed common.Hash
+	_ = json.Unmarshal(response.Result, &relayed)
+
+	chain.Reverts[destination] = []byte{0x08, 0xc3, 0x79, 0xa0}
+	response = callFakeChain(t, handler, "eth_sendRawTransaction", `["`+signRawTransaction(t, key, 1)+`"]`)
+	var reverted common.Hash
+	_ = json.Unmarshal(response.Result, &reverted)
+
+	tests := []struct {
+		name   string
+		method string
+		params string
+		want   string
+	}{
+		{name: "counts relayed transactions", method: "eth_getTransactionCount", params: `["` + sender.Hex() + `","latest"]`, want: `"0x2"`},
+		{name: "counts other senders", method: "eth_getTransactionCount", params: `["` + destination.Hex() + `","latest"]`, want: `"0x0"`},
+		{name: "returns receipt", method: "eth_getTransactionReceipt", params: `["` + relayed.Hex() + `"]`, want: `"status":"0x1"`},
+		{name: "returns revert reason", method: "eth_getTransactionReceipt", params: `["` + reverted.Hex() + `"]`, want: `"revertReason":"0x08c379a0"`},
+		{name: "returns null while pending", method: "eth_getTransactionReceipt", params: `["` + common.Hash{}.Hex() + `"]`, want: `null`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := callFakeChain(t, handler, test.method, test.params)
+			if response.Error != nil || !strings.Contains(string(response.Result), test.want) {
+				t.Errorf("%s should return %s, got %s", test.method, test.want, response.String())
+			}
+		})
+	}
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	raw := []string{signRawTransaction(t, key, 0), signRawTransaction(t, key, 1), signRawTransaction(t, key, 2)}
+	tx, _ := service.GetTransaction(raw[0][2:])
+	metaTxGasLimit := service.MetaTxGasLimit(tx)
+
+	// the node allowance fits one transaction per block
+	chain := servicetest.NewFakeChainBackend(metaTxGasLimit + metaTxGasLimit/2)
+	chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+	handler, relaySignerService := newFakeChainController(t, chain, func(config *model.Config) {
+		config.Queue = model.QueueConfig{Enabled: true, MaxDepth: 1, MaxWait: 1}
+	})
+	relaySignerService.NewBlock(&types.Header{Number: big.NewInt(1)})
+
+	if response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw[0]+`"]`); response.Error != nil {
+		t.Fatalf("First transaction should be relayed, got %s", response.String())
+	}
+
+	queued := make(chan rpc.JsonrpcMessage)
+	go func() {
+		queued <- callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw[1]+`"]`)
+	}()
+	waitQueueDepth(t, relaySignerService, 1)
+
+	second, _ := service.GetTransaction(raw[1][2:])
+	if transaction, ok := relaySignerService.GetQueuedTransaction(second.Hash()); !ok || transaction.Position != 1 || transaction.Nonce != 1 {
+		t.Errorf("Transaction exceeding the allowance left should be queued, got %v", transaction)
+	}
+	if response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw[2]+`"]`); response.Error == nil || response.Error.ErrorCode() != service.RATE_LIMIT_ERROR_CODE || !strings.Contains(response.Error.Error(), "admission queue is full") {
+		t.Errorf("Transaction should be rejected by a full queue, got %s", response.String())
+	}
+
+	relaySignerService.NewBlock(&types.Header{Number: big.NewInt(2)})
+	response := <-queued
+	var hash common.Hash
+	if response.Error != nil || json.Unmarshal(response.Result, &hash) != nil {
+		t.Fatalf("Queued transaction should be relayed in the next block, got %s", response.String())
+	}
+	if relayed := chain.Relayed(); len(relayed) != 2 || relayed[1] != hash {
+		t.Errorf("Relay transaction hash should be returned, got %s want %v", hash.Hex(), relayed)
+	}
+
+	go func() {
+		queued <- callFakeChain(t, handler, "eth_sendRawTransaction", `["`+raw[2]+`"]`)
+	}()
+	if response := <-queued; response.Error == nil || !strings.Contains(response.Error.Error(), "waited more than 1s in the admission queue") {
+		t.Errorf("Transaction should be rejected after the max wait, got %s", response.String())
+	}
+
+	status := relaySignerService.GetQueueStatus()
+	if status.Depth != 0 || status.Admitted != 1 || status.Delayed != 1 || status.Expired != 1 || status.Rejected != 1 || status.HighestDepth != 1 {
+		t.Errorf("Queue counters don't match, got %+v", status)
+	}
+}
+
+func TestAdmissionQueueRejectsOversizedTransaction(t *testing.T) {
+	chain := servicetest.NewFakeChainBackend(1)
+	key, _ := crypto.GenerateKey()
+	chain.Permit(crypto.PubkeyToAddress(key.PublicKey))
+	handler, relaySignerService := newFakeChainController(t, chain, func(config *model.Config) {
+		config.Queue = model.QueueConfig{Enabled: true}
+	})
+	relaySignerService.NewBlock(&types.Header{Number: big.NewInt(1)})
+
+	response := callFakeChain(t, handler, "eth_sendRawTransaction", `["`+signRawTransaction(t, key, 0)+`"]`)
+	if response.Error == nil || !strings.Contains(response.Error.Error(), "transaction gas limit exceeds block gas limit") {
+		t.Errorf("Transaction larger than the allowance of a block shouldn't be queued, got %s", response.String())
+	}
+	if status := relaySignerService.GetQueueStatus(); status.Depth != 0 {
+		t.Errorf("Rejected transaction should leave the queue, got %+v", status)
+	}
+}
+
+func waitQueueDepth(t *testing.T, relaySignerService *service.RelaySignerService, depth int) {
+	deadline := time.Now().Add(time.Second)
+	for relaySignerService.GetQueueStatus().Depth != depth {
+		if time.Now().After(deadline) {
+			t.Fatalf("Queue depth should be %d, got %+v", depth, relaySignerService.GetQueueStatus())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
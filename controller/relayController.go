@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 
 	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	customErrors "github.com/LACNetNetworks/gas-relay-signer/errors"
 	"github.com/LACNetNetworks/gas-relay-signer/model"
 	"github.com/LACNetNetworks/gas-relay-signer/rpc"
 	"github.com/LACNetNetworks/gas-relay-signer/service"
@@ -18,17 +21,28 @@ import (
 
 var lock sync.Mutex
 
+const METHOD_NOT_ALLOWED_ERROR_CODE = -32601
+
 // RelayController is the main controller
 type RelayController struct {
 	// The controller's configuration
 	Config             *model.Config
 	RelaySignerService *service.RelaySignerService
+	Proxy              *ReverseProxy
 }
 
 // Init controller
-func (controller *RelayController) Init(config *model.Config, relaySignerService *service.RelaySignerService) {
+func (controller *RelayController) Init(config *model.Config, relaySignerService *service.RelaySignerService) error {
 	controller.Config = config
 	controller.RelaySignerService = relaySignerService
+
+	proxy, err := NewReverseProxy(config.Proxy, config.Application.NodeURL)
+	if err != nil {
+		return err
+	}
+	controller.Proxy = proxy
+	relaySignerService.SetReaders(proxy)
+	return nil
 }
 
 // SignTransaction ...
@@ -50,28 +64,40 @@ func (controller *RelayController) SignTransaction(w http.ResponseWriter, r *htt
 
 	err = json.NewDecoder(rdr1).Decode(&rpcMessage)
 	if err != nil {
-		log.GeneralLogger.Println("Invalid params")
-		log.GeneralLogger.Println(err)
+		log.ErrorLogger.Println("Invalid params")
+		log.ErrorLogger.Println(err)
+		return
+	}
+
+	tenant := TenantFromRequest(r)
+	if tenant != nil {
+		log.GeneralLogger.Println("JSON-RPC Method:", rpcMessage.Method, "Tenant:", tenant.ID)
+	} else {
+		log.GeneralLogger.Println("JSON-RPC Method:", rpcMessage.Method)
+	}
+
+	err = controller.RelaySignerService.AllowRequest(controller.clientIP(r), controller.quotaKey(r, tenant))
+	if err != nil {
+		writeLimitError(w, rpcMessage.ID, err)
 		return
 	}
 
-	log.GeneralLogger.Println("JSON-RPC Method:", rpcMessage.Method)
+	if !controller.methodAllowed(&rpcMessage) {
+		log.GeneralLogger.Println("Method rejected by policy:", rpcMessage.Method)
+		err := customErrors.NotPermitted.New("method is not allowed", METHOD_NOT_ALLOWED_ERROR_CODE)
+		data := handleError(rpcMessage.ID, err)
+		w.Write(data)
+		return
+	}
 
 	if rpcMessage.IsPrivTransaction() {
 		r.Body = rdr2
-		log.GeneralLogger.Println("Is a private Transaction, forward to Besu->Orion")
-
-		serveReverseProxy(controller.Config.Application.NodeURL, w, r)
+		processPrivMethod(controller.RelaySignerService, controller.Proxy, rpcMessage, tenant, w, r)
 	} else if rpcMessage.IsPrivRawTransaction() {
 		r.Body = rdr2
-		log.GeneralLogger.Println("Is a private send Transaction, decrease gas used")
-
-		controller.RelaySignerService.DecreaseGasUsed(rpcMessage.ID)
-
-		log.GeneralLogger.Println("forward to Besu->Orion")
-		serveReverseProxy(controller.Config.Application.NodeURL, w, r)
+		processPrivateRawTransaction(controller.RelaySignerService, controller.Proxy, rpcMessage, tenant, w, r)
 	} else if rpcMessage.IsRawTransaction() {
-		processRawTransaction(controller.RelaySignerService, rpcMessage, w)
+		processRawTransaction(controller.RelaySignerService, rpcMessage, tenant, w, r)
 		return
 	} else if rpcMessage.IsGetTransactionReceipt() {
 		processGetTransactionReceipt(controller.RelaySignerService, rpcMessage, w)
@@ -79,40 +105,69 @@ func (controller *RelayController) SignTransaction(w http.ResponseWriter, r *htt
 	} else if rpcMessage.IsGetTransactionCount() {
 		processTransactionCount(controller.RelaySignerService, rpcMessage, w)
 		return
+	} else if rpcMessage.MatchesMethod(controller.RelaySignerService.CurrentConfig().RPCPolicy.Forward) {
+		r.Body = rdr2
+		log.GeneralLogger.Println("forward to Besu")
+		forward(controller.Proxy, w, r, rpcMessage)
 	} else {
-		//	r.Body=rdr2
 		err := errors.New("method is not supported")
 		data := handleError(rpcMessage.ID, err)
 		w.Write(data)
 		return
-		//	serveReverseProxy(controller.Config.Application.NodeURL,w,r)
 	}
 }
 
-func serveReverseProxy(target string, res http.ResponseWriter, req *http.Request) {
-	// parse the url
-	url, err := url.Parse(target)
+// methodAllowed applies the deny list first, then the allow list when it is not empty
+func (controller *RelayController) methodAllowed(rpcMessage *rpc.JsonrpcMessage) bool {
+	policy := controller.RelaySignerService.CurrentConfig().RPCPolicy
+	if rpcMessage.MatchesMethod(policy.Deny) {
+		return false
+	}
+	return len(policy.Allow) == 0 || rpcMessage.MatchesMethod(policy.Allow)
+}
+
+func (controller *RelayController) clientIP(r *http.Request) string {
+	if controller.RelaySignerService.CurrentConfig().RateLimit.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		handleError(nil, err)
+		return r.RemoteAddr
 	}
-	// create the reverse proxy
-	proxy := httputil.NewSingleHostReverseProxy(url)
+	return host
+}
 
-	// Update the headers to allow for SSL redirection
-	req.URL.Host = url.Host
-	req.URL.Scheme = url.Scheme
-	req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
-	req.Host = url.Host
+// quotaKey is the key of the API key rate limit, the tenant when the request is authenticated
+func (controller *RelayController) quotaKey(r *http.Request, tenant *model.Tenant) string {
+	if tenant != nil {
+		return "tenant:" + tenant.ID
+	}
+	return r.Header.Get(controller.apiKeyHeader())
+}
 
-	// Note that ServeHttp is non blocking and uses a go routine under the hood
-	proxy.ServeHTTP(res, req)
+func (controller *RelayController) apiKeyHeader() string {
+	header := controller.RelaySignerService.CurrentConfig().RateLimit.APIKeyHeader
+	if header == "" {
+		return "X-API-Key"
+	}
+	return header
+}
+
+func writeLimitError(w http.ResponseWriter, messageID json.RawMessage, err error) {
+	if limitErr, ok := err.(*service.RateLimitError); ok {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(limitErr.RetryAfter.Seconds())), 10))
+	}
+	data := handleError(messageID, err)
+	w.Write(data)
 }
 
 func handleError(messageID json.RawMessage, err error) []byte {
 	//	log.GeneralLogger.Println(err)
 	data, err := json.Marshal(service.HandleError(messageID, err))
 	if err != nil {
-		log.GeneralLogger.Println("Error trying to marshall a response to client")
+		log.ErrorLogger.Println("Error trying to marshall a response to client")
 	}
 
 	return data
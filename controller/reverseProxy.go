@@ -0,0 +1,391 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	customErrors "github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const DEFAULT_PROXY_DIAL_TIMEOUT int64 = 5
+const DEFAULT_PROXY_RESPONSE_TIMEOUT int64 = 30
+const DEFAULT_PROXY_IDLE_CONN_TIMEOUT int64 = 90
+const DEFAULT_PROXY_MAX_IDLE_CONNS = 100
+const DEFAULT_PROXY_HEALTH_CHECK_INTERVAL int64 = 10
+const DEFAULT_PROXY_MAX_BLOCK_LAG uint64 = 5
+const DEFAULT_PROXY_MAX_ERRORS = 3
+const UPSTREAM_UNAVAILABLE_ERROR_CODE = -32603
+
+var healthCheckRequest = []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+
+// WRITE_METHODS are not idempotent, they are sent once and only to the writer node
+var WRITE_METHODS = map[string]bool{
+	"eth_sendTransaction":           true,
+	"eth_sendRawTransaction":        true,
+	"eea_sendRawTransaction":        true,
+	"priv_distributeRawTransaction": true,
+	"priv_createPrivacyGroup":       true,
+	"priv_deletePrivacyGroup":       true,
+}
+
+type messageIDContextKey struct{}
+
+type writeContextKey struct{}
+
+type upstream struct {
+	url         *url.URL
+	priority    int
+	lock        sync.RWMutex
+	healthy     bool
+	blockNumber uint64
+	errors      int
+}
+
+func (upstream *upstream) isHealthy() bool {
+	upstream.lock.RLock()
+	defer upstream.lock.RUnlock()
+	return upstream.healthy
+}
+
+func (upstream *upstream) setHealthy(healthy bool) {
+	upstream.lock.Lock()
+	defer upstream.lock.Unlock()
+	if upstream.healthy != healthy {
+		log.GeneralLogger.Println("upstream", upstream.url.String(), "healthy:", healthy)
+	}
+	upstream.healthy = healthy
+}
+
+// reportError counts a failed read, the upstream is skipped after maxErrors consecutive failures until its next health check
+func (upstream *upstream) reportError(maxErrors int, err error) {
+	upstream.lock.Lock()
+	defer upstream.lock.Unlock()
+	upstream.errors++
+	if upstream.healthy && upstream.errors >= maxErrors {
+		log.ErrorLogger.Println("upstream", upstream.url.String(), "marked unhealthy after", upstream.errors, "failed reads:", err)
+		upstream.healthy = false
+	}
+}
+
+// checked records a health check, the errors counted so far are forgiven when the upstream answered
+func (upstream *upstream) checked(reachable bool, blockNumber uint64, highest uint64, maxBlockLag uint64) {
+	upstream.lock.Lock()
+	defer upstream.lock.Unlock()
+	healthy := reachable && highest-blockNumber <= maxBlockLag
+	if upstream.healthy != healthy {
+		log.GeneralLogger.Println("upstream", upstream.url.String(), "healthy:", healthy, "block:", blockNumber, "highest:", highest)
+	}
+	upstream.healthy = healthy
+	upstream.blockNumber = blockNumber
+	if reachable {
+		upstream.errors = 0
+	}
+}
+
+// ReverseProxy forwards JSON-RPC requests to the healthy Besu upstream with the lowest priority, failing over to the
+// next one on errors. Writes always go to the writer node.
+type ReverseProxy struct {
+	upstreams           []*upstream
+	writer              *upstream
+	transport           http.RoundTripper
+	retries             int
+	healthCheckInterval time.Duration
+	maxBlockLag         uint64
+	maxErrors           int
+	proxy               *httputil.ReverseProxy
+}
+
+// NewReverseProxy creates the proxy for the configured upstreams, or for nodeURL when none is configured
+func NewReverseProxy(config model.ProxyConfig, nodeURL string) (*ReverseProxy, error) {
+	targets := config.Upstreams
+	if len(targets) == 0 {
+		targets = []string{nodeURL}
+	}
+
+	reverseProxy := &ReverseProxy{
+		// every other upstream is tried once by default
+		retries:             len(targets) - 1,
+		healthCheckInterval: time.Duration(secondsOrDefault(config.HealthCheckInterval, DEFAULT_PROXY_HEALTH_CHECK_INTERVAL)) * time.Second,
+		maxBlockLag:         config.MaxBlockLag,
+		maxErrors:           config.MaxErrors,
+	}
+	if config.Retries != nil {
+		reverseProxy.retries = *config.Retries
+	}
+	if reverseProxy.maxBlockLag == 0 {
+		reverseProxy.maxBlockLag = DEFAULT_PROXY_MAX_BLOCK_LAG
+	}
+	if reverseProxy.maxErrors <= 0 {
+		reverseProxy.maxErrors = DEFAULT_PROXY_MAX_ERRORS
+	}
+
+	for i, target := range targets {
+		upstream, err := newUpstream(target)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(config.Priorities) {
+			upstream.priority = config.Priorities[i]
+		}
+		reverseProxy.upstreams = append(reverseProxy.upstreams, upstream)
+	}
+
+	// lower priority values are preferred, keeping the configured order between equal priorities
+	sort.SliceStable(reverseProxy.upstreams, func(i, j int) bool {
+		return reverseProxy.upstreams[i].priority < reverseProxy.upstreams[j].priority
+	})
+
+	reverseProxy.writer = reverseProxy.upstreams[0]
+	if len(config.Upstreams) > 0 {
+		writer, err := newUpstream(nodeURL)
+		if err != nil {
+			return nil, err
+		}
+		reverseProxy.writer = writer
+	}
+
+	maxIdleConns := config.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = DEFAULT_PROXY_MAX_IDLE_CONNS
+	}
+	reverseProxy.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Duration(secondsOrDefault(config.DialTimeout, DEFAULT_PROXY_DIAL_TIMEOUT)) * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConns,
+		IdleConnTimeout:       time.Duration(secondsOrDefault(config.IdleConnTimeout, DEFAULT_PROXY_IDLE_CONN_TIMEOUT)) * time.Second,
+		ResponseHeaderTimeout: time.Duration(secondsOrDefault(config.ResponseTimeout, DEFAULT_PROXY_RESPONSE_TIMEOUT)) * time.Second,
+	}
+
+	reverseProxy.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.Header.Set("X-Forwarded-Host", req.Host)
+		},
+		Transport:    roundTripperFunc(reverseProxy.roundTrip),
+		ErrorHandler: reverseProxy.handleError,
+	}
+
+	return reverseProxy, nil
+}
+
+func newUpstream(target string) (*upstream, error) {
+	upstreamURL, err := url.Parse(target)
+	if err != nil || upstreamURL.Scheme == "" || upstreamURL.Host == "" {
+		return nil, customErrors.FailedConnection.New(fmt.Sprintf("invalid upstream URL %s", target), -32602)
+	}
+	return &upstream{url: upstreamURL, healthy: true}, nil
+}
+
+// Forward proxies a read request, answering with a JSON-RPC error for messageID when no upstream is available
+func (reverseProxy *ReverseProxy) Forward(w http.ResponseWriter, r *http.Request, messageID json.RawMessage) {
+	reverseProxy.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), messageIDContextKey{}, messageID)))
+}
+
+// ForwardWrite proxies a request that is not idempotent to the writer node, without retrying it when it fails
+func (reverseProxy *ReverseProxy) ForwardWrite(w http.ResponseWriter, r *http.Request, messageID json.RawMessage) {
+	ctx := context.WithValue(context.WithValue(r.Context(), messageIDContextKey{}, messageID), writeContextKey{}, true)
+	reverseProxy.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// forward proxies the request with ForwardWrite when its method is a write
+func forward(proxy *ReverseProxy, w http.ResponseWriter, r *http.Request, rpcMessage rpc.JsonrpcMessage) {
+	if WRITE_METHODS[rpcMessage.Method] {
+		proxy.ForwardWrite(w, r, rpcMessage.ID)
+		return
+	}
+	proxy.Forward(w, r, rpcMessage.ID)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// roundTrip sends the request to the healthy upstreams in order, retrying on connection errors and gateway statuses.
+// A write is only sent to the writer node, a failed one may have reached it anyway.
+func (reverseProxy *ReverseProxy) roundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	candidates, retries := reverseProxy.candidates(), reverseProxy.retries
+	if write, _ := req.Context().Value(writeContextKey{}).(bool); write {
+		candidates, retries = []*upstream{reverseProxy.writer}, 0
+	}
+
+	var lastErr error
+	for attempt, upstream := range candidates {
+		if attempt > retries {
+			break
+		}
+
+		outReq := req.Clone(req.Context())
+		outReq.URL.Scheme = upstream.url.Scheme
+		outReq.URL.Host = upstream.url.Host
+		outReq.URL.Path = upstream.url.Path
+		outReq.Host = upstream.url.Host
+		outReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		outReq.ContentLength = int64(len(body))
+
+		response, err := reverseProxy.transport.RoundTrip(outReq)
+		if err == nil && !isGatewayError(response.StatusCode) {
+			return response, nil
+		}
+		if err == nil {
+			response.Body.Close()
+			err = fmt.Errorf("upstream %s answered %s", upstream.url.Host, response.Status)
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		log.ErrorLogger.Println("upstream", upstream.url.String(), "failed:", err)
+		upstream.setHealthy(false)
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// candidates returns the healthy upstreams first, each group by priority
+func (reverseProxy *ReverseProxy) candidates() []*upstream {
+	var healthy, unhealthy []*upstream
+	for _, upstream := range reverseProxy.upstreams {
+		if upstream.isHealthy() {
+			healthy = append(healthy, upstream)
+		} else {
+			unhealthy = append(unhealthy, upstream)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// ReaderURL returns the first healthy upstream, reads of the relay signer use it
+func (reverseProxy *ReverseProxy) ReaderURL() string {
+	return reverseProxy.candidates()[0].url.String()
+}
+
+// ReportError counts a read of the relay signer failed by an upstream, which is skipped until its next health check
+// after proxy.maxErrors consecutive failures
+func (reverseProxy *ReverseProxy) ReportError(url string, err error) {
+	for _, upstream := range reverseProxy.upstreams {
+		if upstream.url.String() == url {
+			log.ErrorLogger.Println("upstream", url, "failed a read:", err)
+			upstream.reportError(reverseProxy.maxErrors, err)
+		}
+	}
+}
+
+// Status returns the health of every upstream
+func (reverseProxy *ReverseProxy) Status() []model.NodeStatus {
+	status := make([]model.NodeStatus, 0, len(reverseProxy.upstreams))
+	for _, upstream := range reverseProxy.upstreams {
+		upstream.lock.RLock()
+		status = append(status, model.NodeStatus{URL: upstream.url.String(), Priority: upstream.priority, Healthy: upstream.healthy, BlockNumber: upstream.blockNumber, Errors: upstream.errors})
+		upstream.lock.RUnlock()
+	}
+	return status
+}
+
+func (reverseProxy *ReverseProxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	messageID, _ := r.Context().Value(messageIDContextKey{}).(json.RawMessage)
+	log.ErrorLogger.Println("no upstream available:", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(handleError(messageID, customErrors.FailedConnection.New("node is unavailable", UPSTREAM_UNAVAILABLE_ERROR_CODE)))
+}
+
+// ProcessHealthChecks probes every upstream periodically until done is closed
+func (reverseProxy *ReverseProxy) ProcessHealthChecks(done <-chan interface{}) {
+	ticker := time.NewTicker(reverseProxy.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			log.GeneralLogger.Println("quit signal received...exiting from upstream health checks")
+			return
+		case <-ticker.C:
+			reverseProxy.checkUpstreams()
+		}
+	}
+}
+
+// checkUpstreams queries the latest block of every upstream and marks the unreachable ones, and those more than
+// proxy.maxBlockLag blocks behind the most advanced one, as unhealthy. A lagging node would answer stale nonces.
+func (reverseProxy *ReverseProxy) checkUpstreams() {
+	blockNumbers := make([]uint64, len(reverseProxy.upstreams))
+	reachable := make([]bool, len(reverseProxy.upstreams))
+	var highest uint64
+	for i, upstream := range reverseProxy.upstreams {
+		blockNumbers[i], reachable[i] = reverseProxy.checkUpstream(upstream)
+		if reachable[i] && blockNumbers[i] > highest {
+			highest = blockNumbers[i]
+		}
+	}
+
+	for i, upstream := range reverseProxy.upstreams {
+		upstream.checked(reachable[i], blockNumbers[i], highest, reverseProxy.maxBlockLag)
+	}
+}
+
+// checkUpstream returns the latest block number of upstream, false when it doesn't answer it
+func (reverseProxy *ReverseProxy) checkUpstream(upstream *upstream) (uint64, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), reverseProxy.healthCheckInterval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstream.url.String(), bytes.NewReader(healthCheckRequest))
+	if err != nil {
+		return 0, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := reverseProxy.transport.RoundTrip(req)
+	if err != nil {
+		return 0, false
+	}
+	defer response.Body.Close()
+
+	var message struct {
+		Result hexutil.Uint64  `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if response.StatusCode != http.StatusOK || json.NewDecoder(response.Body).Decode(&message) != nil || message.Error != nil {
+		return 0, false
+	}
+	return uint64(message.Result), true
+}
+
+func isGatewayError(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+func secondsOrDefault(value, defaultValue int64) int64 {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+func TestReverseProxyFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.Contains(body, []byte("eth_blockNumber")) {
+			t.Errorf("Request body should be forwarded on retry, got %s", body)
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":7,"result":"0x10"}`))
+	}))
+	defer up.Close()
+
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{down.URL, up.URL}}, down.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","method":"eth_blockNumber","id":7}`))
+	recorder := httptest.NewRecorder()
+	proxy.Forward(recorder, request, []byte("7"))
+
+	if recorder.Body.String() != `{"jsonrpc":"2.0","id":7,"result":"0x10"}` {
+		t.Errorf("Request should fail over to the healthy upstream, got %s", recorder.Body.String())
+	}
+	if proxy.upstreams[0].isHealthy() || !proxy.upstreams[1].isHealthy() {
+		t.Errorf("Failed upstream should be marked unhealthy")
+	}
+}
+
+func TestReverseProxyUnavailable(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	proxy, err := NewReverseProxy(model.ProxyConfig{}, down.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","method":"eth_blockNumber","id":7}`))
+	recorder := httptest.NewRecorder()
+	proxy.Forward(recorder, request, []byte("7"))
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"jsonrpc":"2.0","id":7,"error":{"code":-32603,"message":"node is unavailable"}}` {
+		t.Errorf("Unavailable node should be a JSON-RPC error, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	if _, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{"://bad"}}, ""); err == nil {
+		t.Errorf("Invalid upstream URL should be rejected")
+	}
+}
+
+func TestReverseProxyWritesAreNotRetried(t *testing.T) {
+	requests := 0
+	writer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer writer.Close()
+
+	reader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Write should only be sent to the writer node")
+	}))
+	defer reader.Close()
+
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{reader.URL}}, writer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","method":"eth_sendRawTransaction","id":7}`))
+	recorder := httptest.NewRecorder()
+	proxy.ForwardWrite(recorder, request, []byte("7"))
+
+	if requests != 1 {
+		t.Errorf("Write should be sent once, got %d requests", requests)
+	}
+	if recorder.Body.String() != `{"jsonrpc":"2.0","id":7,"error":{"code":-32603,"message":"node is unavailable"}}` {
+		t.Errorf("Failed write should be a JSON-RPC error, got %s", recorder.Body.String())
+	}
+}
+
+func TestReverseProxyRetriesDisabled(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Request should not be retried when retries are disabled")
+	}))
+	defer up.Close()
+
+	retries := 0
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{down.URL, up.URL}, Retries: &retries}, down.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"jsonrpc":"2.0","method":"eth_blockNumber","id":7}`))
+	recorder := httptest.NewRecorder()
+	proxy.Forward(recorder, request, []byte("7"))
+
+	if recorder.Body.String() != `{"jsonrpc":"2.0","id":7,"error":{"code":-32603,"message":"node is unavailable"}}` {
+		t.Errorf("Failed read should be a JSON-RPC error, got %s", recorder.Body.String())
+	}
+}
+
+func TestReverseProxyReaders(t *testing.T) {
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{"http://reader1:4545", "http://reader2:4545"}, MaxErrors: 2}, "http://writer:4545")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy.ReaderURL() != "http://reader1:4545" {
+		t.Errorf("Reads should go to the first healthy upstream, got %s", proxy.ReaderURL())
+	}
+
+	proxy.ReportError("http://reader1:4545", errors.New("connection refused"))
+	if proxy.ReaderURL() != "http://reader1:4545" {
+		t.Errorf("Reads should keep the upstream below maxErrors failures, got %s", proxy.ReaderURL())
+	}
+	proxy.ReportError("http://reader1:4545", errors.New("connection refused"))
+	if proxy.ReaderURL() != "http://reader2:4545" {
+		t.Errorf("Reads should skip the failed upstream, got %s", proxy.ReaderURL())
+	}
+
+	status := proxy.Status()
+	if len(status) != 2 || status[0].Healthy || status[0].Errors != 2 || !status[1].Healthy {
+		t.Errorf("Status should report the failed upstream, got %+v", status)
+	}
+}
+
+func newBlockNumberUpstream(blockNumber string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + blockNumber + `"}`))
+	}))
+}
+
+func TestReverseProxyBlockLag(t *testing.T) {
+	lagging := newBlockNumberUpstream("0x10")
+	defer lagging.Close()
+	synced := newBlockNumberUpstream("0x64")
+	defer synced.Close()
+
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{lagging.URL, synced.URL}, MaxBlockLag: 5}, synced.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.ReportError(synced.URL, errors.New("connection refused"))
+
+	proxy.checkUpstreams()
+	if proxy.ReaderURL() != synced.URL {
+		t.Errorf("Reads should skip an upstream more than maxBlockLag blocks behind, got %s", proxy.ReaderURL())
+	}
+	status := proxy.Status()
+	if status[0].Healthy || status[0].BlockNumber != 0x10 || !status[1].Healthy || status[1].BlockNumber != 0x64 || status[1].Errors != 0 {
+		t.Errorf("Status should report the lagging upstream and forgive the errors of the answering one, got %+v", status)
+	}
+}
+
+func TestReverseProxyPriority(t *testing.T) {
+	proxy, err := NewReverseProxy(model.ProxyConfig{Upstreams: []string{"http://reader1:4545", "http://reader2:4545", "http://reader3:4545"}, Priorities: []int{2, 1}, MaxErrors: 1}, "http://writer:4545")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for _, upstream := range proxy.candidates() {
+		order = append(order, upstream.url.Host)
+	}
+	if strings.Join(order, ",") != "reader3:4545,reader2:4545,reader1:4545" {
+		t.Errorf("Upstreams should be tried by priority, got %v", order)
+	}
+
+	proxy.ReportError("http://reader3:4545", errors.New("connection refused"))
+	if proxy.ReaderURL() != "http://reader2:4545" {
+		t.Errorf("Reads should go to the healthy upstream with the lowest priority, got %s", proxy.ReaderURL())
+	}
+}
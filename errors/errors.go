@@ -35,6 +35,12 @@ const (
 	MalformedRawTransaction
 	//InvalidAddress error
 	InvalidAddress
+	//NotPermitted error
+	NotPermitted
+	//Unauthorized error
+	Unauthorized
+	//InvalidConfig error
+	InvalidConfig
 )	
 
 type customError struct {
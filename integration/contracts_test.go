@@ -0,0 +1,44 @@
+package integration
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BLOCKS_FREQUENCY is the number of blocks after which the RelayHub recalculates the gas limit of the writer nodes
+const BLOCKS_FREQUENCY = 60
+
+var addNodeSelector = crypto.Keccak256([]byte("addNode(address)"))[:4]
+var setBlocksFrequencySelector = crypto.Keccak256([]byte("setBlocksFrequency(uint8)"))[:4]
+
+// deployCompiled deploys testdata/<name>.bin from the writer node account with the ABI encoded constructor arguments.
+// The bytecode is compiled with solc 0.8.21 for istanbul (optimizer 200 runs, no metadata hash) from the repository
+// root with --base-path . --include-path integration/testdata/contracts
+func (node *simulatedNode) deployCompiled(t *testing.T, name string, arguments []byte) common.Address {
+	bin, err := ioutil.ReadFile(filepath.Join("testdata", name+".bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := append(common.FromHex(strings.TrimSpace(string(bin))), arguments...)
+	receipt := node.transact(t, nil, code)
+	return receipt.ContractAddress
+}
+
+// deployRelayHub deploys testdata/RelayHub.bin, the TxRelay of relayhub/contracts, and registers the writer node
+// in it. The writer node is the account ingress of the RelayHub since only the account ingress can add nodes.
+func (node *simulatedNode) deployRelayHub(t *testing.T) common.Address {
+	writer := crypto.PubkeyToAddress(node.key.PublicKey)
+	arguments := append(common.LeftPadBytes(big.NewInt(BLOCKS_FREQUENCY).Bytes(), 32), common.LeftPadBytes(writer.Bytes(), 32)...)
+	relayHub := node.deployCompiled(t, "RelayHub", arguments)
+
+	node.transact(t, &relayHub, append(common.CopyBytes(addNodeSelector), common.LeftPadBytes(writer.Bytes(), 32)...))
+	// any call evaluating the current block assigns the gas limit of the nodes added
+	node.transact(t, &relayHub, append(common.CopyBytes(setBlocksFrequencySelector), common.LeftPadBytes(big.NewInt(BLOCKS_FREQUENCY).Bytes(), 32)...))
+	return relayHub
+}
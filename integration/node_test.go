@@ -0,0 +1,173 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+const BLOCK_GAS_LIMIT uint64 = 100000000
+
+// TRANSACTION_GAS_LIMIT is the gas of the transactions sent by transact, enough to deploy the RelayHub
+const TRANSACTION_GAS_LIMIT uint64 = 5000000
+
+// simulatedNode serves a go-ethereum simulated backend over JSON-RPC, every transaction is mined right away
+type simulatedNode struct {
+	*httptest.Server
+	backend *backends.SimulatedBackend
+	// key of the writer node, funded in the genesis block
+	key *ecdsa.PrivateKey
+}
+
+func newSimulatedNode(t *testing.T, key *ecdsa.PrivateKey) *simulatedNode {
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: new(big.Int).Lsh(big.NewInt(1), 100)}}
+	backend := backends.NewSimulatedBackend(alloc, BLOCK_GAS_LIMIT)
+
+	server := gethrpc.NewServer()
+	if err := server.RegisterName("eth", &ethAPI{backend: backend}); err != nil {
+		t.Fatal(err)
+	}
+	node := &simulatedNode{Server: httptest.NewServer(server), backend: backend, key: key}
+	t.Cleanup(func() {
+		node.Close()
+		server.Stop()
+		backend.Close()
+	})
+	return node
+}
+
+// transact sends a transaction signed by the writer node key and returns its receipt
+func (node *simulatedNode) transact(t *testing.T, to *common.Address, data []byte) *types.Receipt {
+	ctx := context.Background()
+	from := crypto.PubkeyToAddress(node.key.PublicKey)
+	nonce, err := node.backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx *types.Transaction
+	if to != nil {
+		tx = types.NewTransaction(nonce, *to, new(big.Int), TRANSACTION_GAS_LIMIT, new(big.Int), data)
+	} else {
+		tx = types.NewContractCreation(nonce, new(big.Int), TRANSACTION_GAS_LIMIT, new(big.Int), data)
+	}
+	tx, err = types.SignTx(tx, types.HomesteadSigner{}, node.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.backend.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	node.backend.Commit()
+
+	receipt, err := node.backend.TransactionReceipt(ctx, tx.Hash())
+	if err != nil || receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("Transaction %s should succeed, got %v %v", tx.Hash().Hex(), receipt, err)
+	}
+	return receipt
+}
+
+// ethAPI implements the eth namespace used by the relay signer and its bindings
+type ethAPI struct {
+	backend *backends.SimulatedBackend
+}
+
+type callArgs struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+func (args callArgs) message() ethereum.CallMsg {
+	message := ethereum.CallMsg{To: args.To, Data: args.Data}
+	if args.From != nil {
+		message.From = *args.From
+	}
+	if args.Gas != nil {
+		message.Gas = uint64(*args.Gas)
+	}
+	if args.GasPrice != nil {
+		message.GasPrice = args.GasPrice.ToInt()
+	}
+	if args.Value != nil {
+		message.Value = args.Value.ToInt()
+	}
+	return message
+}
+
+func (api *ethAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(params.AllEthashProtocolChanges.ChainID)
+}
+
+func (api *ethAPI) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := api.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.Number.Uint64()), nil
+}
+
+func (api *ethAPI) GetBlockByNumber(ctx context.Context, _ gethrpc.BlockNumber, _ bool) (*types.Header, error) {
+	return api.backend.HeaderByNumber(ctx, nil)
+}
+
+func (api *ethAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
+	price, err := api.backend.SuggestGasPrice(ctx)
+	return (*hexutil.Big)(price), err
+}
+
+func (api *ethAPI) GetCode(ctx context.Context, address common.Address, _ gethrpc.BlockNumber) (hexutil.Bytes, error) {
+	return api.backend.CodeAt(ctx, address, nil)
+}
+
+func (api *ethAPI) GetTransactionCount(ctx context.Context, address common.Address, block gethrpc.BlockNumber) (hexutil.Uint64, error) {
+	var nonce uint64
+	var err error
+	if block == gethrpc.PendingBlockNumber {
+		nonce, err = api.backend.PendingNonceAt(ctx, address)
+	} else {
+		nonce, err = api.backend.NonceAt(ctx, address, nil)
+	}
+	return hexutil.Uint64(nonce), err
+}
+
+func (api *ethAPI) Call(ctx context.Context, args callArgs, _ gethrpc.BlockNumber) (hexutil.Bytes, error) {
+	return api.backend.CallContract(ctx, args.message(), nil)
+}
+
+func (api *ethAPI) EstimateGas(ctx context.Context, args callArgs) (hexutil.Uint64, error) {
+	gas, err := api.backend.EstimateGas(ctx, args.message())
+	return hexutil.Uint64(gas), err
+}
+
+func (api *ethAPI) SendRawTransaction(ctx context.Context, raw hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if err := api.backend.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	api.backend.Commit()
+	return tx.Hash(), nil
+}
+
+func (api *ethAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return api.backend.TransactionReceipt(ctx, hash)
+}
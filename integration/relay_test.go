@@ -0,0 +1,242 @@
+// Package integration runs the relay signer against a simulated chain, without any network access.
+// The RelayHub is the TxRelay of relayhub/contracts behind its BaseRelayRecipientProxy and the AccountRules is
+// the one of samples/custom-permissioning-contracts, both deployed from the bytecode compiled into testdata.
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/client"
+	"github.com/LACNetNetworks/gas-relay-signer/controller"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var addAccountSelector = crypto.Keccak256([]byte("addAccount(address)"))[:4]
+var setRelayHubSelector = crypto.Keccak256([]byte("setRelayHub(address)"))[:4]
+var storeSelector = crypto.Keccak256([]byte("store(uint256)"))[:4]
+var retreiveSelector = crypto.Keccak256([]byte("retreive()"))[:4]
+var transactionRelayedTopic = crypto.Keccak256Hash([]byte("TransactionRelayed(address,address,address,bool,bytes)"))
+
+// harness is a relay signer served in-process on top of a simulated node
+type harness struct {
+	node        *simulatedNode
+	proxy       common.Address
+	relayHub    common.Address
+	rules       common.Address
+	recipient   common.Address
+	service     *service.RelaySignerService
+	relayServer *httptest.Server
+}
+
+func newHarness(t *testing.T) *harness {
+	writerKey, _ := crypto.GenerateKey()
+	t.Setenv(service.ENVIRONMENT_KEY_NAME, "0x"+hex.EncodeToString(crypto.FromECDSA(writerKey)))
+
+	node := newSimulatedNode(t, writerKey)
+	relayHub := node.deployRelayHub(t)
+	proxy := node.deployCompiled(t, "BaseRelayRecipientProxy", common.LeftPadBytes(relayHub.Bytes(), 32))
+	// there is no AccountIngress on the simulated chain
+	rules := node.deployCompiled(t, "AccountRules", common.LeftPadBytes(nil, 32))
+	recipient := node.deployCompiled(t, "RecipientMock", nil)
+
+	config := &model.Config{
+		Application: model.ApplicationConfig{NodeURL: node.URL, ContractAddress: proxy.Hex()},
+		Security:    model.SecurityConfig{PermissionsEnabled: true, AccountContractAddress: rules.Hex()},
+	}
+	relaySignerService := new(service.RelaySignerService)
+	if err := relaySignerService.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	relayController := new(controller.RelayController)
+	if err := relayController.Init(config, relaySignerService); err != nil {
+		t.Fatal(err)
+	}
+
+	relayServer := httptest.NewServer(http.HandlerFunc(relayController.SignTransaction))
+	t.Cleanup(relayServer.Close)
+
+	return &harness{node: node, proxy: proxy, relayHub: relayHub, rules: rules, recipient: recipient, service: relaySignerService, relayServer: relayServer}
+}
+
+// switchRelayHub deploys a new RelayHub and points the proxy to it, as governance does
+func (h *harness) switchRelayHub(t *testing.T) common.Address {
+	relayHub := h.node.deployRelayHub(t)
+	data := append(common.CopyBytes(setRelayHubSelector), common.LeftPadBytes(relayHub.Bytes(), 32)...)
+	h.node.transact(t, &h.proxy, data)
+	return relayHub
+}
+
+// permit adds the account of key to the AccountRules contract
+func (h *harness) permit(t *testing.T, key *ecdsa.PrivateKey) {
+	data := append(common.CopyBytes(addAccountSelector), common.LeftPadBytes(crypto.PubkeyToAddress(key.PublicKey).Bytes(), 32)...)
+	h.node.transact(t, &h.rules, data)
+}
+
+// stored reads the number the relayed transactions store in the RecipientMock
+func (h *harness) stored(t *testing.T) uint64 {
+	output, err := h.node.backend.CallContract(context.Background(), ethereum.CallMsg{To: &h.recipient, Data: retreiveSelector}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return new(big.Int).SetBytes(output).Uint64()
+}
+
+// store encodes the RecipientMock call storing number
+func store(number uint64) []byte {
+	return append(common.CopyBytes(storeSelector), common.LeftPadBytes(new(big.Int).SetUint64(number).Bytes(), 32)...)
+}
+
+// transactionRelayed returns the TransactionRelayed event of receipt
+func transactionRelayed(t *testing.T, receipt *types.Receipt) *types.Log {
+	for _, log := range receipt.Logs {
+		if len(log.Topics) > 0 && log.Topics[0] == transactionRelayedTopic {
+			return log
+		}
+	}
+	t.Fatalf("RelayHub should emit TransactionRelayed, got %v", receipt.Logs)
+	return nil
+}
+
+// dial connects a user to the relay signer
+func (h *harness) dial(t *testing.T, key *ecdsa.PrivateKey) *client.Client {
+	user, err := client.Dial(h.relayServer.URL, key, crypto.PubkeyToAddress(h.node.key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.GasLimit = 100000
+	user.PollInterval = 10 * time.Millisecond
+	t.Cleanup(user.Close)
+	return user
+}
+
+func TestRelayRawTransaction(t *testing.T) {
+	h := newHarness(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, _ := crypto.GenerateKey()
+	h.permit(t, key)
+	user := h.dial(t, key)
+	to := h.recipient
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		pending, err := user.PendingNonce(ctx)
+		if err != nil || pending != nonce {
+			t.Fatalf("Nonce should be read from the RelayHub, got %d %v want %d", pending, err, nonce)
+		}
+
+		hash, err := user.Send(ctx, &to, store(nonce+1))
+		if err != nil {
+			t.Fatalf("Transaction should be relayed, got %v", err)
+		}
+
+		receipt, err := user.WaitReceipt(ctx, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.TxHash != hash || receipt.Status != types.ReceiptStatusSuccessful || receipt.RevertReason != nil {
+			t.Errorf("Relay transaction %s should be mined successfully, got %+v", hash.Hex(), receipt)
+		}
+		relayed := transactionRelayed(t, receipt.Receipt)
+		if relayed.Address != h.relayHub || common.BytesToAddress(relayed.Topics[3].Bytes()) != to || new(big.Int).SetBytes(relayed.Data[:32]).Sign() == 0 {
+			t.Fatalf("RelayHub should execute the relayed call, got %+v", relayed)
+		}
+		if common.BytesToAddress(relayed.Topics[2].Bytes()) != user.From {
+			t.Errorf("RelayHub should recover the user as sender, got %s", relayed.Topics[2].Hex())
+		}
+		if stored := h.stored(t); stored != nonce+1 {
+			t.Errorf("Relayed call should store %d, got %d", nonce+1, stored)
+		}
+
+		block, err := h.node.backend.BlockByNumber(ctx, receipt.BlockNumber)
+		if err != nil || block.Transactions()[0].To() == nil || *block.Transactions()[0].To() != h.relayHub {
+			t.Errorf("Relay transaction should be sent by the writer node to the RelayHub, got %v", err)
+		}
+	}
+
+	pending, err := user.PendingNonce(ctx)
+	if err != nil || pending != 2 {
+		t.Errorf("Every relayed transaction should increment the nonce, got %d %v", pending, err)
+	}
+}
+
+func TestRejectNotPermittedSender(t *testing.T) {
+	h := newHarness(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, _ := crypto.GenerateKey()
+	user := h.dial(t, key)
+	to := h.recipient
+
+	before, _ := h.node.backend.HeaderByNumber(ctx, nil)
+	_, err := user.Send(ctx, &to, store(1))
+	if err == nil || !strings.Contains(err.Error(), "not permitted") {
+		t.Fatalf("Transaction of an account missing from AccountRules should be rejected, got %v", err)
+	}
+
+	after, _ := h.node.backend.HeaderByNumber(ctx, nil)
+	if after.Number.Cmp(before.Number) != 0 {
+		t.Errorf("Rejected transaction shouldn't reach the chain, blocks %s -> %s", before.Number, after.Number)
+	}
+
+	h.permit(t, key)
+	hash, err := user.Send(ctx, &to, store(1))
+	if err != nil {
+		t.Fatalf("Transaction should be relayed once the account is permitted, got %v", err)
+	}
+	receipt, err := user.WaitReceipt(ctx, hash)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful || receipt.BlockNumber.Cmp(new(big.Int).Add(after.Number, big.NewInt(2))) != 0 {
+		t.Errorf("Relay transaction should be mined in the block after the permission, got %+v %v", receipt, err)
+	}
+}
+
+func TestRelayHubSwitch(t *testing.T) {
+	h := newHarness(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, _ := crypto.GenerateKey()
+	h.permit(t, key)
+	user := h.dial(t, key)
+	to := h.recipient
+
+	if _, err := user.Send(ctx, &to, store(1)); err != nil {
+		t.Fatalf("Transaction should be relayed, got %v", err)
+	}
+
+	relayHub := h.switchRelayHub(t)
+	changed, err := h.service.RefreshRelayHubAddress()
+	if err != nil || !changed || h.service.RelayHubAddress() != relayHub {
+		t.Fatalf("RelayHub should be read again from the proxy, got %v %v %s", changed, err, h.service.RelayHubAddress().Hex())
+	}
+
+	pending, err := user.PendingNonce(ctx)
+	if err != nil || pending != 0 {
+		t.Fatalf("Nonce should be read from the new RelayHub, got %d %v", pending, err)
+	}
+	hash, err := user.Send(ctx, &to, store(1))
+	if err != nil {
+		t.Fatalf("Transaction should be relayed through the new RelayHub, got %v", err)
+	}
+	receipt, err := user.WaitReceipt(ctx, hash)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("Relay transaction should be mined successfully, got %+v %v", receipt, err)
+	}
+	if relayed := transactionRelayed(t, receipt.Receipt); relayed.Address != relayHub {
+		t.Errorf("New RelayHub should emit TransactionRelayed, got %+v", relayed)
+	}
+}
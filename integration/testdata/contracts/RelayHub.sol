@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: UNLICENSED
+pragma solidity >=0.8.0 <0.9.0;
+
+import "relayhub/contracts/TxRelay.sol";
+
+/**
+ * @title RelayHub
+ * @dev TxRelay with the functions of the relay signer bindings it doesn't declare: relayMetaTx and deployMetaTx
+ * taking the gas limit of the meta transaction first, and getNodeGasLimit. The meta transactions run the
+ * relayMetaTx and deployMetaTx of TxRelay through a delegatecall, so the writer node stays msg.sender.
+ */
+contract RelayHub is TxRelay {
+
+    constructor(uint8 _blocksFrequency, address _accountIngress) TxRelay(_blocksFrequency, _accountIngress){
+    }
+
+    function relayMetaTx(
+        uint256, // gasLimit
+        bytes memory signingData,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    ) external returns (ErrorCode success){
+        bytes memory output = _delegate(abi.encodeWithSignature("relayMetaTx(bytes,uint8,bytes32,bytes32)", signingData, v, r, s));
+        return abi.decode(output, (ErrorCode));
+    }
+
+    function deployMetaTx(
+        uint256, // gasLimit
+        bytes memory signingData,
+        uint8 v,
+        bytes32 r,
+        bytes32 s
+    ) external returns (ErrorCode success, address deployedAddress){
+        bytes memory output = _delegate(abi.encodeWithSignature("deployMetaTx(bytes,uint8,bytes32,bytes32)", signingData, v, r, s));
+        return abi.decode(output, (ErrorCode, address));
+    }
+
+    /**
+     * @dev Returns the gas a registered node can use in its next block, evaluateCurrentBlock resets the gas limit
+     * of the node to the current one on its first transaction of a block
+     */
+    function getNodeGasLimit(address node) external view returns (uint256){
+        if (!exists(node)){
+            return 0;
+        }
+        return getCurrentGasLimit();
+    }
+
+    function _delegate(bytes memory data) private returns (bytes memory){
+        (bool success, bytes memory output) = address(this).delegatecall(data);
+        if (!success){
+            assembly {
+                revert(add(output, 0x20), mload(output))
+            }
+        }
+        return output;
+    }
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+pragma solidity >=0.8.0 <0.9.0;
+
+/**
+ * @title RLPReader
+ * @dev The part of RLPReader from the solidity-rlp package that TxRelay.sol uses, decoding the signingData list.
+ * The package is a node dependency of relayhub/contracts that isn't part of this repository, so the integration
+ * tests compile TxRelay against this reader with the same library and struct names.
+ */
+library RLPReader {
+    uint8 constant STRING_SHORT_START = 0x80;
+    uint8 constant STRING_LONG_START = 0xb8;
+    uint8 constant LIST_SHORT_START = 0xc0;
+    uint8 constant LIST_LONG_START = 0xf8;
+    uint8 constant WORD_SIZE = 32;
+
+    struct RLPItem {
+        uint256 len;
+        uint256 memPtr;
+    }
+
+    struct Iterator {
+        RLPItem item;
+        uint256 nextPtr;
+    }
+
+    function toRlpItem(bytes memory item) internal pure returns (RLPItem memory) {
+        uint256 memPtr;
+        assembly {
+            memPtr := add(item, 0x20)
+        }
+        return RLPItem(item.length, memPtr);
+    }
+
+    function isList(RLPItem memory item) internal pure returns (bool) {
+        if (item.len == 0) return false;
+
+        uint8 byte0;
+        uint256 memPtr = item.memPtr;
+        assembly {
+            byte0 := byte(0, mload(memPtr))
+        }
+        return byte0 >= LIST_SHORT_START;
+    }
+
+    function toList(RLPItem memory item) internal pure returns (RLPItem[] memory result) {
+        require(isList(item), "RLPReader: item is not a list");
+
+        uint256 items = numItems(item);
+        result = new RLPItem[](items);
+
+        uint256 memPtr = item.memPtr + _payloadOffset(item.memPtr);
+        for (uint256 i = 0; i < items; i++) {
+            uint256 dataLen = _itemLength(memPtr);
+            result[i] = RLPItem(dataLen, memPtr);
+            memPtr = memPtr + dataLen;
+        }
+    }
+
+    function numItems(RLPItem memory item) internal pure returns (uint256 count) {
+        if (item.len == 0) return 0;
+
+        uint256 currPtr = item.memPtr + _payloadOffset(item.memPtr);
+        uint256 endPtr = item.memPtr + item.len;
+        while (currPtr < endPtr) {
+            currPtr = currPtr + _itemLength(currPtr);
+            count++;
+        }
+    }
+
+    function payloadLocation(RLPItem memory item) internal pure returns (uint256, uint256) {
+        uint256 offset = _payloadOffset(item.memPtr);
+        return (item.memPtr + offset, item.len - offset);
+    }
+
+    function toAddress(RLPItem memory item) internal pure returns (address) {
+        // 1 byte for the length prefix
+        require(item.len == 21, "RLPReader: invalid address length");
+
+        return address(uint160(toUint(item)));
+    }
+
+    function toUint(RLPItem memory item) internal pure returns (uint256 result) {
+        require(item.len > 0 && item.len <= 33, "RLPReader: invalid uint length");
+
+        (uint256 memPtr, uint256 len) = payloadLocation(item);
+        assembly {
+            result := mload(memPtr)
+
+            // shift to the correct location if necessary
+            if lt(len, 32) {
+                result := div(result, exp(256, sub(32, len)))
+            }
+        }
+    }
+
+    function toBytes(RLPItem memory item) internal pure returns (bytes memory result) {
+        require(item.len > 0, "RLPReader: empty item");
+
+        (uint256 memPtr, uint256 len) = payloadLocation(item);
+        result = new bytes(len);
+
+        uint256 destPtr;
+        assembly {
+            destPtr := add(0x20, result)
+        }
+        _copy(memPtr, destPtr, len);
+    }
+
+    // length of the RLP item starting at memPtr, prefix included
+    function _itemLength(uint256 memPtr) private pure returns (uint256 itemLen) {
+        uint256 byte0;
+        assembly {
+            byte0 := byte(0, mload(memPtr))
+        }
+
+        if (byte0 < STRING_SHORT_START) {
+            itemLen = 1;
+        } else if (byte0 < STRING_LONG_START) {
+            itemLen = byte0 - STRING_SHORT_START + 1;
+        } else if (byte0 < LIST_SHORT_START) {
+            assembly {
+                // number of bytes of the length
+                let byteLen := sub(byte0, 0xb7)
+                memPtr := add(memPtr, 1)
+                let dataLen := div(mload(memPtr), exp(256, sub(32, byteLen)))
+                itemLen := add(dataLen, add(byteLen, 1))
+            }
+        } else if (byte0 < LIST_LONG_START) {
+            itemLen = byte0 - LIST_SHORT_START + 1;
+        } else {
+            assembly {
+                let byteLen := sub(byte0, 0xf7)
+                memPtr := add(memPtr, 1)
+                let dataLen := div(mload(memPtr), exp(256, sub(32, byteLen)))
+                itemLen := add(dataLen, add(byteLen, 1))
+            }
+        }
+    }
+
+    // number of bytes of the prefix of the RLP item starting at memPtr
+    function _payloadOffset(uint256 memPtr) private pure returns (uint256) {
+        uint256 byte0;
+        assembly {
+            byte0 := byte(0, mload(memPtr))
+        }
+
+        if (byte0 < STRING_SHORT_START) {
+            return 0;
+        } else if (byte0 < STRING_LONG_START || (byte0 >= LIST_SHORT_START && byte0 < LIST_LONG_START)) {
+            return 1;
+        } else if (byte0 < LIST_SHORT_START) {
+            return byte0 - (STRING_LONG_START - 1) + 1;
+        } else {
+            return byte0 - (LIST_LONG_START - 1) + 1;
+        }
+    }
+
+    function _copy(uint256 src, uint256 dest, uint256 len) private pure {
+        if (len == 0) return;
+
+        // copy as many words as possible
+        for (; len >= WORD_SIZE; len -= WORD_SIZE) {
+            assembly {
+                mstore(dest, mload(src))
+            }
+            src += WORD_SIZE;
+            dest += WORD_SIZE;
+        }
+
+        if (len > 0) {
+            // left over bytes, the mask keeps the bytes of dest after them
+            uint256 mask = 256**(WORD_SIZE - len) - 1;
+            assembly {
+                let srcpart := and(mload(src), not(mask))
+                let destpart := and(mload(dest), mask)
+                mstore(dest, or(destpart, srcpart))
+            }
+        }
+    }
+}
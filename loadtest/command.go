@@ -0,0 +1,179 @@
+package loadtest
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/cli"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const DEFAULT_RELAY_URL = "http://localhost:9001"
+const DEFAULT_RATE = 10
+const DEFAULT_ACCOUNTS = 10
+const DEFAULT_DURATION = time.Minute
+const DEFAULT_GAS_LIMIT uint64 = 300000
+const DEFAULT_WAIT = time.Minute
+
+const HELP = `sends transactions of a contract workload through the relay signer at a target rate and
+reports latency percentiles, rejection reasons, gas used per block and finality
+
+workloads:
+  raw      calls --contract with --data
+  deploy   deploys the --data bytecode
+  erc20    transfer(address,uint256) of 1 unit to a random address, the accounts need a balance
+  erc721   mint(address,uint256) of a new token to the sender, the accounts need the minter role
+  did      setAttribute(address,bytes32,bytes,uint256) of the sender in an ERC-1056 DID registry
+
+the accounts are generated unless --keys is set, they must be permitted when account
+permissioning is enabled
+`
+
+// Command sends a contract workload through a running relay signer and prints the report
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:    "loadtest",
+		Summary: "send a contract workload through the relay signer and report its performance",
+		Help:    HELP,
+		Setup: func(flags *flag.FlagSet) cli.Runner {
+			relayURL := flags.String("relay", DEFAULT_RELAY_URL, "URL of the relay signer")
+			node := flags.String("node", "", "address of the writer node, the address of WRITER_KEY by default")
+			workloadName := flags.String("workload", "raw", "workload, one of "+strings.Join(WorkloadNames(), ", "))
+			contract := flags.String("contract", "", "address of the contract called by the workload")
+			data := flags.String("data", "0x", "hex payload of the raw workload, bytecode of the deploy workload")
+			rate := flags.Float64("rate", DEFAULT_RATE, "target transactions per second")
+			count := flags.Int("count", 0, "transactions to send, unlimited during --duration when 0")
+			duration := flags.Duration("duration", DEFAULT_DURATION, "time sending transactions")
+			accounts := flags.Int("accounts", DEFAULT_ACCOUNTS, "generated accounts, each one waits for the relay signer before sending again")
+			keysFile := flags.String("keys", "", "file with one hex private key per line used instead of generated accounts")
+			gasLimit := flags.Uint64("gas", DEFAULT_GAS_LIMIT, "gas limit of each transaction")
+			chainID := flags.Int64("chain-id", 0, "sign EIP-155 transactions for this chain id, homestead transactions when 0")
+			wait := flags.Duration("wait", DEFAULT_WAIT, "time to wait for the receipt of each transaction")
+			return func(args []string, out io.Writer) error {
+				nodeAddress, err := nodeArgument(*node)
+				if err != nil {
+					return err
+				}
+
+				var contractAddress *common.Address
+				if *contract != "" {
+					if !common.IsHexAddress(*contract) {
+						return fmt.Errorf("invalid address %s", *contract)
+					}
+					address := common.HexToAddress(*contract)
+					contractAddress = &address
+				}
+				payload, err := hexutil.Decode(*data)
+				if err != nil {
+					return fmt.Errorf("invalid data: %s", err)
+				}
+				workload, err := NewWorkload(*workloadName, contractAddress, payload)
+				if err != nil {
+					return err
+				}
+
+				var keys []*ecdsa.PrivateKey
+				if *keysFile != "" {
+					keys, err = readKeys(*keysFile)
+				} else {
+					keys, err = GenerateKeys(*accounts)
+				}
+				if err != nil {
+					return err
+				}
+
+				options := Options{
+					RelayURL:    *relayURL,
+					NodeAddress: nodeAddress,
+					Workload:    workload,
+					Keys:        keys,
+					Rate:        *rate,
+					Count:       *count,
+					Duration:    *duration,
+					GasLimit:    *gasLimit,
+					Wait:        *wait,
+				}
+				if *chainID != 0 {
+					options.ChainID = big.NewInt(*chainID)
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				interrupt := make(chan os.Signal, 1)
+				signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+				defer signal.Stop(interrupt)
+				go func() {
+					select {
+					case <-interrupt:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+
+				fmt.Fprintf(out, "sending %s transactions from %d accounts at %.1f tx/s to %s\n", workload.Name, len(keys), *rate, *relayURL)
+				report, err := Execute(ctx, options)
+				if err != nil {
+					return err
+				}
+				report.Print(out)
+				return nil
+			}
+		},
+	}
+}
+
+func nodeArgument(node string) (common.Address, error) {
+	if node != "" {
+		if !common.IsHexAddress(node) {
+			return common.Address{}, fmt.Errorf("invalid address %s", node)
+		}
+		return common.HexToAddress(node), nil
+	}
+
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(os.Getenv("WRITER_KEY"), "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("--node or WRITER_KEY is needed to address the writer node")
+	}
+	return crypto.PubkeyToAddress(key.PublicKey), nil
+}
+
+func readKeys(path string) ([]*ecdsa.PrivateKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []*ecdsa.PrivateKey
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(text, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid private key", path, line)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no private keys", path)
+	}
+	return keys, nil
+}
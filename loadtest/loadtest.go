@@ -0,0 +1,174 @@
+// Package loadtest drives signed user transactions through a relay signer at a target rate,
+// reporting the figures of docs/STRESS_TESTING.md for a contract workload.
+package loadtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/client"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Options of a load test run
+type Options struct {
+	RelayURL string
+	// NodeAddress is the writer node relaying the transactions
+	NodeAddress common.Address
+	Workload    *Workload
+	// Keys sign the transactions, each one sends a transaction at a time
+	Keys []*ecdsa.PrivateKey
+	// Rate is the target of transactions sent per second
+	Rate float64
+	// Count stops sending after that many transactions, Duration stops sending after that time, the first reached
+	Count    int
+	Duration time.Duration
+	GasLimit uint64
+	// ChainID signs EIP-155 transactions when set
+	ChainID *big.Int
+	// Wait is how long after its submission a transaction receipt is polled
+	Wait         time.Duration
+	PollInterval time.Duration
+}
+
+// account sends the transactions of one key with consecutive nonces
+type account struct {
+	client *client.Client
+	nonce  uint64
+	sent   uint64
+}
+
+// Execute runs the load test until Count transactions were sent or Duration elapsed, then waits for their receipts
+func Execute(ctx context.Context, options Options) (*Report, error) {
+	if options.Rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if len(options.Keys) == 0 {
+		return nil, fmt.Errorf("at least one account is needed")
+	}
+	if options.Count <= 0 && options.Duration <= 0 {
+		return nil, fmt.Errorf("count or duration must be set")
+	}
+
+	rpcClient, err := rpc.DialContext(ctx, options.RelayURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rpcClient.Close()
+
+	idle := make(chan *account, len(options.Keys))
+	for _, key := range options.Keys {
+		user := client.NewClient(rpcClient, key, options.NodeAddress)
+		user.ChainID = options.ChainID
+		if options.PollInterval > 0 {
+			user.PollInterval = options.PollInterval
+		}
+		nonce, err := user.PendingNonce(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("can't get the nonce of %s: %s", user.From.Hex(), err)
+		}
+		idle <- &account{client: user, nonce: nonce}
+	}
+
+	report := newReport(options.Workload.Name, options.Rate)
+	report.started = time.Now()
+
+	var deadline <-chan time.Time
+	if options.Duration > 0 {
+		timer := time.NewTimer(options.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / options.Rate))
+	defer ticker.Stop()
+
+	var sending, receipts sync.WaitGroup
+	// ticks without an idle account don't count in Count
+	ticks := 0
+ticking:
+	for options.Count <= 0 || ticks < options.Count {
+		select {
+		case <-ctx.Done():
+			break ticking
+		case <-deadline:
+			break ticking
+		case <-ticker.C:
+		}
+
+		select {
+		case sender := <-idle:
+			ticks++
+			sending.Add(1)
+			go func() {
+				defer sending.Done()
+				send(ctx, options, sender, report, &receipts)
+				idle <- sender
+			}()
+		default:
+			report.skipped()
+		}
+	}
+	sending.Wait()
+	report.Duration = time.Since(report.started)
+	receipts.Wait()
+
+	return report, nil
+}
+
+// send submits the next transaction of sender and follows its receipt, a rejected nonce is reused by the next one
+func send(ctx context.Context, options Options, sender *account, report *Report, receipts *sync.WaitGroup) {
+	data, err := options.Workload.Data(sender.client.From, sender.sent)
+	if err != nil {
+		report.submitted(0, err)
+		return
+	}
+	tx, err := sender.client.BuildTransaction(sender.nonce, options.Workload.To, nil, options.GasLimit, data)
+	if err != nil {
+		report.submitted(0, err)
+		return
+	}
+
+	submittedAt := time.Now()
+	hash, err := sender.client.SendTransaction(ctx, tx)
+	report.submitted(time.Since(submittedAt), err)
+	if err != nil {
+		return
+	}
+	sender.nonce++
+	sender.sent++
+
+	receipts.Add(1)
+	go func() {
+		defer receipts.Done()
+		waitCtx, cancel := context.WithTimeout(ctx, options.Wait)
+		defer cancel()
+
+		receipt, err := sender.client.WaitReceipt(waitCtx, hash)
+		if err != nil || receipt == nil || receipt.BlockNumber == nil {
+			report.pending()
+			return
+		}
+		minedAt := time.Now()
+		report.mined(receipt.BlockNumber.Uint64(), receipt.GasUsed, receipt.Status != types.ReceiptStatusSuccessful, minedAt.Sub(submittedAt), minedAt)
+	}()
+}
+
+// GenerateKeys creates count random user keys
+func GenerateKeys(count int) ([]*ecdsa.PrivateKey, error) {
+	keys := make([]*ecdsa.PrivateKey, count)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,116 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/controller"
+	"github.com/LACNetNetworks/gas-relay-signer/service/servicetest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newRelay(t *testing.T, chain *servicetest.FakeChainBackend) *httptest.Server {
+	relaySignerService, config := servicetest.NewService(t, chain, nil)
+	relayController := new(controller.RelayController)
+	if err := relayController.Init(config, relaySignerService); err != nil {
+		t.Fatal(err)
+	}
+	relay := httptest.NewServer(http.HandlerFunc(relayController.SignTransaction))
+	t.Cleanup(relay.Close)
+	return relay
+}
+
+func TestExecute(t *testing.T) {
+	contract := common.HexToAddress("0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1")
+	workload, _ := NewWorkload("raw", &contract, []byte{0xca, 0xfe})
+
+	// 66 bytes of payload and trailer, the node allowance fits 5 transactions
+	metaTxGasLimit := uint64(66*105 + 300000 + 100000)
+	chain := servicetest.NewFakeChainBackend(5 * metaTxGasLimit)
+	relay := newRelay(t, chain)
+
+	keys, _ := GenerateKeys(2)
+	report, err := Execute(context.Background(), Options{
+		RelayURL:     relay.URL,
+		NodeAddress:  common.HexToAddress("0x211152ca21d5daedbcfbf61173886bbb1a217242"),
+		Workload:     workload,
+		Keys:         keys,
+		Rate:         200,
+		Count:        8,
+		Duration:     10 * time.Second,
+		GasLimit:     100000,
+		Wait:         5 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Sent != 8 || report.Accepted != 5 || report.Rejections["transaction gas limit exceeds block gas limit"] != 3 {
+		t.Errorf("Transactions beyond the node allowance should be rejected, got %d sent %d accepted %v", report.Sent, report.Accepted, report.Rejections)
+	}
+	if report.Mined != 5 || report.Pending != 0 || len(report.FinalityLatency) != 5 || len(report.SubmitLatency) != 8 {
+		t.Errorf("Every accepted transaction should be mined, got %d mined %d pending", report.Mined, report.Pending)
+	}
+	if len(report.Blocks) != 5 || report.Blocks[1].GasUsed != metaTxGasLimit || report.Finality() <= 0 {
+		t.Errorf("Gas used should be reported by block, got %v", report.Blocks)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, want := range []string{"accepted:        5", "3  transaction gas limit exceeds block gas limit", "max 1 tx/block"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Report should contain %q, got\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWorkloads(t *testing.T) {
+	contract := common.HexToAddress("0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1")
+	account := common.HexToAddress("0x211152ca21d5daedbcfbf61173886bbb1a217242")
+
+	tests := []struct {
+		name     string
+		selector string
+		size     int
+	}{
+		{name: "erc20", selector: "transfer(address,uint256)", size: 4 + 2*32},
+		{name: "erc721", selector: "mint(address,uint256)", size: 4 + 2*32},
+		{name: "did", selector: "setAttribute(address,bytes32,bytes,uint256)", size: 4 + 8*32},
+	}
+	for _, test := range tests {
+		workload, err := NewWorkload(test.name, &contract, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := workload.Data(account, 1)
+		if err != nil || len(data) != test.size || !bytes.Equal(data[:4], crypto.Keccak256([]byte(test.selector))[:4]) {
+			t.Errorf("Workload %s should call %s, got %x %v", test.name, test.selector, data, err)
+		}
+	}
+
+	if _, err := NewWorkload("erc20", nil, nil); err == nil {
+		t.Errorf("Workloads calling a contract should need its address")
+	}
+	if workload, err := NewWorkload("deploy", nil, []byte{0x60, 0x80}); err != nil || workload.To != nil {
+		t.Errorf("Deploy workload should create contracts, got %v", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	for p, want := range map[float64]time.Duration{0: 1, 50: 5, 90: 9, 99: 10, 100: 10} {
+		if got := Percentile(latencies, p); got != want {
+			t.Errorf("Percentile %v should be %v, got %v", p, want, got)
+		}
+	}
+	if Percentile(nil, 50) != 0 {
+		t.Errorf("Percentile of no latencies should be 0")
+	}
+}
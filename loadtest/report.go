@@ -0,0 +1,179 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// BlockStats are the relayed transactions of the load test mined in one block
+type BlockStats struct {
+	Number       uint64
+	Transactions int
+	GasUsed      uint64
+}
+
+// Report summarizes a load test run
+type Report struct {
+	Workload string
+	Rate     float64
+	// Duration of the sending phase
+	Duration time.Duration
+	// Sent counts the transactions submitted to the relay signer
+	Sent int
+	// Skipped counts the ticks without an idle account to send from
+	Skipped    int
+	Accepted   int
+	Rejections map[string]int
+	Mined      int
+	Reverted   int
+	// Pending counts the accepted transactions without a receipt after the wait
+	Pending int
+	// SubmitLatency is the eth_sendRawTransaction round trip of each transaction sent
+	SubmitLatency []time.Duration
+	// FinalityLatency is the time from submission to the receipt of each mined transaction
+	FinalityLatency []time.Duration
+	Blocks          map[uint64]*BlockStats
+
+	lock      sync.Mutex
+	started   time.Time
+	lastMined time.Time
+}
+
+func newReport(workload string, rate float64) *Report {
+	return &Report{Workload: workload, Rate: rate, Rejections: make(map[string]int), Blocks: make(map[uint64]*BlockStats)}
+}
+
+func (report *Report) submitted(latency time.Duration, err error) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	report.Sent++
+	report.SubmitLatency = append(report.SubmitLatency, latency)
+	if err != nil {
+		report.Rejections[err.Error()]++
+		return
+	}
+	report.Accepted++
+}
+
+func (report *Report) skipped() {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	report.Skipped++
+}
+
+func (report *Report) mined(block uint64, gasUsed uint64, reverted bool, latency time.Duration, at time.Time) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	report.Mined++
+	if reverted {
+		report.Reverted++
+	}
+	report.FinalityLatency = append(report.FinalityLatency, latency)
+	if at.After(report.lastMined) {
+		report.lastMined = at
+	}
+
+	stats := report.Blocks[block]
+	if stats == nil {
+		stats = &BlockStats{Number: block}
+		report.Blocks[block] = stats
+	}
+	stats.Transactions++
+	stats.GasUsed += gasUsed
+}
+
+func (report *Report) pending() {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+	report.Pending++
+}
+
+// Finality is the rate at which transactions were mined, from the first submission to the last receipt
+func (report *Report) Finality() float64 {
+	elapsed := report.lastMined.Sub(report.started).Seconds()
+	if report.Mined == 0 || elapsed <= 0 {
+		return 0
+	}
+	return float64(report.Mined) / elapsed
+}
+
+// Print writes the report in the format of docs/STRESS_TESTING.md
+func (report *Report) Print(out io.Writer) {
+	sentRate := 0.0
+	if report.Duration > 0 {
+		sentRate = float64(report.Sent) / report.Duration.Seconds()
+	}
+
+	fmt.Fprintf(out, "workload:        %s\n", report.Workload)
+	fmt.Fprintf(out, "sent:            %d in %s, %.1f tx/s for a %.1f tx/s target\n", report.Sent, report.Duration.Round(time.Millisecond), sentRate, report.Rate)
+	if report.Skipped > 0 {
+		fmt.Fprintf(out, "skipped:         %d with every account waiting for the relay signer, add accounts to reach the target rate\n", report.Skipped)
+	}
+	fmt.Fprintf(out, "accepted:        %d\n", report.Accepted)
+	fmt.Fprintf(out, "rejected:        %d\n", report.Sent-report.Accepted)
+	reasons := make([]string, 0, len(report.Rejections))
+	for reason := range report.Rejections {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return report.Rejections[reasons[i]] > report.Rejections[reasons[j]] })
+	for _, reason := range reasons {
+		fmt.Fprintf(out, "  %6d  %s\n", report.Rejections[reason], reason)
+	}
+	fmt.Fprintf(out, "mined:           %d, %d reverted, %d without receipt\n", report.Mined, report.Reverted, report.Pending)
+	fmt.Fprintf(out, "submit latency:  %s\n", percentiles(report.SubmitLatency))
+	fmt.Fprintf(out, "finality:        %s, %.1f tx/s\n", percentiles(report.FinalityLatency), report.Finality())
+
+	blocks := make([]*BlockStats, 0, len(report.Blocks))
+	var maxTransactions int
+	var maxGas, totalGas uint64
+	for _, stats := range report.Blocks {
+		blocks = append(blocks, stats)
+		if stats.Transactions > maxTransactions {
+			maxTransactions = stats.Transactions
+		}
+		if stats.GasUsed > maxGas {
+			maxGas = stats.GasUsed
+		}
+		totalGas += stats.GasUsed
+	}
+	if len(blocks) == 0 {
+		return
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Number < blocks[j].Number })
+
+	fmt.Fprintf(out, "blocks:          %d, max %d tx/block, max %d gas/block, %d gas/tx\n", len(blocks), maxTransactions, maxGas, totalGas/uint64(report.Mined))
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "block\ttx\tgasUsed\t")
+	for _, stats := range blocks {
+		fmt.Fprintf(table, "%d\t%d\t%d\t\n", stats.Number, stats.Transactions, stats.GasUsed)
+	}
+	table.Flush()
+}
+
+// Percentile returns the p-th percentile of latencies, by the nearest rank
+func Percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func percentiles(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("p50 %s  p90 %s  p99 %s  max %s",
+		Percentile(latencies, 50).Round(time.Millisecond), Percentile(latencies, 90).Round(time.Millisecond),
+		Percentile(latencies, 99).Round(time.Millisecond), Percentile(latencies, 100).Round(time.Millisecond))
+}
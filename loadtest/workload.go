@@ -0,0 +1,130 @@
+package loadtest
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const DID_ATTRIBUTE_VALIDITY = 86400
+
+// Workload builds the transactions sent by the load generator
+type Workload struct {
+	Name string
+	// To is the contract called, nil deploys Data
+	To *common.Address
+	// Data returns the payload of the sequence-th transaction sent by account
+	Data func(account common.Address, sequence uint64) ([]byte, error)
+}
+
+var workloads = map[string]func(contract *common.Address, data []byte) (*Workload, error){
+	"raw":    rawWorkload,
+	"deploy": deployWorkload,
+	"erc20":  erc20Workload,
+	"erc721": erc721Workload,
+	"did":    didWorkload,
+}
+
+// WorkloadNames lists the available workloads
+func WorkloadNames() []string {
+	names := make([]string, 0, len(workloads))
+	for name := range workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewWorkload creates the workload name calling contract, data is the payload of raw and the bytecode of deploy
+func NewWorkload(name string, contract *common.Address, data []byte) (*Workload, error) {
+	create, ok := workloads[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload %s, expected one of %s", name, strings.Join(WorkloadNames(), ", "))
+	}
+	if contract == nil && name != "deploy" {
+		return nil, fmt.Errorf("workload %s needs a contract address", name)
+	}
+	return create(contract, data)
+}
+
+// rawWorkload sends the same payload in every transaction
+func rawWorkload(contract *common.Address, data []byte) (*Workload, error) {
+	return &Workload{Name: "raw", To: contract, Data: func(common.Address, uint64) ([]byte, error) {
+		return data, nil
+	}}, nil
+}
+
+// deployWorkload deploys the same bytecode in every transaction
+func deployWorkload(_ *common.Address, data []byte) (*Workload, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("workload deploy needs the contract bytecode")
+	}
+	return &Workload{Name: "deploy", Data: func(common.Address, uint64) ([]byte, error) {
+		return data, nil
+	}}, nil
+}
+
+// erc20Workload transfers one token unit to a new address, the accounts must hold a balance
+func erc20Workload(contract *common.Address, _ []byte) (*Workload, error) {
+	transfer := newMethod("transfer(address,uint256)", "address", "uint256")
+	return &Workload{Name: "erc20", To: contract, Data: func(common.Address, uint64) ([]byte, error) {
+		return transfer.pack(randomAddress(), big.NewInt(1))
+	}}, nil
+}
+
+// erc721Workload mints a new token to the sender, the accounts must have the minter role
+func erc721Workload(contract *common.Address, _ []byte) (*Workload, error) {
+	mint := newMethod("mint(address,uint256)", "address", "uint256")
+	return &Workload{Name: "erc721", To: contract, Data: func(account common.Address, sequence uint64) ([]byte, error) {
+		tokenID := new(big.Int).Lsh(new(big.Int).SetBytes(account.Bytes()), 64)
+		return mint.pack(account, tokenID.Add(tokenID, new(big.Int).SetUint64(sequence)))
+	}}, nil
+}
+
+// didWorkload sets an attribute of the sender identity in an ERC-1056 DID registry
+func didWorkload(contract *common.Address, _ []byte) (*Workload, error) {
+	setAttribute := newMethod("setAttribute(address,bytes32,bytes,uint256)", "address", "bytes32", "bytes", "uint256")
+	return &Workload{Name: "did", To: contract, Data: func(account common.Address, sequence uint64) ([]byte, error) {
+		var name [32]byte
+		copy(name[:], "did/svc/LoadTest")
+		value := []byte(fmt.Sprintf("https://loadtest.example/%s/%d", account.Hex(), sequence))
+		return setAttribute.pack(account, name, value, big.NewInt(DID_ATTRIBUTE_VALIDITY))
+	}}, nil
+}
+
+type method struct {
+	selector  []byte
+	arguments abi.Arguments
+}
+
+func newMethod(signature string, types ...string) method {
+	arguments := make(abi.Arguments, len(types))
+	for i, name := range types {
+		argumentType, err := abi.NewType(name, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		arguments[i] = abi.Argument{Type: argumentType}
+	}
+	return method{selector: crypto.Keccak256([]byte(signature))[:4], arguments: arguments}
+}
+
+func (m method) pack(values ...interface{}) ([]byte, error) {
+	encoded, err := m.arguments.Pack(values...)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, m.selector...), encoded...), nil
+}
+
+func randomAddress() common.Address {
+	var address common.Address
+	rand.Read(address[:])
+	return address
+}
@@ -15,57 +15,160 @@ limitations under the License.
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/LACNetNetworks/gas-relay-signer/admin"
 	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/cli"
+	conf "github.com/LACNetNetworks/gas-relay-signer/config"
 	"github.com/LACNetNetworks/gas-relay-signer/controller"
+	"github.com/LACNetNetworks/gas-relay-signer/loadtest"
 	"github.com/LACNetNetworks/gas-relay-signer/model"
 	"github.com/LACNetNetworks/gas-relay-signer/service"
-	"github.com/spf13/viper"
 )
 
 var config *model.Config
 var relaySignerService *service.RelaySignerService
 var relayController *controller.RelayController
+var healthController *controller.HealthController
+var adminController *controller.AdminController
 
 func main() {
-	config = getConfigFromFile()
+	configPath := flag.String("config", "", "path of the configuration file, config.toml in the working directory by default")
+	commands := newCommands(configPath)
+	flag.Usage = func() {
+		cli.Usage(os.Stderr, flag.CommandLine, commands)
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if err := cli.Execute(flag.CommandLine, commands, args, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// newCommands lists every command, those reading the configuration load the file given with --config when they run
+func newCommands(configPath *string) []*cli.Command {
+	loadConfig := func() *model.Config {
+		return getConfigFromFile(conf.NewSource(*configPath))
+	}
+	return []*cli.Command{
+		{
+			Name:    "serve",
+			Summary: "start the relay signer, the default when no command is given",
+			Setup: func(flags *flag.FlagSet) cli.Runner {
+				return func(args []string, out io.Writer) error {
+					serve(conf.NewSource(*configPath))
+					return nil
+				}
+			},
+		},
+		cli.DecodeTxCommand(),
+		cli.SignPayloadCommand(),
+		cli.ReceiptCommand(loadConfig),
+		cli.ConfigCommand(configPath),
+		admin.Command(loadConfig),
+		admin.AccountsCommand(loadConfig),
+		loadtest.Command(),
+	}
+}
 
-	relaySignerService = new(service.RelaySignerService)
+func serve(source *conf.Source) {
+	config = getConfigFromFile(source)
+
+	relaySignerService = service.NewRelaySignerService(nil)
 	err := relaySignerService.Init(config)
 	if err != nil {
-		log.GeneralLogger.Fatal(err)
+		log.ErrorLogger.Fatal(err)
 		return
 	}
 
 	relayController = new(controller.RelayController)
-	relayController.Init(config, relaySignerService)
+	err = relayController.Init(config, relaySignerService)
+	if err != nil {
+		log.ErrorLogger.Fatal(err)
+		return
+	}
+	healthController = new(controller.HealthController)
+	healthController.Init(config, relaySignerService)
+	adminController = new(controller.AdminController)
+	adminController.Init(config, relaySignerService)
 	done := make(chan interface{})
 	go relaySignerService.ProcessNewBlocks(done)
-	setupRoutes(config.Application.Port)
+	go relaySignerService.ProcessPermissionEvents(done)
+	go relayController.Proxy.ProcessHealthChecks(done)
+	go relaySignerService.ProcessRelayHubAddress(done)
+	go relaySignerService.ProcessPrivateAccounting(done)
+	go source.Watch(done, reloadConfig)
+	setupRoutes(config.Application.Port, done)
 	close(done)
 }
 
-func getConfigFromFile() *model.Config {
-	v := viper.New()
-	v.SetConfigName("config")
-	v.AddConfigPath(".")
-	if err := v.ReadInConfig(); err != nil {
-		log.GeneralLogger.Printf("couldn't load config: %s", err)
+func getConfigFromFile(source *conf.Source) *model.Config {
+	c, err := source.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		log.ErrorLogger.Printf("couldn't load config: %s", err)
 		os.Exit(1)
 	}
-	var c model.Config
-	if err := v.Unmarshal(&c); err != nil {
-		log.GeneralLogger.Printf("couldn't read config: %s", err)
-		os.Exit(1)
+	if err := log.SetLevel(c.Log.Level); err != nil {
+		log.ErrorLogger.Println(err)
 	}
-	log.GeneralLogger.Printf("smartContract=%s AgentKey=%s\n", c.Application.ContractAddress, c.KeyStore.Agent)
-	return &c
+	log.GeneralLogger.Printf("config=%s smartContract=%s AgentKey=%s\n", source.Path(), c.Application.ContractAddress, c.KeyStore.Agent)
+	return c
 }
 
-func setupRoutes(port string) {
+func reloadConfig(next *model.Config) error {
+	current := relaySignerService.CurrentConfig()
+	if sections := conf.RestartRequired(&current, next); len(sections) > 0 {
+		log.GeneralLogger.Println("changes to", strings.Join(sections, ", "), "are applied on restart")
+	}
+	return relaySignerService.Reload(next)
+}
+
+func setupRoutes(port string, done <-chan interface{}) {
 	log.GeneralLogger.Println("Init RelaySigner")
-	http.HandleFunc("/", relayController.SignTransaction)
-	http.ListenAndServe(":"+port, nil)
+	if relaySignerService.CurrentConfig().Auth.Enabled {
+		http.HandleFunc("/", relayController.Authenticate(relayController.SignTransaction))
+	} else {
+		http.HandleFunc("/", relayController.SignTransaction)
+	}
+	http.HandleFunc("/healthz", healthController.Liveness)
+	http.HandleFunc("/readyz", healthController.Readiness)
+	if config.Admin.Token != "" {
+		http.HandleFunc("/admin/status", adminController.Status)
+		http.HandleFunc("/admin/history", adminController.History)
+		http.HandleFunc("/admin/limits", adminController.Limits)
+		http.HandleFunc("/admin/nodes", adminController.Nodes)
+		http.HandleFunc("/admin/queue", adminController.Queue)
+		http.HandleFunc("/admin/transactions", adminController.Transactions)
+	}
+
+	if !config.TLS.Enabled {
+		http.ListenAndServe(":"+port, nil)
+		return
+	}
+
+	reloader, err := service.NewCertificateReloader(config.TLS)
+	if err != nil {
+		log.ErrorLogger.Fatal(err)
+		return
+	}
+	go reloader.Watch(done)
+
+	server := &http.Server{Addr: ":" + port, TLSConfig: reloader.TLSConfig()}
+	log.GeneralLogger.Println("Serving HTTPS, client certificates required:", config.TLS.ClientCAFile != "" && config.TLS.RequireClientCert)
+	err = server.ListenAndServeTLS("", "")
+	if err != nil {
+		log.ErrorLogger.Fatal(err)
+	}
 }
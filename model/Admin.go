@@ -0,0 +1,98 @@
+package model
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RelayHubStatus is the gas allowance and RelayHub state seen by this node
+type RelayHubStatus struct {
+	RelayHubAddress     *common.Address `json:"relayHubAddress"`
+	NodeAddress         common.Address  `json:"nodeAddress"`
+	NodeGasLimit        uint64          `json:"nodeGasLimit"`
+	GasUsedInBlock      uint64          `json:"gasUsedInBlock"`
+	GasLimit            uint64          `json:"gasLimit"`
+	MaxGasBlockLimit    uint64          `json:"maxGasBlockLimit"`
+	CurrentGasLimit     uint64          `json:"currentGasLimit"`
+	GasUsedLastBlocks   uint64          `json:"gasUsedLastBlocks"`
+	RegisteredNodes     uint64          `json:"registeredNodes"`
+	LastBlockNumber     uint64          `json:"lastBlockNumber,omitempty"`
+	LastBlockReceivedAt *time.Time      `json:"lastBlockReceivedAt,omitempty"`
+	Tiers               []TierStatus    `json:"tiers,omitempty"`
+}
+
+// TierStatus is the share of the node allowance reserved for a tier and the gas its tenants used in the current block
+type TierStatus struct {
+	Name           string  `json:"name"`
+	Priority       int     `json:"priority"`
+	ReservedShare  float64 `json:"reservedShare"`
+	ReservedGas    uint64  `json:"reservedGas"`
+	GasUsedInBlock uint64  `json:"gasUsedInBlock"`
+}
+
+// RelayRecord is an entry of the recent relay history
+type RelayRecord struct {
+	Time            time.Time       `json:"time"`
+	Tenant          string          `json:"tenant,omitempty"`
+	From            string          `json:"from"`
+	To              *common.Address `json:"to,omitempty"`
+	Nonce           uint64          `json:"nonce"`
+	GasLimit        uint64          `json:"gasLimit"`
+	GasUsedInBlock  uint64          `json:"gasUsedInBlock"`
+	TransactionHash string          `json:"transactionHash,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// LimiterState is the remaining tokens per key and the gas used per sender in the current quota window
+type LimiterState struct {
+	Enabled       bool               `json:"enabled"`
+	IP            map[string]float64 `json:"ip,omitempty"`
+	APIKey        map[string]float64 `json:"apiKey,omitempty"`
+	Sender        map[string]float64 `json:"sender,omitempty"`
+	SenderGasUsed map[string]uint64  `json:"senderGasUsed,omitempty"`
+}
+
+// QueuedTransaction is a raw transaction waiting in the admission queue for a block with enough gas allowance
+type QueuedTransaction struct {
+	Hash       common.Hash `json:"hash"`
+	Tenant     string      `json:"tenant,omitempty"`
+	Tier       string      `json:"tier,omitempty"`
+	From       string      `json:"from"`
+	Nonce      uint64      `json:"nonce"`
+	GasLimit   uint64      `json:"gasLimit"`
+	Priority   int         `json:"priority"`
+	Position   int         `json:"position"`
+	EnqueuedAt time.Time   `json:"enqueuedAt"`
+	// Blocks counts the blocks received while the transaction waited
+	Blocks int `json:"blocks"`
+}
+
+// QueueStatus is the depth, the counters since startup and the transactions of the admission queue
+type QueueStatus struct {
+	Enabled      bool   `json:"enabled"`
+	Order        string `json:"order,omitempty"`
+	Depth        int    `json:"depth"`
+	MaxDepth     int    `json:"maxDepth"`
+	MaxWait      int64  `json:"maxWait"`
+	GasQueued    uint64 `json:"gasQueued"`
+	HighestDepth int    `json:"highestDepth"`
+	// Admitted counts the transactions that left the queue to be relayed, Delayed the ones among them that waited a block
+	Admitted uint64 `json:"admitted"`
+	Delayed  uint64 `json:"delayed"`
+	// Expired counts the transactions rejected after the max wait, Rejected the ones turned away by a full queue
+	Expired  uint64 `json:"expired"`
+	Rejected uint64 `json:"rejected"`
+	// AverageWait is the mean time in seconds spent in the queue by the delayed transactions
+	AverageWait  float64             `json:"averageWait"`
+	Transactions []QueuedTransaction `json:"transactions,omitempty"`
+}
+
+// RelayedTransaction maps a raw transaction relayed within the deduplication window to its relay transaction
+type RelayedTransaction struct {
+	Hash      common.Hash `json:"hash"`
+	RelayHash common.Hash `json:"relayHash"`
+	Time      time.Time   `json:"time"`
+	// Duplicates counts the later submissions answered with RelayHash
+	Duplicates int `json:"duplicates"`
+}
@@ -11,6 +11,7 @@ type ApplicationConfig struct {
 	NodeAddressPath         string          `mapstructure:"nodeAddressPath"`
 	Key                     string          `mapstructure:"key"`
 	Port                    string          `mapstructure:"port"`
+	RelayHubRefreshInterval int64           `mapstructure:"relayHubRefreshInterval"`
 }
 
 type KeyStoreConfig struct {
@@ -22,13 +23,125 @@ type PassphraseConfig struct {
 }
 
 type SecurityConfig struct {
-	PermissionsEnabled     bool   `mapstructure:"permissionsEnabled"`
-	AccountContractAddress string `mapstructure:"accountContractAddress"`
+	PermissionsEnabled            bool   `mapstructure:"permissionsEnabled"`
+	AccountContractAddress        string `mapstructure:"accountContractAddress"`
+	DestinationPermissionsEnabled bool   `mapstructure:"destinationPermissionsEnabled"`
+	TransactionPermissionsEnabled bool   `mapstructure:"transactionPermissionsEnabled"`
+	PermissionsCacheEnabled       bool   `mapstructure:"permissionsCacheEnabled"`
+	PermissionsCacheStaleness     int64  `mapstructure:"permissionsCacheStaleness"`
+}
+
+type HealthConfig struct {
+	MaxHeaderAge    int64  `mapstructure:"maxHeaderAge"`
+	MinGasAllowance uint64 `mapstructure:"minGasAllowance"`
+}
+
+type AdminConfig struct {
+	Token       string `mapstructure:"token"`
+	HistorySize int    `mapstructure:"historySize"`
+}
+
+type RateLimitConfig struct {
+	Enabled           bool    `mapstructure:"enabled"`
+	IPRate            float64 `mapstructure:"ipRate"`
+	IPBurst           int     `mapstructure:"ipBurst"`
+	APIKeyRate        float64 `mapstructure:"apiKeyRate"`
+	APIKeyBurst       int     `mapstructure:"apiKeyBurst"`
+	APIKeyHeader      string  `mapstructure:"apiKeyHeader"`
+	SenderRate        float64 `mapstructure:"senderRate"`
+	SenderBurst       int     `mapstructure:"senderBurst"`
+	SenderGasQuota    uint64  `mapstructure:"senderGasQuota"`
+	GasQuotaWindow    int64   `mapstructure:"gasQuotaWindow"`
+	TrustForwardedFor bool    `mapstructure:"trustForwardedFor"`
+}
+
+type AuthConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	APIKeyHeader string   `mapstructure:"apiKeyHeader"`
+	APIKeys      []string `mapstructure:"apiKeys"`
+	JWTIssuer    string   `mapstructure:"jwtIssuer"`
+	JWTAudience  string   `mapstructure:"jwtAudience"`
+	JWTSecret    string   `mapstructure:"jwtSecret"`
+	JWKSFile     string   `mapstructure:"jwksFile"`
+	TenantClaim  string   `mapstructure:"tenantClaim"`
+}
+
+type TLSConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	CertFile          string `mapstructure:"certFile"`
+	KeyFile           string `mapstructure:"keyFile"`
+	MinVersion        string `mapstructure:"minVersion"`
+	ClientCAFile      string `mapstructure:"clientCAFile"`
+	RequireClientCert bool   `mapstructure:"requireClientCert"`
+}
+
+type RPCPolicyConfig struct {
+	Allow   []string `mapstructure:"allow"`
+	Deny    []string `mapstructure:"deny"`
+	Forward []string `mapstructure:"forward"`
+}
+
+type ProxyConfig struct {
+	Upstreams           []string `mapstructure:"upstreams"`
+	Priorities          []int    `mapstructure:"priorities"`
+	DialTimeout         int64    `mapstructure:"dialTimeout"`
+	ResponseTimeout     int64    `mapstructure:"responseTimeout"`
+	IdleConnTimeout     int64    `mapstructure:"idleConnTimeout"`
+	MaxIdleConns        int      `mapstructure:"maxIdleConns"`
+	Retries             *int     `mapstructure:"retries"`
+	HealthCheckInterval int64    `mapstructure:"healthCheckInterval"`
+	MaxBlockLag         uint64   `mapstructure:"maxBlockLag"`
+	MaxErrors           int      `mapstructure:"maxErrors"`
+}
+
+type PrivacyConfig struct {
+	PrecompileAddress    string   `mapstructure:"precompileAddress"`
+	AccountingRetries    int      `mapstructure:"accountingRetries"`
+	AccountingRetryDelay int64    `mapstructure:"accountingRetryDelay"`
+	EnforceGroups        bool     `mapstructure:"enforceGroups"`
+	FlexibleMetaTx       bool     `mapstructure:"flexibleMetaTx"`
+	FlexiblePrecompile   string   `mapstructure:"flexiblePrecompileAddress"`
+	Groups               []string `mapstructure:"groups"`
+}
+
+type QueueConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	MaxDepth int    `mapstructure:"maxDepth"`
+	MaxWait  int64  `mapstructure:"maxWait"`
+	Order    string `mapstructure:"order"`
+}
+
+type DeduplicationConfig struct {
+	Enabled bool  `mapstructure:"enabled"`
+	Window  int64 `mapstructure:"window"`
+}
+
+type TierConfig struct {
+	Name          string   `mapstructure:"name"`
+	Priority      int      `mapstructure:"priority"`
+	ReservedShare float64  `mapstructure:"reservedShare"`
+	Tenants       []string `mapstructure:"tenants"`
+}
+
+type LogConfig struct {
+	Level string `mapstructure:"level"`
 }
 
 type Config struct {
-	Application ApplicationConfig `mapstructure:"application"`
-	KeyStore    KeyStoreConfig    `mapstructure:"keystore"`
-	Passphrase  PassphraseConfig  `mapstructure:"passphrase"`
-	Security    SecurityConfig    `mapstructure:"security"`
+	Application   ApplicationConfig   `mapstructure:"application"`
+	KeyStore      KeyStoreConfig      `mapstructure:"keystore"`
+	Passphrase    PassphraseConfig    `mapstructure:"passphrase"`
+	Security      SecurityConfig      `mapstructure:"security"`
+	Health        HealthConfig        `mapstructure:"health"`
+	Admin         AdminConfig         `mapstructure:"admin"`
+	RateLimit     RateLimitConfig     `mapstructure:"rateLimit"`
+	Auth          AuthConfig          `mapstructure:"auth"`
+	TLS           TLSConfig           `mapstructure:"tls"`
+	RPCPolicy     RPCPolicyConfig     `mapstructure:"rpcPolicy"`
+	Proxy         ProxyConfig         `mapstructure:"proxy"`
+	Privacy       PrivacyConfig       `mapstructure:"privacy"`
+	Queue         QueueConfig         `mapstructure:"queue"`
+	Tiers         []TierConfig        `mapstructure:"tiers"`
+	Deduplication DeduplicationConfig `mapstructure:"deduplication"`
+	Log           LogConfig           `mapstructure:"log"`
 }
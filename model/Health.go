@@ -0,0 +1,23 @@
+package model
+
+// HealthCheck is the result of a single readiness probe
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// HealthStatus is the response of the liveness and readiness endpoints
+type HealthStatus struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// NodeStatus is the health of an upstream node
+type NodeStatus struct {
+	URL         string `json:"url"`
+	Priority    int    `json:"priority"`
+	Healthy     bool   `json:"healthy"`
+	BlockNumber uint64 `json:"blockNumber"`
+	Errors      int    `json:"errors"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const RESTRICTED = "restricted"
+const UNRESTRICTED = "unrestricted"
+
+// PrivateTransaction is a signed Besu private transaction as sent to eea_sendRawTransaction or priv_distributeRawTransaction
+type PrivateTransaction struct {
+	Nonce          uint64
+	GasPrice       *big.Int
+	GasLimit       uint64
+	To             *common.Address
+	Value          *big.Int
+	Data           []byte
+	From           common.Address
+	PrivateFrom    []byte
+	PrivateFor     [][]byte
+	PrivacyGroupID []byte
+	Restriction    string
+}
@@ -0,0 +1,10 @@
+package model
+
+const AUTH_METHOD_API_KEY = "apiKey"
+const AUTH_METHOD_JWT = "jwt"
+
+// Tenant is the identity an authenticated request is served for
+type Tenant struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
@@ -118,6 +118,17 @@ func (msg *JsonrpcMessage) IsGetBlockByNumber() bool {
 	return strings.HasSuffix(msg.Method, getBlockByNumber)
 }
 
+//MatchesMethod reports whether the method is listed by name, by namespace ("net" or "net_*") or by "*"
+func (msg *JsonrpcMessage) MatchesMethod(patterns []string) bool {
+	for _, pattern := range patterns {
+		namespace := strings.TrimSuffix(pattern, serviceMethodSeparator+"*")
+		if pattern == "*" || pattern == msg.Method || namespace == msg.namespace() {
+			return true
+		}
+	}
+	return false
+}
+
 func (msg *JsonrpcMessage) namespace() string {
 	elem := strings.SplitN(msg.Method, serviceMethodSeparator, 2)
 	return elem[0]
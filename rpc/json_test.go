@@ -0,0 +1,26 @@
+package rpc
+
+import "testing"
+
+func TestMatchesMethod(t *testing.T) {
+	msg := &JsonrpcMessage{Method: "net_version"}
+
+	cases := []struct {
+		patterns []string
+		matches  bool
+	}{
+		{[]string{"net_version"}, true},
+		{[]string{"net"}, true},
+		{[]string{"net_*"}, true},
+		{[]string{"*"}, true},
+		{[]string{"eth_call", "net_peerCount"}, false},
+		{[]string{"ne"}, false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		if msg.MatchesMethod(c.patterns) != c.matches {
+			t.Errorf("MatchesMethod(%v) should be %v", c.patterns, c.matches)
+		}
+	}
+}
@@ -12,7 +12,7 @@ contract AccountRules is AccountRulesProxy, AccountRulesList, AccessControl {
     // version of this contract: semver like 1.2.14 represented like 001002014
     uint private version = 1000000;
 
-    AccountIngress private ingressContract;
+    address private ingressContract;
 
     modifier onlyOnEditMode() {
         require(!readOnlyMode, "In read only mode: rules cannot be modified");
@@ -24,7 +24,8 @@ contract AccountRules is AccountRulesProxy, AccountRulesList, AccessControl {
         _;
     }
 
-    constructor (AccountIngress _ingressContract) public {
+    constructor (address _ingressContract) {
+        ingressContract = _ingressContract;
         _setupRole(DEFAULT_ADMIN_ROLE, msg.sender);
         add(msg.sender);
     }
@@ -53,14 +54,14 @@ contract AccountRules is AccountRulesProxy, AccountRulesList, AccessControl {
 
     function transactionAllowed(
         address sender,
-        address, // target
+        address target,
         uint256, // value
         uint256, // gasPrice
         uint256, // gasLimit
         bytes memory // payload
     ) public view returns (bool) {
         if (
-            accountPermitted (sender) && (target == "0x1234567890123456789012345678901234567890")
+            accountPermitted (sender) && (target == 0x1234567890123456789012345678901234567890)
         ) {
             return true;
         } else {
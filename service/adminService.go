@@ -0,0 +1,140 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+const DEFAULT_HISTORY_SIZE = 100
+
+// relayHistory keeps the last relayed or rejected transactions in a ring buffer
+type relayHistory struct {
+	mutex   sync.Mutex
+	records []model.RelayRecord
+	next    int
+	full    bool
+}
+
+func newRelayHistory(size int) *relayHistory {
+	if size <= 0 {
+		size = DEFAULT_HISTORY_SIZE
+	}
+	return &relayHistory{records: make([]model.RelayRecord, size)}
+}
+
+func (history *relayHistory) add(record model.RelayRecord) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	history.records[history.next] = record
+	history.next = (history.next + 1) % len(history.records)
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+// list returns records from the newest to the oldest
+func (history *relayHistory) list() []model.RelayRecord {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	size := history.next
+	if history.full {
+		size = len(history.records)
+	}
+
+	records := make([]model.RelayRecord, 0, size)
+	for i := 1; i <= size; i++ {
+		index := (history.next - i + len(history.records)) % len(history.records)
+		records = append(records, history.records[index])
+	}
+	return records
+}
+
+// RecordRelay adds a relayed or rejected transaction to the history
+func (service *RelaySignerService) RecordRelay(record model.RelayRecord) {
+	if service.history == nil {
+		return
+	}
+	record.GasUsedInBlock = getGasUsed()
+	service.history.add(record)
+}
+
+// GetRelayHistory returns the recent relay history, newest first
+func (service *RelaySignerService) GetRelayHistory() []model.RelayRecord {
+	if service.history == nil {
+		return []model.RelayRecord{}
+	}
+	return service.history.list()
+}
+
+// GetRelayHubStatus queries RelayHub for the gas allowance of this node and the hub-wide limits
+func (service *RelaySignerService) GetRelayHubStatus() (*model.RelayHubStatus, error) {
+	relayHubAddress := service.relayHubAddress()
+	if relayHubAddress == nil {
+		return nil, errors.InvalidAddress.New("RelayHub address was not resolved", -32610)
+	}
+
+	nodeAddress, err := service.getNodeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := service.chain()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	nodeGasLimit, err := client.GetNodeGasLimit(*relayHubAddress, nodeAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	gasLimit, err := client.GetGasLimit(*relayHubAddress, nodeAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	maxGasBlockLimit, err := client.GetMaxBlockGasLimit(*relayHubAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	currentGasLimit, err := client.GetCurrentGasLimit(*relayHubAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	gasUsedLastBlocks, err := client.GetGasUsedLastBlocks(*relayHubAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.GetNodes(*relayHubAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.RelayHubStatus{
+		RelayHubAddress:   relayHubAddress,
+		NodeAddress:       nodeAddress,
+		NodeGasLimit:      nodeGasLimit.Uint64(),
+		GasUsedInBlock:    getGasUsed(),
+		GasLimit:          gasLimit.Uint64(),
+		MaxGasBlockLimit:  maxGasBlockLimit.Uint64(),
+		CurrentGasLimit:   currentGasLimit.Uint64(),
+		GasUsedLastBlocks: gasUsedLastBlocks.Uint64(),
+		RegisteredNodes:   nodes.Uint64(),
+		Tiers:             service.tierStatus(nodeGasLimit.Uint64()),
+	}
+
+	header, receivedAt := getLastHeader()
+	if header != nil {
+		status.LastBlockNumber = header.Number.Uint64()
+		status.LastBlockReceivedAt = &receivedAt
+	}
+
+	return status, nil
+}
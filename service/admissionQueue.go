@@ -0,0 +1,341 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const QUEUE_ORDER_FIFO = "fifo"
+const QUEUE_ORDER_PRIORITY = "priority"
+const DEFAULT_QUEUE_MAX_DEPTH = 1000
+const DEFAULT_QUEUE_MAX_WAIT int64 = 30
+
+// QueueTicket is the place of a transaction in the admission queue
+type QueueTicket struct {
+	transaction model.QueuedTransaction
+	wake        chan struct{}
+	// active is set while the transaction has its turn, later arrivals are never placed before it
+	active bool
+	// deferred is set when the transaction didn't fit in the current block, cleared by the next block
+	deferred bool
+	expired  bool
+}
+
+// admissionQueue holds the transactions exceeding the node allowance of the current block until a following block
+// leaves room for them. A transaction only enters the queue when it doesn't fit or transactions it may not pass are
+// waiting. Only the head of the queue verifies its gas limit, so transactions are relayed in queue order, except that
+// a transaction may pass the deferred transactions of other tiers to use the gas reserved for its own.
+type admissionQueue struct {
+	mutex    sync.Mutex
+	order    string
+	maxDepth int
+	maxWait  time.Duration
+	tickets  []*QueueTicket
+
+	lastBlock     time.Time
+	blockInterval time.Duration
+	highestDepth  int
+	admitted      uint64
+	delayed       uint64
+	expired       uint64
+	rejected      uint64
+	totalWait     time.Duration
+}
+
+func newAdmissionQueue(config model.QueueConfig) (*admissionQueue, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	queue := &admissionQueue{order: config.Order, maxDepth: config.MaxDepth, maxWait: time.Duration(config.MaxWait) * time.Second}
+	if queue.order == "" {
+		queue.order = QUEUE_ORDER_FIFO
+	}
+	if queue.order != QUEUE_ORDER_FIFO && queue.order != QUEUE_ORDER_PRIORITY {
+		return nil, errors.InvalidConfig.New("queue order must be fifo or priority", -32602)
+	}
+	if queue.maxDepth <= 0 {
+		queue.maxDepth = DEFAULT_QUEUE_MAX_DEPTH
+	}
+	if queue.maxWait <= 0 {
+		queue.maxWait = time.Duration(DEFAULT_QUEUE_MAX_WAIT) * time.Second
+	}
+
+	return queue, nil
+}
+
+// retryAfter is the hint given to clients turned away by the queue, the time until the next block is expected
+func (queue *admissionQueue) retryAfter() time.Duration {
+	if queue.blockInterval <= 0 {
+		return time.Second
+	}
+	return queue.blockInterval
+}
+
+// enter places the transaction after those with the same or a higher priority and after every active one
+func (queue *admissionQueue) enter(transaction model.QueuedTransaction, now time.Time) (*QueueTicket, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if len(queue.tickets) >= queue.maxDepth {
+		queue.rejected++
+		return nil, &RateLimitError{message: "admission queue is full", RetryAfter: queue.retryAfter()}
+	}
+
+	transaction.EnqueuedAt = now
+	ticket := &QueueTicket{transaction: transaction, wake: make(chan struct{}, 1)}
+
+	index := 0
+	for i, other := range queue.tickets {
+		if other.active || other.transaction.Priority >= transaction.Priority {
+			index = i + 1
+		}
+	}
+	queue.tickets = append(queue.tickets, nil)
+	copy(queue.tickets[index+1:], queue.tickets[index:])
+	queue.tickets[index] = ticket
+
+	if len(queue.tickets) > queue.highestDepth {
+		queue.highestDepth = len(queue.tickets)
+	}
+	queue.notify()
+	return ticket, nil
+}
+
+// waiting reports whether a transaction of tier arriving now would have to wait behind queued transactions
+func (queue *admissionQueue) waiting(tier string) bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for _, other := range queue.tickets {
+		if !other.deferred || other.transaction.Tier == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// turn reports whether ticket wasn't deferred in the current block and only deferred transactions of other tiers are ahead of it
+func (queue *admissionQueue) turn(ticket *QueueTicket) bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if ticket.deferred {
+		return false
+	}
+	for _, other := range queue.tickets {
+		if other == ticket {
+			ticket.active = true
+			return true
+		}
+		if !other.deferred || other.transaction.Tier == ticket.transaction.Tier {
+			return false
+		}
+	}
+	return false
+}
+
+// await blocks until it is the turn of ticket, or removes it from the queue once it waited maxWait
+func (queue *admissionQueue) await(ticket *QueueTicket) error {
+	timer := time.NewTimer(time.Until(ticket.transaction.EnqueuedAt.Add(queue.maxWait)))
+	defer timer.Stop()
+
+	for !queue.turn(ticket) {
+		select {
+		case <-ticket.wake:
+		case <-timer.C:
+			queue.mutex.Lock()
+			defer queue.mutex.Unlock()
+			ticket.expired = true
+			queue.expired++
+			queue.remove(ticket)
+			return &RateLimitError{message: fmt.Sprintf("transaction waited more than %s in the admission queue", queue.maxWait), RetryAfter: queue.retryAfter()}
+		}
+	}
+	return nil
+}
+
+// deferToNextBlock keeps ticket in its place until the next block and lets the transactions behind it take their turn
+func (queue *admissionQueue) deferToNextBlock(ticket *QueueTicket) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	ticket.active = false
+	ticket.deferred = true
+	queue.notify()
+}
+
+// leave removes ticket once it was relayed or rejected after waiting
+func (queue *admissionQueue) leave(ticket *QueueTicket, now time.Time) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if ticket.expired {
+		return
+	}
+	queue.admitted++
+	if ticket.transaction.Blocks > 0 {
+		queue.delayed++
+		queue.totalWait += now.Sub(ticket.transaction.EnqueuedAt)
+	}
+	queue.remove(ticket)
+}
+
+func (queue *admissionQueue) remove(ticket *QueueTicket) {
+	for i, queued := range queue.tickets {
+		if queued == ticket {
+			queue.tickets = append(queue.tickets[:i], queue.tickets[i+1:]...)
+			break
+		}
+	}
+	queue.notify()
+}
+
+// newBlock lets the deferred transactions try again and counts the blocks waited by every queued transaction
+func (queue *admissionQueue) newBlock(now time.Time) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if !queue.lastBlock.IsZero() {
+		queue.blockInterval = now.Sub(queue.lastBlock)
+	}
+	queue.lastBlock = now
+
+	for _, ticket := range queue.tickets {
+		if !ticket.active {
+			ticket.deferred = false
+			ticket.transaction.Blocks++
+		}
+	}
+	queue.notify()
+}
+
+// notify wakes the deferred transactions at the head of the queue and the first one after them, the mutex must be held
+func (queue *admissionQueue) notify() {
+	for _, ticket := range queue.tickets {
+		select {
+		case ticket.wake <- struct{}{}:
+		default:
+		}
+		if !ticket.deferred {
+			return
+		}
+	}
+}
+
+func (queue *admissionQueue) status() *model.QueueStatus {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	status := &model.QueueStatus{
+		Enabled:      true,
+		Order:        queue.order,
+		Depth:        len(queue.tickets),
+		MaxDepth:     queue.maxDepth,
+		MaxWait:      int64(queue.maxWait.Seconds()),
+		HighestDepth: queue.highestDepth,
+		Admitted:     queue.admitted,
+		Delayed:      queue.delayed,
+		Expired:      queue.expired,
+		Rejected:     queue.rejected,
+		Transactions: make([]model.QueuedTransaction, 0, len(queue.tickets)),
+	}
+	if queue.delayed > 0 {
+		status.AverageWait = (queue.totalWait / time.Duration(queue.delayed)).Seconds()
+	}
+	for i, ticket := range queue.tickets {
+		transaction := ticket.transaction
+		transaction.Position = i + 1
+		status.GasQueued += transaction.GasLimit
+		status.Transactions = append(status.Transactions, transaction)
+	}
+	return status
+}
+
+func (queue *admissionQueue) find(hash common.Hash) (*model.QueuedTransaction, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for i, ticket := range queue.tickets {
+		if ticket.transaction.Hash == hash {
+			transaction := ticket.transaction
+			transaction.Position = i + 1
+			return &transaction, true
+		}
+	}
+	return nil, false
+}
+
+// QueueWaiting reports whether a raw transaction of tenant must enter the admission queue right away, behind the
+// queued transactions it may not pass, instead of verifying its gas limit first
+func (service *RelaySignerService) QueueWaiting(tenant string) bool {
+	if service.queue == nil {
+		return false
+	}
+	return service.queue.waiting(service.tiers.name(tenant))
+}
+
+// CanQueue reports whether a raw transaction of tenant exceeding the allowance left in the current block may wait in
+// the admission queue, false when the queue is disabled or it wouldn't fit even in an empty block of nodeGasLimit
+func (service *RelaySignerService) CanQueue(tenant string, gasLimit uint64, nodeGasLimit uint64) bool {
+	return service.queue != nil && service.fitsEmptyBlock(gasLimit, nodeGasLimit, service.tiers.name(tenant))
+}
+
+// EnterQueue places a raw transaction in the admission queue, it returns a nil ticket when the queue is disabled
+func (service *RelaySignerService) EnterQueue(transaction model.QueuedTransaction) (*QueueTicket, error) {
+	if service.queue == nil {
+		return nil, nil
+	}
+	transaction.Tier = service.tiers.name(transaction.Tenant)
+	if service.queue.order == QUEUE_ORDER_PRIORITY {
+		transaction.Priority = service.tiers.priority(transaction.Tenant)
+	}
+	return service.queue.enter(transaction, time.Now())
+}
+
+// AwaitTurn blocks until the ticket may verify its gas limit in the current block
+func (service *RelaySignerService) AwaitTurn(ticket *QueueTicket) error {
+	if service.queue == nil || ticket == nil {
+		return nil
+	}
+	return service.queue.await(ticket)
+}
+
+// DeferToNextBlock keeps a transaction exceeding the allowance left in the current block in the queue until the next
+// block. It returns false for a transaction that wouldn't fit even in an empty block of nodeGasLimit.
+func (service *RelaySignerService) DeferToNextBlock(ticket *QueueTicket, gasLimit uint64, nodeGasLimit uint64) bool {
+	if service.queue == nil || ticket == nil || !service.fitsEmptyBlock(gasLimit, nodeGasLimit, ticket.transaction.Tier) {
+		return false
+	}
+	service.queue.deferToNextBlock(ticket)
+	return true
+}
+
+// LeaveQueue removes a relayed or rejected transaction from the queue and lets the next one take its turn
+func (service *RelaySignerService) LeaveQueue(ticket *QueueTicket) {
+	if service.queue == nil || ticket == nil {
+		return
+	}
+	service.queue.leave(ticket, time.Now())
+}
+
+// GetQueueStatus returns the depth, the counters and the transactions of the admission queue
+func (service *RelaySignerService) GetQueueStatus() *model.QueueStatus {
+	if service.queue == nil {
+		return &model.QueueStatus{}
+	}
+	return service.queue.status()
+}
+
+// GetQueuedTransaction returns the place in the admission queue of the raw transaction hash
+func (service *RelaySignerService) GetQueuedTransaction(hash common.Hash) (*model.QueuedTransaction, bool) {
+	if service.queue == nil {
+		return nil, false
+	}
+	return service.queue.find(hash)
+}
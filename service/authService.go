@@ -0,0 +1,236 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const DEFAULT_TENANT_CLAIM = "sub"
+const UNAUTHORIZED_ERROR_CODE = -32001
+
+var hmacMethods = []string{"HS256", "HS384", "HS512"}
+var publicKeyMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// authenticator maps static API keys and JWTs of the configured issuer to tenant identities
+type authenticator struct {
+	apiKeys     map[string]string
+	hmacSecret  []byte
+	publicKeys  map[string]interface{}
+	issuer      string
+	audience    string
+	tenantClaim string
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newAuthenticator(config model.AuthConfig) (*authenticator, error) {
+	auth := &authenticator{
+		apiKeys:     make(map[string]string),
+		issuer:      config.JWTIssuer,
+		audience:    config.JWTAudience,
+		tenantClaim: config.TenantClaim,
+	}
+	if auth.tenantClaim == "" {
+		auth.tenantClaim = DEFAULT_TENANT_CLAIM
+	}
+
+	for _, entry := range config.APIKeys {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.FailedKeyConfig.New("API keys must be configured as tenant:key", -32602)
+		}
+		auth.apiKeys[parts[1]] = parts[0]
+	}
+
+	if config.JWTSecret != "" {
+		auth.hmacSecret = []byte(config.JWTSecret)
+	}
+
+	if config.JWKSFile != "" {
+		publicKeys, err := loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		auth.publicKeys = publicKeys
+	}
+
+	if (auth.hmacSecret != nil || auth.publicKeys != nil) && auth.issuer == "" {
+		return nil, errors.FailedKeyConfig.New("JWT issuer must be configured to accept JWTs", -32602)
+	}
+
+	return auth, nil
+}
+
+func loadJWKS(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.FailedReadFile.Wrapf(err, "can't read JWKS file %s", -32602, path)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, errors.FailedReadFile.Wrapf(err, "invalid JWKS file %s", -32602, path)
+	}
+
+	publicKeys := make(map[string]interface{}, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, errors.FailedKeyConfig.Wrapf(err, "invalid key %s in JWKS file", -32602, key.Kid)
+		}
+		publicKeys[key.Kid] = publicKey
+	}
+	return publicKeys, nil
+}
+
+func (key jsonWebKey) publicKey() (interface{}, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBase64Int(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64Int(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", key.Crv)
+		}
+		x, err := decodeBase64Int(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64Int(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", key.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", key.Kty)
+}
+
+func decodeBase64Int(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+func (auth *authenticator) authenticateAPIKey(apiKey string) (*model.Tenant, bool) {
+	for key, tenant := range auth.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return &model.Tenant{ID: tenant, Method: model.AUTH_METHOD_API_KEY}, true
+		}
+	}
+	return nil, false
+}
+
+func (auth *authenticator) authenticateJWT(tokenString string) (*model.Tenant, error) {
+	var methods []string
+	if auth.hmacSecret != nil {
+		methods = append(methods, hmacMethods...)
+	}
+	if auth.publicKeys != nil {
+		methods = append(methods, publicKeyMethods...)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("JWT authentication is not configured")
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithIssuer(auth.issuer), jwt.WithExpirationRequired()}
+	if auth.audience != "" {
+		options = append(options, jwt.WithAudience(auth.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, auth.verificationKey, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, ok := claims[auth.tenantClaim].(string)
+	if !ok || tenant == "" {
+		return nil, fmt.Errorf("claim %s with the tenant is missing", auth.tenantClaim)
+	}
+
+	return &model.Tenant{ID: tenant, Method: model.AUTH_METHOD_JWT}, nil
+}
+
+func (auth *authenticator) verificationKey(token *jwt.Token) (interface{}, error) {
+	if strings.HasPrefix(token.Method.Alg(), "HS") {
+		return auth.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if publicKey, ok := auth.publicKeys[kid]; ok {
+		return publicKey, nil
+	}
+	if kid == "" && len(auth.publicKeys) == 1 {
+		for _, publicKey := range auth.publicKeys {
+			return publicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %s", kid)
+}
+
+// Authenticate resolves the tenant of a request from its API key or its bearer JWT
+func (service *RelaySignerService) Authenticate(apiKey, bearerToken string) (*model.Tenant, error) {
+	auth := service.currentAuth()
+	if auth == nil {
+		return nil, nil
+	}
+
+	if apiKey != "" {
+		if tenant, ok := auth.authenticateAPIKey(apiKey); ok {
+			return tenant, nil
+		}
+		return nil, errors.Unauthorized.New("invalid API key", UNAUTHORIZED_ERROR_CODE)
+	}
+
+	if bearerToken != "" {
+		tenant, err := auth.authenticateJWT(bearerToken)
+		if err != nil {
+			return nil, errors.Unauthorized.Wrapf(err, "invalid token", UNAUTHORIZED_ERROR_CODE)
+		}
+		return tenant, nil
+	}
+
+	return nil, errors.Unauthorized.New("missing API key or bearer token", UNAUTHORIZED_ERROR_CODE)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"math/big"
+
+	bl "github.com/LACNetNetworks/gas-relay-signer/blockchain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ChainBackend is every chain access of the service, implemented by blockchain.Client
+type ChainBackend interface {
+	GetChainID() (*big.Int, error)
+	GetPendingNonce(address common.Address) (uint64, error)
+	GetLatestHeader() (*types.Header, error)
+	GetRelayHubAddress(proxyAddress common.Address) (common.Address, error)
+	SendMetatransaction(contractAddress common.Address, options *bind.TransactOpts, to *common.Address, signingData []byte, v uint8, r [32]byte, s [32]byte) (*common.Hash, error)
+	GetTransactionReceipt(transactionHash common.Hash) (*types.Receipt, error)
+	GetTransactionCount(contractAddress common.Address, address common.Address, nodeAddress common.Address) (*big.Int, error)
+	EstimatePrivacyMarkerGas(from, precompileAddress common.Address, payloadSize int) (uint64, error)
+	DecreaseGasUsed(contractAddress common.Address, options *bind.TransactOpts, gasUsed *big.Int) (*common.Hash, error)
+
+	GetNodeGasLimit(contractAddress, nodeAddress common.Address) (*big.Int, error)
+	GetGasLimit(contractAddress, nodeAddress common.Address) (*big.Int, error)
+	GetMaxBlockGasLimit(contractAddress common.Address) (*big.Int, error)
+	GetCurrentGasLimit(contractAddress common.Address) (*big.Int, error)
+	GetGasUsedLastBlocks(contractAddress common.Address) (*big.Int, error)
+	GetNodes(contractAddress common.Address) (*big.Int, error)
+
+	AccountPermitted(contractAddress, senderAddress common.Address) (bool, error)
+	DestinationPermitted(contractAddress, targetAddress common.Address) (bool, error)
+	TransactionAllowed(contractAddress, senderAddress, targetAddress common.Address, value, gasPrice *big.Int, gasLimit uint64, payload []byte) (bool, error)
+	GetAccounts(contractAddress common.Address) ([]common.Address, error)
+	GetTargets(contractAddress common.Address) ([]common.Address, error)
+
+	SubscribeNewHeads(headers chan<- *types.Header) (ethereum.Subscription, error)
+	SubscribeContractLogs(contractAddress common.Address, logs chan<- types.Log) (ethereum.Subscription, error)
+	Close()
+}
+
+// ChainDialer opens the ChainBackend of the node at url, application.nodeURL for requests and application.wsURL for
+// subscriptions. The caller closes it when done
+type ChainDialer func(url string) (ChainBackend, error)
+
+// NewRelaySignerService creates a service reaching the chain through dial, the configured nodes when dial is nil
+func NewRelaySignerService(dial ChainDialer) *RelaySignerService {
+	return &RelaySignerService{dial: dial}
+}
+
+// chain opens the backend of requests on application.nodeURL, reading from the proxy upstreams when they are set
+func (service *RelaySignerService) chain() (ChainBackend, error) {
+	if service.dial != nil {
+		return service.dial(service.Config.Application.NodeURL)
+	}
+	client, err := service.connect()
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// websocket opens the backend of subscriptions on application.wsURL
+func (service *RelaySignerService) websocket() (ChainBackend, error) {
+	if service.dial != nil {
+		return service.dial(service.Config.Application.WSURL)
+	}
+	client := new(bl.Client)
+	err := client.Connect(service.Config.Application.WSURL)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const DEFAULT_DEDUPLICATION_WINDOW int64 = 600
+
+// DEDUPLICATION_RETRY_AFTER is the retry hint of a submission that stopped waiting for an earlier one still relayed
+const DEDUPLICATION_RETRY_AFTER = time.Second
+
+// relayedTransaction is a raw transaction submitted within the deduplication window
+type relayedTransaction struct {
+	transaction model.RelayedTransaction
+	// done is closed once the first submission was relayed or rejected
+	done    chan struct{}
+	relayed bool
+}
+
+type expiry struct {
+	hash common.Hash
+	at   time.Time
+}
+
+// relayedTransactions maps the hash of the raw transactions relayed within the window to their relay transaction hash,
+// so a client retrying a submission gets the original relay transaction instead of paying for a second one
+type relayedTransactions struct {
+	mutex        sync.Mutex
+	window       time.Duration
+	transactions map[common.Hash]*relayedTransaction
+	expiries     []expiry
+}
+
+func newRelayedTransactions(config model.DeduplicationConfig) *relayedTransactions {
+	if !config.Enabled {
+		return nil
+	}
+	window := config.Window
+	if window <= 0 {
+		window = DEFAULT_DEDUPLICATION_WINDOW
+	}
+	return &relayedTransactions{window: time.Duration(window) * time.Second, transactions: make(map[common.Hash]*relayedTransaction)}
+}
+
+// prune forgets the transactions relayed before the window, the mutex must be held
+func (relayed *relayedTransactions) prune(now time.Time) {
+	for len(relayed.expiries) > 0 && now.Sub(relayed.expiries[0].at) > relayed.window {
+		first := relayed.expiries[0]
+		if transaction, ok := relayed.transactions[first.hash]; ok && transaction.relayed && transaction.transaction.Time.Equal(first.at) {
+			delete(relayed.transactions, first.hash)
+		}
+		relayed.expiries = relayed.expiries[1:]
+	}
+}
+
+// claim returns the relay transaction hash of an earlier submission of hash, waiting for one in progress until ctx
+// is done or the window elapses, or nil when the caller must relay it
+func (relayed *relayedTransactions) claim(ctx context.Context, hash common.Hash, now time.Time) (*common.Hash, error) {
+	ctx, cancel := context.WithTimeout(ctx, relayed.window)
+	defer cancel()
+
+	for {
+		relayed.mutex.Lock()
+		relayed.prune(now)
+		transaction, ok := relayed.transactions[hash]
+		if !ok {
+			relayed.transactions[hash] = &relayedTransaction{transaction: model.RelayedTransaction{Hash: hash}, done: make(chan struct{})}
+			relayed.mutex.Unlock()
+			return nil, nil
+		}
+		if transaction.relayed {
+			transaction.transaction.Duplicates++
+			relayHash := transaction.transaction.RelayHash
+			relayed.mutex.Unlock()
+			return &relayHash, nil
+		}
+		relayed.mutex.Unlock()
+
+		// the first submission is still being relayed, a rejected one is removed and this one is relayed instead
+		select {
+		case <-transaction.done:
+		case <-ctx.Done():
+			return nil, &RateLimitError{message: "raw transaction is still being relayed by an earlier submission", RetryAfter: DEDUPLICATION_RETRY_AFTER}
+		}
+	}
+}
+
+// complete records the relay transaction of hash, or forgets it when relayHash is nil because it was rejected
+func (relayed *relayedTransactions) complete(hash common.Hash, relayHash *common.Hash, now time.Time) {
+	relayed.mutex.Lock()
+	defer relayed.mutex.Unlock()
+
+	transaction, ok := relayed.transactions[hash]
+	if !ok || transaction.relayed {
+		return
+	}
+	if relayHash == nil {
+		delete(relayed.transactions, hash)
+	} else {
+		transaction.relayed = true
+		transaction.transaction.RelayHash = *relayHash
+		transaction.transaction.Time = now
+		relayed.expiries = append(relayed.expiries, expiry{hash: hash, at: now})
+	}
+	close(transaction.done)
+}
+
+func (relayed *relayedTransactions) find(hash common.Hash, now time.Time) (*model.RelayedTransaction, bool) {
+	relayed.mutex.Lock()
+	defer relayed.mutex.Unlock()
+
+	relayed.prune(now)
+	transaction, ok := relayed.transactions[hash]
+	if !ok || !transaction.relayed {
+		return nil, false
+	}
+	result := transaction.transaction
+	return &result, true
+}
+
+// ClaimTransaction returns the relay transaction hash of an earlier submission of the raw transaction hash within the
+// deduplication window, waiting for one still in progress while ctx isn't done. Otherwise it returns nil, the caller
+// relays the transaction and reports the outcome with CompleteTransaction. A RateLimitError is returned when ctx is done
+// before the submission in progress completes.
+func (service *RelaySignerService) ClaimTransaction(ctx context.Context, hash common.Hash) (*common.Hash, error) {
+	if service.relayed == nil {
+		return nil, nil
+	}
+	relayHash, err := service.relayed.claim(ctx, hash, time.Now())
+	if err != nil {
+		log.GeneralLogger.Println("raw transaction", hash.Hex(), "stopped waiting for an earlier submission:", err)
+		return nil, err
+	}
+	if relayHash != nil {
+		log.GeneralLogger.Println("raw transaction", hash.Hex(), "was already relayed in", relayHash.Hex())
+	}
+	return relayHash, nil
+}
+
+// CompleteTransaction records the relay transaction hash of a claimed raw transaction, nil when it was rejected
+func (service *RelaySignerService) CompleteTransaction(hash common.Hash, relayHash *common.Hash) {
+	if service.relayed == nil {
+		return
+	}
+	service.relayed.complete(hash, relayHash, time.Now())
+}
+
+// GetRelayedTransaction returns the relay transaction of a raw transaction hash relayed within the deduplication window
+func (service *RelaySignerService) GetRelayedTransaction(hash common.Hash) (*model.RelayedTransaction, bool) {
+	if service.relayed == nil {
+		return nil, false
+	}
+	return service.relayed.find(hash, time.Now())
+}
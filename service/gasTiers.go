@@ -0,0 +1,130 @@
+package service
+
+import (
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+// tierGasUsed is the gas used in the current block by the tenants of each tier, reset with GAS_LIMIT
+var tierGasUsed = make(map[string]uint64)
+
+// gasTier reserves a share of the node allowance of every block for its tenants
+type gasTier struct {
+	name          string
+	priority      int
+	reservedShare float64
+}
+
+// gasTiers maps the tenants sharing the writer node to their tier, tenants without one share the unnamed default tier
+type gasTiers struct {
+	tiers   []*gasTier
+	tenants map[string]*gasTier
+}
+
+// newGasTiers builds the tiers of config, already checked by config.Validate
+func newGasTiers(config []model.TierConfig) *gasTiers {
+	tiers := &gasTiers{tenants: make(map[string]*gasTier)}
+	for _, tierConfig := range config {
+		tier := &gasTier{name: tierConfig.Name, priority: tierConfig.Priority, reservedShare: tierConfig.ReservedShare}
+		tiers.tiers = append(tiers.tiers, tier)
+		for _, tenant := range tierConfig.Tenants {
+			tiers.tenants[tenant] = tier
+		}
+	}
+	return tiers
+}
+
+// tier returns the tier of tenant, the tier of "*" for unlisted tenants and unauthenticated requests, or nil
+func (tiers *gasTiers) tier(tenant string) *gasTier {
+	if tiers == nil {
+		return nil
+	}
+	if tier, ok := tiers.tenants[tenant]; ok && tenant != "" {
+		return tier
+	}
+	return tiers.tenants[ANY_TENANT]
+}
+
+func (tiers *gasTiers) name(tenant string) string {
+	if tier := tiers.tier(tenant); tier != nil {
+		return tier.name
+	}
+	return ""
+}
+
+func (tiers *gasTiers) priority(tenant string) int {
+	if tier := tiers.tier(tenant); tier != nil {
+		return tier.priority
+	}
+	return 0
+}
+
+// reservations is the gas of nodeGasLimit reserved for each tier
+func (tiers *gasTiers) reservations(nodeGasLimit uint64) map[string]uint64 {
+	reservations := make(map[string]uint64)
+	if tiers == nil {
+		return reservations
+	}
+	for _, tier := range tiers.tiers {
+		reservations[tier.name] = uint64(tier.reservedShare * float64(nodeGasLimit))
+	}
+	return reservations
+}
+
+// reserveGas adds gasLimit to the gas used in the current block when it fits in nodeGasLimit without taking the part of
+// the other tiers reservations they haven't used yet. A rejected transaction uses nothing.
+func (service *RelaySignerService) reserveGas(gasLimit uint64, nodeGasLimit uint64, tier string) bool {
+	reservations := service.tiers.reservations(nodeGasLimit)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	var reservedByOthers uint64
+	for name, reserved := range reservations {
+		if name != tier && tierGasUsed[name] < reserved {
+			reservedByOthers += reserved - tierGasUsed[name]
+		}
+	}
+	if GAS_LIMIT+gasLimit+reservedByOthers > nodeGasLimit {
+		log.GeneralLogger.Println("gasLimit", gasLimit, "of tier", tier, "exceeds the allowance left, used:", GAS_LIMIT, "reserved by other tiers:", reservedByOthers)
+		return false
+	}
+
+	GAS_LIMIT = GAS_LIMIT + gasLimit
+	tierGasUsed[tier] += gasLimit
+	log.GeneralLogger.Println("gasLimit used in currently block:", GAS_LIMIT)
+	return true
+}
+
+// fitsEmptyBlock reports whether gasLimit fits in nodeGasLimit in a block where no transaction was relayed yet
+func (service *RelaySignerService) fitsEmptyBlock(gasLimit uint64, nodeGasLimit uint64, tier string) bool {
+	var reservedByOthers uint64
+	for name, reserved := range service.tiers.reservations(nodeGasLimit) {
+		if name != tier {
+			reservedByOthers += reserved
+		}
+	}
+	return gasLimit+reservedByOthers <= nodeGasLimit
+}
+
+// tierStatus returns the reservation and the gas used in the current block of every tier
+func (service *RelaySignerService) tierStatus(nodeGasLimit uint64) []model.TierStatus {
+	if service.tiers == nil {
+		return nil
+	}
+	reservations := service.tiers.reservations(nodeGasLimit)
+
+	lock.Lock()
+	defer lock.Unlock()
+	status := make([]model.TierStatus, 0, len(service.tiers.tiers))
+	for _, tier := range service.tiers.tiers {
+		status = append(status, model.TierStatus{
+			Name:           tier.name,
+			Priority:       tier.priority,
+			ReservedShare:  tier.reservedShare,
+			ReservedGas:    reservations[tier.name],
+			GasUsedInBlock: tierGasUsed[tier.name],
+		})
+	}
+	return status
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const STATUS_READY = "ready"
+const STATUS_NOT_READY = "not ready"
+const STATUS_ALIVE = "alive"
+
+const DEFAULT_MAX_HEADER_AGE int64 = 60
+
+var lastHeader *types.Header
+var lastHeaderTime time.Time
+
+// CheckLiveness reports the process is up and serving requests
+func (service *RelaySignerService) CheckLiveness() *model.HealthStatus {
+	return &model.HealthStatus{Status: STATUS_ALIVE}
+}
+
+// CheckReadiness verifies every dependency needed to relay a transaction
+func (service *RelaySignerService) CheckReadiness() *model.HealthStatus {
+	checks := []model.HealthCheck{
+		service.checkSignerKey(),
+		service.checkRelayHub(),
+		service.checkNode(),
+		service.checkSubscription(),
+		service.checkGasAllowance(),
+	}
+
+	status := &model.HealthStatus{Status: STATUS_READY, Checks: checks}
+	for _, check := range checks {
+		if !check.Healthy {
+			status.Status = STATUS_NOT_READY
+		}
+	}
+
+	return status
+}
+
+func (service *RelaySignerService) checkSignerKey() model.HealthCheck {
+	check := model.HealthCheck{Name: "signerKey"}
+
+	nodeAddress, err := service.getNodeAddress()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Healthy = true
+	check.Detail = nodeAddress.Hex()
+	return check
+}
+
+func (service *RelaySignerService) checkRelayHub() model.HealthCheck {
+	check := model.HealthCheck{Name: "relayHub"}
+
+	relayHubAddress := service.relayHubAddress()
+	if relayHubAddress == nil {
+		check.Detail = "relayHub address was not resolved from proxy"
+		return check
+	}
+
+	check.Healthy = true
+	check.Detail = relayHubAddress.Hex()
+	return check
+}
+
+func (service *RelaySignerService) checkNode() model.HealthCheck {
+	check := model.HealthCheck{Name: "node"}
+
+	client, err := service.chain()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	defer client.Close()
+
+	header, err := client.GetLatestHeader()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Healthy = true
+	check.Detail = fmt.Sprintf("latest block %s", header.Number)
+	return check
+}
+
+func (service *RelaySignerService) checkSubscription() model.HealthCheck {
+	check := model.HealthCheck{Name: "subscription"}
+
+	header, receivedAt := getLastHeader()
+	if header == nil {
+		check.Detail = "no block header received from websocket yet"
+		return check
+	}
+
+	maxHeaderAge := service.Config.Health.MaxHeaderAge
+	if maxHeaderAge <= 0 {
+		maxHeaderAge = DEFAULT_MAX_HEADER_AGE
+	}
+
+	age := time.Since(receivedAt)
+	check.Detail = fmt.Sprintf("last block %s received %ds ago", header.Number, int64(age.Seconds()))
+	if age > time.Duration(maxHeaderAge)*time.Second {
+		return check
+	}
+
+	check.Healthy = true
+	return check
+}
+
+func (service *RelaySignerService) checkGasAllowance() model.HealthCheck {
+	check := model.HealthCheck{Name: "gasAllowance"}
+
+	relayHubAddress := service.relayHubAddress()
+	if relayHubAddress == nil {
+		check.Detail = "relayHub address is unknown"
+		return check
+	}
+
+	nodeAddress, err := service.getNodeAddress()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	client, err := service.chain()
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	defer client.Close()
+
+	nodeGasLimit, err := client.GetNodeGasLimit(*relayHubAddress, nodeAddress)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	used := getGasUsed()
+	var remaining uint64
+	if nodeGasLimit.Uint64() > used {
+		remaining = nodeGasLimit.Uint64() - used
+	}
+
+	check.Detail = fmt.Sprintf("remaining %d of %d", remaining, nodeGasLimit.Uint64())
+	if remaining < service.Config.Health.MinGasAllowance {
+		return check
+	}
+
+	check.Healthy = true
+	return check
+}
+
+func (service *RelaySignerService) getNodeAddress() (common.Address, error) {
+	privateKey, err := crypto.HexToECDSA(service.Config.Application.Key)
+	if err != nil {
+		return common.Address{}, errors.FailedKeyConfig.New("Invalid ECDSA Key", -32602)
+	}
+
+	publicKeyECDSA, ok := privateKey.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return common.Address{}, errors.FailedKeyConfig.New("Invalid ECDSA Public Key", -32602)
+	}
+
+	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
+}
+
+func setLastHeader(header *types.Header) {
+	lock.Lock()
+	defer lock.Unlock()
+	lastHeader = header
+	lastHeaderTime = time.Now()
+}
+
+func getLastHeader() (*types.Header, time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+	return lastHeader, lastHeaderTime
+}
+
+func getGasUsed() uint64 {
+	lock.Lock()
+	defer lock.Unlock()
+	return GAS_LIMIT
+}
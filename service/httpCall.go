@@ -10,75 +10,49 @@ import (
 
 	"github.com/LACNetNetworks/gas-relay-signer/audit"
 	"github.com/LACNetNetworks/gas-relay-signer/rpc"
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/common"
 )
 
-const DATA_CALL_RELAYHUB = "0x7bdf2ec7"
-
-func isPoolEmpty(rpcURL string, id json.RawMessage) (bool, error) {
-	data := fmt.Sprintf(`{"jsonrpc":"2.0","method":"txpool_besuTransactions",
-	"params":[], "id":"%s"}`, id)
-
-	requestBody := []byte(data)
-
-	timeout := time.Duration(5 * time.Second)
-	client := http.Client{
-		Timeout: timeout,
-	}
-
-	request, err := http.NewRequest("POST", rpcURL, bytes.NewBuffer(requestBody))
-	request.Header.Set("Content-type", "application/json")
-
+// callNode sends a JSON-RPC request to the node and returns its result
+func callNode(rpcURL string, method string, params []interface{}, id json.RawMessage) (json.RawMessage, error) {
+	requestBody, err := json.Marshal(rpc.JsonrpcMessage{Version: "2.0", ID: id, Method: method, Params: mustMarshal(params)})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	response, err := client.Do(request)
-	if err != nil {
-		return false, err
+	client := http.Client{
+		Timeout: 5 * time.Second,
 	}
 
-	defer response.Body.Close()
-
-	body, err := ioutil.ReadAll(response.Body)
-
+	response, err := client.Post(rpcURL, "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	rdr1 := ioutil.NopCloser(bytes.NewBuffer(body))
+	defer response.Body.Close()
 
 	var rpcMessage rpc.JsonrpcMessage
-
-	err = json.NewDecoder(rdr1).Decode(&rpcMessage)
+	err = json.NewDecoder(response.Body).Decode(&rpcMessage)
 	if err != nil {
-		return false, err
-	}
-
-	audit.GeneralLogger.Println("Transactions in pool:", rpcMessage.String())
-
-	var v []json.RawMessage
-	err = json.Unmarshal(rpcMessage.Result, &v)
-	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	if len(v) > 0 {
-		return false, nil
+	if rpcMessage.Error != nil {
+		return nil, rpcMessage.Error
 	}
 
-	return true, nil
+	return rpcMessage.Result, nil
 }
 
-func getRelayHubContractAddress(rpcURL string, id string, relayHubProxyAddress string, _timeout int) (*common.Address, error) {
-	data := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_call","params":[{"to":"%s","data":"%s"},"latest"], "id":"%s"}`, relayHubProxyAddress, DATA_CALL_RELAYHUB, id)
+func mustMarshal(value interface{}) json.RawMessage {
+	data, _ := json.Marshal(value)
+	return data
+}
 
-	fmt.Println(data)
+func isPoolEmpty(rpcURL string, id json.RawMessage) (bool, error) {
+	data := fmt.Sprintf(`{"jsonrpc":"2.0","method":"txpool_besuTransactions",
+	"params":[], "id":"%s"}`, id)
 
 	requestBody := []byte(data)
 
-	timeout := time.Duration(time.Duration(_timeout) * time.Second)
+	timeout := time.Duration(5 * time.Second)
 	client := http.Client{
 		Timeout: timeout,
 	}
@@ -87,12 +61,12 @@ func getRelayHubContractAddress(rpcURL string, id string, relayHubProxyAddress s
 	request.Header.Set("Content-type", "application/json")
 
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	response, err := client.Do(request)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	defer response.Body.Close()
@@ -100,7 +74,7 @@ func getRelayHubContractAddress(rpcURL string, id string, relayHubProxyAddress s
 	body, err := ioutil.ReadAll(response.Body)
 
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	rdr1 := ioutil.NopCloser(bytes.NewBuffer(body))
@@ -109,28 +83,20 @@ func getRelayHubContractAddress(rpcURL string, id string, relayHubProxyAddress s
 
 	err = json.NewDecoder(rdr1).Decode(&rpcMessage)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	var resultData string = string(rpcMessage.Result)
-
-	responseData := common.Hex2Bytes(resultData[3 : len(resultData)-1])
+	audit.GeneralLogger.Println("Transactions in pool:", rpcMessage.String())
 
-	addressPacked, err := abi.NewType("address", "", nil)
+	var v []json.RawMessage
+	err = json.Unmarshal(rpcMessage.Result, &v)
 	if err != nil {
-		fmt.Println(err)
-	}
-
-	resultPayloadPacked := abi.Arguments{
-		{Type: addressPacked},
+		return false, err
 	}
 
-	addressUnpacked, err := resultPayloadPacked.UnpackValues(responseData)
-	if err != nil {
-		fmt.Println(err)
+	if len(v) > 0 {
+		return false, nil
 	}
 
-	relayHubAddress := addressUnpacked[0].(common.Address)
-
-	return &relayHubAddress, nil
+	return true, nil
 }
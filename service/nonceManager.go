@@ -0,0 +1,27 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// nonceManager serializes the sends of the writer account so each one gets the pending nonce left by the previous one
+type nonceManager struct {
+	lock sync.Mutex
+}
+
+// acquire locks the manager and returns the pending nonce of the writer, release must be called once the transaction is sent
+func (manager *nonceManager) acquire(client ChainBackend, from common.Address) (uint64, error) {
+	manager.lock.Lock()
+	nonce, err := client.GetPendingNonce(from)
+	if err != nil {
+		manager.lock.Unlock()
+		return 0, err
+	}
+	return nonce, nil
+}
+
+func (manager *nonceManager) release() {
+	manager.lock.Unlock()
+}
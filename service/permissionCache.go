@@ -0,0 +1,242 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const DEFAULT_PERMISSIONS_CACHE_STALENESS int64 = 300
+const PERMISSIONS_RESUBSCRIBE_DELAY = 5 * time.Second
+
+// permissionCache mirrors the allowed accounts and targets of the permissioning contract.
+// It is considered in sync while the event subscription that feeds it is alive.
+type permissionCache struct {
+	mutex    sync.RWMutex
+	accounts map[common.Address]bool
+	targets  map[common.Address]bool
+	syncedAt time.Time
+}
+
+func newPermissionCache() *permissionCache {
+	return &permissionCache{accounts: make(map[common.Address]bool), targets: make(map[common.Address]bool)}
+}
+
+func (cache *permissionCache) seed(accounts, targets []common.Address) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.accounts = make(map[common.Address]bool, len(accounts))
+	for _, account := range accounts {
+		cache.accounts[account] = true
+	}
+	cache.targets = make(map[common.Address]bool, len(targets))
+	for _, target := range targets {
+		cache.targets[target] = true
+	}
+	cache.syncedAt = time.Now()
+}
+
+func (cache *permissionCache) invalidate() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.syncedAt = time.Time{}
+}
+
+func (cache *permissionCache) setAccount(account common.Address, permitted bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if permitted {
+		cache.accounts[account] = true
+	} else {
+		delete(cache.accounts, account)
+	}
+}
+
+func (cache *permissionCache) setTarget(target common.Address, permitted bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if permitted {
+		cache.targets[target] = true
+	} else {
+		delete(cache.targets, target)
+	}
+}
+
+// accountPermitted answers from the cache, the second value is false when the cache is too stale to be used
+func (cache *permissionCache) accountPermitted(account common.Address, staleness time.Duration) (bool, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	if cache.syncedAt.IsZero() || time.Since(cache.syncedAt) > staleness {
+		return false, false
+	}
+	return cache.accounts[account], true
+}
+
+// destinationPermitted answers from the cache, the second value is false when the cache is too stale to be used
+func (cache *permissionCache) destinationPermitted(target common.Address, staleness time.Duration) (bool, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	if cache.syncedAt.IsZero() || time.Since(cache.syncedAt) > staleness {
+		return false, false
+	}
+	return cache.targets[target], true
+}
+
+func (service *RelaySignerService) permissionsStaleness() time.Duration {
+	staleness := service.Config.Security.PermissionsCacheStaleness
+	if staleness <= 0 {
+		staleness = DEFAULT_PERMISSIONS_CACHE_STALENESS
+	}
+	return time.Duration(staleness) * time.Second
+}
+
+// ProcessPermissionEvents keeps the permission cache in sync with the permissioning contract events
+func (service *RelaySignerService) ProcessPermissionEvents(done <-chan interface{}) {
+	if service.permissions == nil {
+		return
+	}
+
+	for {
+		err := service.watchPermissionEvents(done)
+		if err == nil {
+			log.GeneralLogger.Println("quit signal received...exiting from processing permission events")
+			return
+		}
+
+		service.permissions.invalidate()
+		log.ErrorLogger.Println("permission events subscription failed, lookups fall back to the contract:", err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(PERMISSIONS_RESUBSCRIBE_DELAY):
+		}
+	}
+}
+
+func (service *RelaySignerService) watchPermissionEvents(done <-chan interface{}) error {
+	client, err := service.websocket()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	contractAddress := common.HexToAddress(service.Config.Security.AccountContractAddress)
+
+	// the logs are only parsed, the filterer needs no backend
+	filterer, err := relay.NewAccountFilterer(contractAddress, nil)
+	if err != nil {
+		return errors.FailedContract.Wrapf(err, "can't instance AccountRules filterer", -32603)
+	}
+
+	// subscribe before seeding so changes between both steps are not lost
+	logs := make(chan types.Log)
+	subscription, err := client.SubscribeContractLogs(contractAddress, logs)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	err = service.syncPermissions(client, contractAddress)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(service.permissionsStaleness() / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-subscription.Err():
+			return subscriptionError(err)
+		case event := <-logs:
+			if err := service.applyPermissionEvent(filterer, event); err != nil {
+				log.ErrorLogger.Println("invalid permission event in transaction", event.TxHash.Hex(), ":", err)
+			}
+		case <-ticker.C:
+			// events can be missed without the subscription failing, a cache that can't be reloaded goes stale
+			if err := service.syncPermissions(client, contractAddress); err != nil {
+				log.ErrorLogger.Println("permission cache resync failed:", err)
+			}
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// applyPermissionEvent updates the permission cache with an AccountRules log, other events are ignored
+func (service *RelaySignerService) applyPermissionEvent(filterer *relay.AccountFilterer, event types.Log) error {
+	if len(event.Topics) == 0 {
+		return nil
+	}
+
+	switch event.Topics[0] {
+	case accountRulesABI.Events["AccountAdded"].ID:
+		added, err := filterer.ParseAccountAdded(event)
+		if err != nil || !added.AccountAdded {
+			return err
+		}
+		log.GeneralLogger.Println("account added to permission cache:", added.AccountAddress.Hex())
+		service.permissions.setAccount(added.AccountAddress, true)
+	case accountRulesABI.Events["AccountRemoved"].ID:
+		removed, err := filterer.ParseAccountRemoved(event)
+		if err != nil || !removed.AccountRemoved {
+			return err
+		}
+		log.GeneralLogger.Println("account removed from permission cache:", removed.AccountAddress.Hex())
+		service.permissions.setAccount(removed.AccountAddress, false)
+	case accountRulesABI.Events["TargetAdded"].ID:
+		added, err := filterer.ParseTargetAdded(event)
+		if err != nil || !added.TargetAdded {
+			return err
+		}
+		log.GeneralLogger.Println("target added to permission cache:", added.AccountAddress.Hex())
+		service.permissions.setTarget(added.AccountAddress, true)
+	case accountRulesABI.Events["TargetRemoved"].ID:
+		removed, err := filterer.ParseTargetRemoved(event)
+		if err != nil || !removed.TargetRemoved {
+			return err
+		}
+		log.GeneralLogger.Println("target removed from permission cache:", removed.AccountAddress.Hex())
+		service.permissions.setTarget(removed.AccountAddress, false)
+	}
+	return nil
+}
+
+var accountRulesABI, _ = abi.JSON(strings.NewReader(relay.AccountABI))
+
+// permissionsReader reads the allowed accounts and targets of the permissioning contract
+type permissionsReader interface {
+	GetAccounts(contractAddress common.Address) ([]common.Address, error)
+	GetTargets(contractAddress common.Address) ([]common.Address, error)
+}
+
+// syncPermissions reloads the allowed accounts and targets, the cache is only in sync after a successful reload
+func (service *RelaySignerService) syncPermissions(client permissionsReader, contractAddress common.Address) error {
+	accounts, err := client.GetAccounts(contractAddress)
+	if err != nil {
+		return err
+	}
+	targets, err := client.GetTargets(contractAddress)
+	if err != nil {
+		return err
+	}
+
+	service.permissions.seed(accounts, targets)
+	log.GeneralLogger.Printf("permission cache synced with %d accounts and %d targets", len(accounts), len(targets))
+	return nil
+}
+
+func subscriptionError(err error) error {
+	if err == nil {
+		return errors.FailedConnection.New("permission events subscription was closed", -32100)
+	}
+	return err
+}
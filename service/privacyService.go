@@ -0,0 +1,342 @@
+package service
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	bl "github.com/LACNetNetworks/gas-relay-signer/blockchain"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const DEFAULT_PRIVACY_PRECOMPILE_ADDRESS = "0x000000000000000000000000000000000000007e"
+const DEFAULT_FLEXIBLE_PRIVACY_PRECOMPILE_ADDRESS = "0x000000000000000000000000000000000000007c"
+const ENCLAVE_KEY_SIZE = 32
+const DEFAULT_ACCOUNTING_RETRIES = 3
+const DEFAULT_ACCOUNTING_RETRY_DELAY int64 = 500
+const DEFAULT_ACCOUNTING_QUEUE_SIZE = 1000
+const ANY_TENANT = "*"
+const PRIVACY_GROUP_NOT_ALLOWED_ERROR_CODE = -32613
+
+// privacyGroups are the privacy groups allowed per tenant
+type privacyGroups map[string]map[string]bool
+
+func newPrivacyGroups(config model.PrivacyConfig) (privacyGroups, error) {
+	groups := make(privacyGroups)
+	for _, entry := range config.Groups {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.FailedKeyConfig.New("privacy groups must be configured as tenant:groupId", -32602)
+		}
+		if groups[parts[0]] == nil {
+			groups[parts[0]] = make(map[string]bool)
+		}
+		groups[parts[0]][parts[1]] = true
+	}
+	return groups, nil
+}
+
+// PrivacyGroupAllowed reports whether the tenant may use the privacy group, always true when groups are not enforced
+func (service *RelaySignerService) PrivacyGroupAllowed(tenant *model.Tenant, group string) bool {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+
+	if !service.Config.Privacy.EnforceGroups {
+		return true
+	}
+	if service.privacyGroups[ANY_TENANT][group] {
+		return true
+	}
+	return tenant != nil && service.privacyGroups[tenant.ID][group]
+}
+
+// PrivacyGroupsEnforced reports whether tenants are restricted to the privacy groups listed for them
+func (service *RelaySignerService) PrivacyGroupsEnforced() bool {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+	return service.Config.Privacy.EnforceGroups
+}
+
+// PrivateTransactionGroup returns the privacy group of the private transaction of a privacy marker transaction, found is
+// false when the node doesn't know the transaction
+func (service *RelaySignerService) PrivateTransactionGroup(hash string, id json.RawMessage) (group string, found bool, err error) {
+	result, err := callNode(service.Config.Application.NodeURL, "priv_getPrivateTransaction", []interface{}{hash}, id)
+	if err != nil {
+		return "", false, errors.CallBlockchainFailed.Wrapf(err, "failed to get private transaction %s", -32603, hash)
+	}
+
+	var tx struct {
+		PrivateFrom    []byte   `json:"privateFrom"`
+		PrivateFor     [][]byte `json:"privateFor"`
+		PrivacyGroupID []byte   `json:"privacyGroupId"`
+	}
+	if string(result) == "null" || len(result) == 0 {
+		return "", false, nil
+	}
+	if err := json.Unmarshal(result, &tx); err != nil {
+		return "", false, errors.CallBlockchainFailed.Wrapf(err, "invalid private transaction %s", -32603, hash)
+	}
+
+	return PrivacyGroup(&model.PrivateTransaction{PrivateFrom: tx.PrivateFrom, PrivateFor: tx.PrivateFor, PrivacyGroupID: tx.PrivacyGroupID}), true, nil
+}
+
+// VerifyPrivateTransaction applies the sender permissioning of public transactions and the privacy group allow-list
+func (service *RelaySignerService) VerifyPrivateTransaction(tx *model.PrivateTransaction, tenant *model.Tenant, id json.RawMessage) error {
+	if service.Config.Security.PermissionsEnabled {
+		isSenderPermitted, err := service.VerifySender(tx.From, id)
+		if err != nil {
+			return err
+		}
+		if !isSenderPermitted {
+			return errors.NotPermitted.New("account sender is not permitted to send transactions", -32603)
+		}
+
+		err = service.VerifyTransaction(tx.From, tx.To, tx.Value, tx.GasPrice, tx.GasLimit, tx.Data, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	group := PrivacyGroup(tx)
+	if !service.PrivacyGroupAllowed(tenant, group) {
+		log.GeneralLogger.Println("privacy group", group, "is not allowed for tenant", tenant)
+		return errors.NotPermitted.New("privacy group is not allowed", PRIVACY_GROUP_NOT_ALLOWED_ERROR_CODE)
+	}
+
+	return nil
+}
+
+// EstimatePrivateTransactionGas returns the gas of the privacy marker transaction the writer node sends for a private transaction
+func (service *RelaySignerService) EstimatePrivateTransactionGas() (uint64, error) {
+	client, err := service.chain()
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	nodeAddress, err := service.getNodeAddress()
+	if err != nil {
+		return 0, err
+	}
+
+	gas, err := client.EstimatePrivacyMarkerGas(nodeAddress, service.privacyPrecompileAddress(), ENCLAVE_KEY_SIZE)
+	if err != nil {
+		return 0, err
+	}
+
+	log.GeneralLogger.Println("estimated privacy marker transaction gas:", gas)
+	return gas, nil
+}
+
+// privateAccounting is the gas of a private transaction to charge in the RelayHub it was verified against
+type privateAccounting struct {
+	relayHub common.Address
+	record   model.RelayRecord
+}
+
+// AccountPrivateTransaction queues the gas of the privacy marker transaction of an accepted private transaction,
+// ProcessPrivateAccounting charges it to the writer node in relayHub so the client doesn't wait for the retries
+func (service *RelaySignerService) AccountPrivateTransaction(relayHub common.Address, record model.RelayRecord) {
+	select {
+	case service.accounting <- privateAccounting{relayHub: relayHub, record: record}:
+	default:
+		service.accountingFailed(record, errors.FailedTransaction.New("gas accounting queue is full", -32603))
+	}
+}
+
+// ProcessPrivateAccounting charges the queued private transactions to the writer node until done is closed
+func (service *RelaySignerService) ProcessPrivateAccounting(done <-chan interface{}) {
+	for {
+		select {
+		case <-done:
+			log.GeneralLogger.Println("quit signal received...exiting from private transaction accounting")
+			return
+		case accounting := <-service.accounting:
+			err := service.chargePrivateTransaction(accounting.relayHub, accounting.record.GasLimit)
+			if err != nil {
+				service.accountingFailed(accounting.record, err)
+			}
+		}
+	}
+}
+
+// accountingFailed keeps the failure in the history for operators, the private transaction is already in the node
+func (service *RelaySignerService) accountingFailed(record model.RelayRecord, err error) {
+	log.ErrorLogger.Println("private transaction", record.TransactionHash, "was not accounted:", err)
+	record.Time = time.Now()
+	record.Error = err.Error()
+	service.RecordRelay(record)
+}
+
+// chargePrivateTransaction charges the gas of a privacy marker transaction to the writer node in relayHub, retrying failed sends
+func (service *RelaySignerService) chargePrivateTransaction(relayHub common.Address, gasUsed uint64) error {
+	retries := service.Config.Privacy.AccountingRetries
+	if retries <= 0 {
+		retries = DEFAULT_ACCOUNTING_RETRIES
+	}
+	delay := time.Duration(service.Config.Privacy.AccountingRetryDelay) * time.Millisecond
+	if delay <= 0 {
+		delay = time.Duration(DEFAULT_ACCOUNTING_RETRY_DELAY) * time.Millisecond
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.ErrorLogger.Println("gas accounting failed, retrying in", delay, ":", err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var hash *common.Hash
+		hash, err = service.sendGasUsed(relayHub, gasUsed)
+		if err == nil {
+			log.GeneralLogger.Println("private transaction gas accounted:", gasUsed, "tx:", hash.Hex())
+			return nil
+		}
+	}
+
+	return errors.FailedTransaction.Wrapf(err, "gas accounting of private transaction failed", -32603)
+}
+
+func (service *RelaySignerService) sendGasUsed(relayHub common.Address, gasUsed uint64) (*common.Hash, error) {
+	client, err := service.chain()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	privateKey, err := crypto.HexToECDSA(service.Config.Application.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := service.nonces.acquire(client, crypto.PubkeyToAddress(privateKey.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	// gas limit of the accounting transaction itself is estimated by the node
+	options := bl.NewTransactOpts(privateKey, 0, nonce)
+	hash, err := client.DecreaseGasUsed(relayHub, options, new(big.Int).SetUint64(gasUsed))
+	service.nonces.release()
+
+	return hash, err
+}
+
+func (service *RelaySignerService) privacyPrecompileAddress() common.Address {
+	if common.IsHexAddress(service.Config.Privacy.PrecompileAddress) {
+		return common.HexToAddress(service.Config.Privacy.PrecompileAddress)
+	}
+	return common.HexToAddress(DEFAULT_PRIVACY_PRECOMPILE_ADDRESS)
+}
+
+// IsFlexiblePrivateTransaction reports whether the private transaction must be relayed as a metatransaction to the flexible privacy precompile
+func (service *RelaySignerService) IsFlexiblePrivateTransaction(tx *model.PrivateTransaction) bool {
+	return service.Config.Privacy.FlexibleMetaTx && tx.PrivacyGroupID != nil
+}
+
+// FlexiblePrivacyMarker is a privacy marker transaction signed by the writer node with a reserved RelayHub nonce
+type FlexiblePrivacyMarker struct {
+	relayHub    common.Address
+	nonce       uint64
+	sender      common.Address
+	signingData []byte
+	v           uint8
+	r, s        [32]byte
+}
+
+// ReserveFlexiblePrivacyMarker signs the privacy marker transaction of a flexible privacy group with the next relayHub
+// nonce of the writer node and counts it as relayed, the caller holds the relay lock
+func (service *RelaySignerService) ReserveFlexiblePrivacyMarker(relayHub common.Address, enclaveKey []byte, gasLimit uint64) (*FlexiblePrivacyMarker, error) {
+	client, err := service.chain()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	privateKey, err := crypto.HexToECDSA(service.Config.Application.Key)
+	if err != nil {
+		return nil, err
+	}
+	nodeAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	chainID, err := client.GetChainID()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := service.relayNonce(client, relayHub, nodeAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	precompile := service.flexiblePrecompileAddress()
+	tx, err := types.SignTx(types.NewTransaction(nonce, precompile, big.NewInt(0), gasLimit, big.NewInt(0), enclaveKey), types.NewEIP155Signer(chainID), privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signingData, err := rlp.EncodeToBytes(model.NewTransaction(nonce, precompile, big.NewInt(0), gasLimit, big.NewInt(0), enclaveKey).Data)
+	if err != nil {
+		return nil, err
+	}
+
+	marker := &FlexiblePrivacyMarker{relayHub: relayHub, nonce: nonce, sender: nodeAddress, signingData: signingData}
+	v, rInt, sInt := tx.RawSignatureValues()
+	marker.v = uint8(v.Uint64())
+	copy(marker.r[32-len(rInt.Bytes()):], rInt.Bytes())
+	copy(marker.s[32-len(sInt.Bytes()):], sInt.Bytes())
+
+	service.incrementTransactionCount(relayHub, nodeAddress.Hex(), nonce)
+	return marker, nil
+}
+
+// SendFlexiblePrivacyMarker relays a reserved privacy marker transaction through the RelayHub it was reserved in, signed by the writer node
+// so its gas is charged to the node allowance as any other metatransaction
+func (service *RelaySignerService) SendFlexiblePrivacyMarker(id json.RawMessage, marker *FlexiblePrivacyMarker, metaTxGasLimit uint64) *rpc.JsonrpcMessage {
+	log.GeneralLogger.Println("relaying flexible privacy marker transaction with nonce", marker.nonce)
+
+	precompile := service.flexiblePrecompileAddress()
+	tx, err := service.relayMetatransaction(marker.relayHub, &precompile, metaTxGasLimit, marker.signingData, marker.v, marker.r, marker.s)
+	if err != nil {
+		return HandleError(id, err)
+	}
+
+	result := new(rpc.JsonrpcMessage)
+	result.ID = id
+	return result.Response(tx)
+}
+
+// ReleaseFlexiblePrivacyMarker forgets the RelayHub nonce counted for the writer node after a failed send, the next
+// marker reads it from the RelayHub again, the caller holds the relay lock
+func (service *RelaySignerService) ReleaseFlexiblePrivacyMarker(marker *FlexiblePrivacyMarker) {
+	service.forgetTransactionCount(marker.relayHub, marker.sender.Hex())
+}
+
+// relayNonce is the next relayHub nonce of a sender, following the transactions relayed since the last block
+func (service *RelaySignerService) relayNonce(client ChainBackend, relayHub common.Address, sender common.Address) (uint64, error) {
+	if count := service.pendingTransactionCount(relayHub, sender.Hex()); count != nil {
+		return count.Uint64() + 1, nil
+	}
+
+	count, err := client.GetTransactionCount(relayHub, sender, sender)
+	if err != nil {
+		return 0, err
+	}
+	return count.Uint64(), nil
+}
+
+func (service *RelaySignerService) flexiblePrecompileAddress() common.Address {
+	if common.IsHexAddress(service.Config.Privacy.FlexiblePrecompile) {
+		return common.HexToAddress(service.Config.Privacy.FlexiblePrecompile)
+	}
+	return common.HexToAddress(DEFAULT_FLEXIBLE_PRIVACY_PRECOMPILE_ADDRESS)
+}
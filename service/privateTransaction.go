@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"sort"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const PRIVATE_TRANSACTION_FIELDS = 12
+
+// GetPrivateTransaction decodes a signed private transaction and recovers its sender
+func GetPrivateTransaction(rawTx string) (*model.PrivateTransaction, error) {
+	rawTxBytes, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.MalformedRawTransaction.Wrapf(err, "Error Decoding Raw Private Transaction", -32012)
+	}
+
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(rawTxBytes, &fields); err != nil || len(fields) != PRIVATE_TRANSACTION_FIELDS {
+		return nil, errors.MalformedRawTransaction.New("private transaction must be a list of 12 fields", -32012)
+	}
+
+	tx := new(model.PrivateTransaction)
+	var to []byte
+	var v, r, s big.Int
+	var restriction []byte
+	decodings := []struct {
+		field int
+		value interface{}
+	}{
+		{0, &tx.Nonce}, {1, &tx.GasPrice}, {2, &tx.GasLimit}, {3, &to}, {4, &tx.Value}, {5, &tx.Data},
+		{6, &v}, {7, &r}, {8, &s}, {9, &tx.PrivateFrom}, {11, &restriction},
+	}
+	for _, decoding := range decodings {
+		if err := rlp.DecodeBytes(fields[decoding.field], decoding.value); err != nil {
+			return nil, errors.MalformedRawTransaction.Wrapf(err, "invalid private transaction field %d", -32012, decoding.field)
+		}
+	}
+	if len(to) > 0 {
+		address := common.BytesToAddress(to)
+		tx.To = &address
+	}
+	tx.Restriction = string(restriction)
+
+	// the recipients are either a list of enclave keys (privateFor) or a privacy group id
+	kind, _, _, err := rlp.Split(fields[10])
+	if err != nil {
+		return nil, errors.MalformedRawTransaction.Wrapf(err, "invalid private transaction recipients", -32012)
+	}
+	if kind == rlp.List {
+		err = rlp.DecodeBytes(fields[10], &tx.PrivateFor)
+	} else {
+		err = rlp.DecodeBytes(fields[10], &tx.PrivacyGroupID)
+	}
+	if err != nil {
+		return nil, errors.MalformedRawTransaction.Wrapf(err, "invalid private transaction recipients", -32012)
+	}
+
+	from, err := recoverPrivateTransactionSender(fields, &v, &r, &s)
+	if err != nil {
+		return nil, err
+	}
+	tx.From = from
+
+	return tx, nil
+}
+
+// recoverPrivateTransactionSender from the signature over the fields without v, r and s, with the chain id when EIP-155 is used
+func recoverPrivateTransactionSender(fields []rlp.RawValue, v, r, s *big.Int) (common.Address, error) {
+	preimage := []interface{}{fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]}
+
+	var recoveryID uint64
+	if v.Uint64() >= 35 {
+		chainID := new(big.Int).Div(new(big.Int).Sub(v, big.NewInt(35)), big.NewInt(2))
+		recoveryID = v.Uint64() - 35 - 2*chainID.Uint64()
+		preimage = append(preimage, chainID, uint(0), uint(0))
+	} else {
+		recoveryID = v.Uint64() - 27
+	}
+	preimage = append(preimage, fields[9], fields[10], fields[11])
+
+	encoded, err := rlp.EncodeToBytes(preimage)
+	if err != nil {
+		return common.Address{}, errors.MalformedRawTransaction.Wrapf(err, "can't encode private transaction", -32012)
+	}
+
+	if recoveryID > 1 || r.BitLen() > 256 || s.BitLen() > 256 {
+		return common.Address{}, errors.MalformedRawTransaction.New("bad signature ECDSA", -32012)
+	}
+	signature := make([]byte, 65)
+	copy(signature[32-len(r.Bytes()):32], r.Bytes())
+	copy(signature[64-len(s.Bytes()):64], s.Bytes())
+	signature[64] = byte(recoveryID)
+
+	publicKey, err := crypto.SigToPub(crypto.Keccak256(encoded), signature)
+	if err != nil {
+		return common.Address{}, errors.MalformedRawTransaction.Wrapf(err, "bad signature ECDSA", -32012)
+	}
+
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// PrivacyGroup returns the base64 privacy group id of the transaction, computing the legacy group of privateFrom and privateFor
+func PrivacyGroup(tx *model.PrivateTransaction) string {
+	if tx.PrivacyGroupID != nil {
+		return base64.StdEncoding.EncodeToString(tx.PrivacyGroupID)
+	}
+
+	// Besu sorts the distinct enclave keys by their Java array hash code
+	var keys [][]byte
+	for _, key := range append([][]byte{tx.PrivateFrom}, tx.PrivateFor...) {
+		duplicated := false
+		for _, existing := range keys {
+			if bytes.Equal(existing, key) {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return javaArrayHashCode(keys[i]) < javaArrayHashCode(keys[j]) })
+
+	encoded, _ := rlp.EncodeToBytes(keys)
+	return base64.StdEncoding.EncodeToString(crypto.Keccak256(encoded))
+}
+
+func javaArrayHashCode(data []byte) int32 {
+	var hash int32 = 1
+	for _, b := range data {
+		hash = 31*hash + int32(int8(b))
+	}
+	return hash
+}
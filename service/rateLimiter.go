@@ -0,0 +1,307 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const RATE_LIMIT_ERROR_CODE = -32005
+const MAX_TRACKED_KEYS = 10000
+
+// RateLimitError is returned when a client exceeds its limits, it carries a retry hint for the client
+type RateLimitError struct {
+	message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return e.message }
+
+// ErrorCode returns code
+func (e *RateLimitError) ErrorCode() int { return RATE_LIMIT_ERROR_CODE }
+
+// ErrorData returns the retry hint in seconds
+func (e *RateLimitError) ErrorData() interface{} {
+	return map[string]int64{"retryAfter": int64(math.Ceil(e.RetryAfter.Seconds()))}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per key refilled at rate tokens per second up to burst
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+func (limiter *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.rate)
+	bucket.last = now
+}
+
+// allow takes a token for key, when there is none it returns the time until the next one
+func (limiter *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		if len(limiter.buckets) >= MAX_TRACKED_KEYS {
+			limiter.prune(now)
+		}
+		bucket = &tokenBucket{tokens: limiter.burst, last: now}
+		limiter.buckets[key] = bucket
+	}
+	limiter.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// refund gives back a token taken by allow
+func (limiter *rateLimiter) refund(key string, now time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if bucket, ok := limiter.buckets[key]; ok {
+		limiter.refill(bucket, now)
+		bucket.tokens = math.Min(limiter.burst, bucket.tokens+1)
+	}
+}
+
+// prune forgets the keys whose bucket is full again
+func (limiter *rateLimiter) prune(now time.Time) {
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+		if bucket.tokens >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+func (limiter *rateLimiter) state(now time.Time) map[string]float64 {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	state := make(map[string]float64, len(limiter.buckets))
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+		state[key] = math.Floor(bucket.tokens*100) / 100
+	}
+	return state
+}
+
+type gasWindow struct {
+	start time.Time
+	used  uint64
+}
+
+// gasQuota limits the gas a key can request in a fixed window
+type gasQuota struct {
+	mutex   sync.Mutex
+	limit   uint64
+	window  time.Duration
+	windows map[string]*gasWindow
+}
+
+func newGasQuota(limit uint64, windowSeconds int64) *gasQuota {
+	if limit == 0 || windowSeconds <= 0 {
+		return nil
+	}
+	return &gasQuota{limit: limit, window: time.Duration(windowSeconds) * time.Second, windows: make(map[string]*gasWindow)}
+}
+
+func (quota *gasQuota) consume(key string, gas uint64, now time.Time) (bool, time.Duration) {
+	quota.mutex.Lock()
+	defer quota.mutex.Unlock()
+
+	window, ok := quota.windows[key]
+	if !ok || now.Sub(window.start) >= quota.window {
+		if !ok && len(quota.windows) >= MAX_TRACKED_KEYS {
+			quota.prune(now)
+		}
+		window = &gasWindow{start: now}
+		quota.windows[key] = window
+	}
+
+	if window.used+gas > quota.limit {
+		return false, window.start.Add(quota.window).Sub(now)
+	}
+	window.used += gas
+	return true, 0
+}
+
+// refund gives back gas consumed at consumedAt, unless its window is already over
+func (quota *gasQuota) refund(key string, gas uint64, consumedAt time.Time) {
+	quota.mutex.Lock()
+	defer quota.mutex.Unlock()
+
+	window, ok := quota.windows[key]
+	if !ok || window.start.After(consumedAt) {
+		return
+	}
+	if window.used < gas {
+		gas = window.used
+	}
+	window.used -= gas
+}
+
+func (quota *gasQuota) prune(now time.Time) {
+	for key, window := range quota.windows {
+		if now.Sub(window.start) >= quota.window {
+			delete(quota.windows, key)
+		}
+	}
+}
+
+func (quota *gasQuota) state(now time.Time) map[string]uint64 {
+	quota.mutex.Lock()
+	defer quota.mutex.Unlock()
+
+	state := make(map[string]uint64, len(quota.windows))
+	for key, window := range quota.windows {
+		if now.Sub(window.start) < quota.window {
+			state[key] = window.used
+		}
+	}
+	return state
+}
+
+// limits groups every configured limiter, a nil limiter means the dimension is not limited
+type limits struct {
+	ip       *rateLimiter
+	apiKey   *rateLimiter
+	sender   *rateLimiter
+	gasQuota *gasQuota
+}
+
+func newLimits(config model.RateLimitConfig) *limits {
+	if !config.Enabled {
+		return nil
+	}
+	return &limits{
+		ip:       newRateLimiter(config.IPRate, config.IPBurst),
+		apiKey:   newRateLimiter(config.APIKeyRate, config.APIKeyBurst),
+		sender:   newRateLimiter(config.SenderRate, config.SenderBurst),
+		gasQuota: newGasQuota(config.SenderGasQuota, config.GasQuotaWindow),
+	}
+}
+
+// AllowRequest applies the per IP and per API key limits to an incoming request
+func (service *RelaySignerService) AllowRequest(ip, apiKey string) error {
+	limits := service.currentLimits()
+	if limits == nil {
+		return nil
+	}
+	now := time.Now()
+
+	if limits.ip != nil && ip != "" {
+		if ok, retryAfter := limits.ip.allow(ip, now); !ok {
+			return &RateLimitError{message: "too many requests from this address", RetryAfter: retryAfter}
+		}
+	}
+
+	if limits.apiKey != nil && apiKey != "" {
+		if ok, retryAfter := limits.apiKey.allow(apiKey, now); !ok {
+			return &RateLimitError{message: "too many requests for this API key", RetryAfter: retryAfter}
+		}
+	}
+
+	return nil
+}
+
+// SenderCharge is what AllowSender took from the limits of a sender
+type SenderCharge struct {
+	limits *limits
+	key    string
+	gas    uint64
+	at     time.Time
+}
+
+// AllowSender applies the per sender limit and the gas quota to a transaction of sender requesting gas. The charge is
+// given back with RefundSender when the transaction is not relayed.
+func (service *RelaySignerService) AllowSender(sender common.Address, gas uint64) (*SenderCharge, error) {
+	limits := service.currentLimits()
+	if limits == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	key := sender.Hex()
+
+	if limits.sender != nil {
+		if ok, retryAfter := limits.sender.allow(key, now); !ok {
+			return nil, &RateLimitError{message: "too many transactions from this sender", RetryAfter: retryAfter}
+		}
+	}
+
+	if limits.gasQuota != nil {
+		if ok, retryAfter := limits.gasQuota.consume(key, gas, now); !ok {
+			if limits.sender != nil {
+				limits.sender.refund(key, now)
+			}
+			msg := fmt.Sprintf("sender gas quota of %d per %s exceeded", limits.gasQuota.limit, limits.gasQuota.window)
+			return nil, &RateLimitError{message: msg, RetryAfter: retryAfter}
+		}
+	}
+
+	return &SenderCharge{limits: limits, key: key, gas: gas, at: now}, nil
+}
+
+// RefundSender gives back the charge of a transaction that was rejected before being relayed
+func (service *RelaySignerService) RefundSender(charge *SenderCharge) {
+	if charge == nil {
+		return
+	}
+	if charge.limits.sender != nil {
+		charge.limits.sender.refund(charge.key, time.Now())
+	}
+	if charge.limits.gasQuota != nil {
+		charge.limits.gasQuota.refund(charge.key, charge.gas, charge.at)
+	}
+}
+
+// GetLimiterState returns the remaining tokens per key and the gas used per sender in the current window
+func (service *RelaySignerService) GetLimiterState() *model.LimiterState {
+	limits := service.currentLimits()
+	state := &model.LimiterState{Enabled: limits != nil}
+	if limits == nil {
+		return state
+	}
+	now := time.Now()
+
+	if limits.ip != nil {
+		state.IP = limits.ip.state(now)
+	}
+	if limits.apiKey != nil {
+		state.APIKey = limits.apiKey.state(now)
+	}
+	if limits.sender != nil {
+		state.Sender = limits.sender.state(now)
+	}
+	if limits.gasQuota != nil {
+		state.SenderGasUsed = limits.gasQuota.state(now)
+	}
+	return state
+}
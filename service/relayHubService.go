@@ -0,0 +1,147 @@
+package service
+
+import (
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const DEFAULT_RELAYHUB_REFRESH_INTERVAL int64 = 60
+
+// relayHubAddress returns the RelayHub transactions are currently relayed through, nil when it was never resolved
+func (service *RelaySignerService) relayHubAddress() *common.Address {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+	return service.Config.Application.RelayHubContractAddress
+}
+
+// RelayHubAddress returns the RelayHub a request is relayed through. It is read once per request and passed down, so
+// a request keeps using it when the RelayHub changes meanwhile
+func (service *RelaySignerService) RelayHubAddress() common.Address {
+	if address := service.relayHubAddress(); address != nil {
+		return *address
+	}
+	return common.Address{}
+}
+
+// resolveRelayHubAddress asks the proxy in application.contractAddress which RelayHub it forwards to
+func (service *RelaySignerService) resolveRelayHubAddress() (*common.Address, error) {
+	client, err := service.chain()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	address, err := client.GetRelayHubAddress(common.HexToAddress(service.Config.Application.ContractAddress))
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// RefreshRelayHubAddress resolves the RelayHub behind the proxy again and switches to it when it changed. The nonces
+// counted and the gas used in the current block belong to the previous RelayHub, so they start over
+func (service *RelaySignerService) RefreshRelayHubAddress() (bool, error) {
+	address, err := service.resolveRelayHubAddress()
+	if err != nil {
+		return false, err
+	}
+
+	service.reloadLock.Lock()
+	previous := service.Config.Application.RelayHubContractAddress
+	if previous != nil && *previous == *address {
+		service.reloadLock.Unlock()
+		return false, nil
+	}
+	service.Config.Application.RelayHubContractAddress = address
+	service.resetTransactionCounts(*address)
+	decrement()
+	service.reloadLock.Unlock()
+
+	if previous != nil {
+		log.GeneralLogger.Printf("RelayHub behind proxy %s changed from %s to %s", service.Config.Application.ContractAddress, previous.Hex(), address.Hex())
+	}
+	return true, nil
+}
+
+// ProcessRelayHubAddress refreshes the RelayHub address every application.relayHubRefreshInterval seconds,
+// and right away when the proxy emits an event, until done is closed
+func (service *RelaySignerService) ProcessRelayHubAddress(done <-chan interface{}) {
+	interval := service.Config.Application.RelayHubRefreshInterval
+	if interval == 0 {
+		interval = DEFAULT_RELAYHUB_REFRESH_INTERVAL
+	}
+	if interval < 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	proxyEvents := make(chan types.Log)
+	if service.Config.Application.WSURL != "" {
+		go service.watchProxyEvents(done, proxyEvents)
+	}
+
+	for {
+		select {
+		case <-done:
+			log.GeneralLogger.Println("quit signal received...exiting from refreshing the RelayHub address")
+			return
+		case <-ticker.C:
+		case event := <-proxyEvents:
+			log.GeneralLogger.Println("RelayHub proxy event in transaction", event.TxHash.Hex())
+		}
+		if _, err := service.RefreshRelayHubAddress(); err != nil {
+			log.ErrorLogger.Println("can't refresh RelayHub address, keeping the current one:", err)
+		}
+	}
+}
+
+func (service *RelaySignerService) watchProxyEvents(done <-chan interface{}, events chan<- types.Log) {
+	proxyAddress := common.HexToAddress(service.Config.Application.ContractAddress)
+	for {
+		err := service.subscribeProxyEvents(done, proxyAddress, events)
+		if err == nil {
+			return
+		}
+		log.ErrorLogger.Println("RelayHub proxy events subscription failed, the address is refreshed periodically:", err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(PERMISSIONS_RESUBSCRIBE_DELAY):
+		}
+	}
+}
+
+func (service *RelaySignerService) subscribeProxyEvents(done <-chan interface{}, proxyAddress common.Address, events chan<- types.Log) error {
+	client, err := service.websocket()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	logs := make(chan types.Log)
+	subscription, err := client.SubscribeContractLogs(proxyAddress, logs)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	for {
+		select {
+		case err := <-subscription.Err():
+			return subscriptionError(err)
+		case event := <-logs:
+			select {
+			case events <- event:
+			case <-done:
+				return nil
+			}
+		case <-done:
+			return nil
+		}
+	}
+}
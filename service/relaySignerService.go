@@ -8,7 +8,6 @@
 package service
 
 import (
-	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"encoding/json"
@@ -17,6 +16,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/LACNetNetworks/gas-relay-signer/audit"
 	bl "github.com/LACNetNetworks/gas-relay-signer/blockchain"
@@ -42,8 +42,23 @@ var lock sync.Mutex
 // RelaySignerService is the main service
 type RelaySignerService struct {
 	// The service's configuration
-	Config  *model.Config
-	senders map[string]*big.Int
+	Config        *model.Config
+	senders       map[string]*big.Int
+	sendersHub    common.Address
+	sendersLock   sync.Mutex
+	history       *relayHistory
+	permissions   *permissionCache
+	limits        *limits
+	auth          *authenticator
+	readers       bl.Readers
+	accounting    chan privateAccounting
+	nonces        *nonceManager
+	queue         *admissionQueue
+	tiers         *gasTiers
+	relayed       *relayedTransactions
+	dial          ChainDialer
+	privacyGroups privacyGroups
+	reloadLock    sync.RWMutex
 }
 
 // Init configuration parameters
@@ -69,60 +84,118 @@ func (service *RelaySignerService) Init(_config *model.Config) error {
 	service.Config.Application.Key = string(key[2:66])
 
 	service.senders = make(map[string]*big.Int)
+	service.nonces = new(nonceManager)
+	service.history = newRelayHistory(service.Config.Admin.HistorySize)
+	service.limits = newLimits(service.Config.RateLimit)
+	service.accounting = make(chan privateAccounting, DEFAULT_ACCOUNTING_QUEUE_SIZE)
+
+	if service.Config.Auth.Enabled {
+		service.auth, err = newAuthenticator(service.Config.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	service.privacyGroups, err = newPrivacyGroups(service.Config.Privacy)
+	if err != nil {
+		return err
+	}
+
+	service.tiers = newGasTiers(service.Config.Tiers)
+
+	service.queue, err = newAdmissionQueue(service.Config.Queue)
+	if err != nil {
+		return err
+	}
+	service.relayed = newRelayedTransactions(service.Config.Deduplication)
 
 	if service.Config.Security.PermissionsEnabled {
 		if !(common.IsHexAddress(service.Config.Security.AccountContractAddress)) {
 			return errors.InvalidAddress.New("Invalid Account Smart Contract Address", -32608)
 		}
+		if service.Config.Security.PermissionsCacheEnabled {
+			service.permissions = newPermissionCache()
+		}
 	}
 
-	service.Config.Application.RelayHubContractAddress, err = getRelayHubContractAddress(service.Config.Application.NodeURL, "1000", service.Config.Application.ContractAddress, 10)
+	_, err = service.RefreshRelayHubAddress()
 	if err != nil {
-		return errors.FailedKeyConfig.New("Can't get relayHub smart contract address from Proxy", -32610)
+		return errors.FailedKeyConfig.Wrapf(err, "Can't get relayHub smart contract address from Proxy", -32610)
 	}
 
 	return nil
 }
 
-// SendMetatransaction to blockchain
-func (service *RelaySignerService) SendMetatransaction(id json.RawMessage, to *common.Address, gasLimit uint64, signingData []byte, v uint8, r, s [32]byte, sender string, nonce uint64) *rpc.JsonrpcMessage {
+// SetReaders shares the upstream nodes of the proxy, and their health, for the reads of the service
+func (service *RelaySignerService) SetReaders(readers bl.Readers) {
+	service.readers = readers
+}
+
+// connect to the writer node, reading from the healthiest upstream node when they are shared
+func (service *RelaySignerService) connect() (*bl.Client, error) {
 	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.NodeURL)
+	if service.readers != nil {
+		return client, client.ConnectReaders(service.Config.Application.NodeURL, service.readers)
+	}
+	return client, client.Connect(service.Config.Application.NodeURL)
+}
+
+// GetNodeStatus returns the health of the upstream nodes used for reads
+func (service *RelaySignerService) GetNodeStatus() []model.NodeStatus {
+	if service.readers == nil {
+		return []model.NodeStatus{}
+	}
+	return service.readers.Status()
+}
+
+// SendMetatransaction to blockchain
+func (service *RelaySignerService) SendMetatransaction(id json.RawMessage, relayHub common.Address, to *common.Address, gasLimit uint64, signingData []byte, v uint8, r, s [32]byte, sender string, nonce uint64) *rpc.JsonrpcMessage {
+	tx, err := service.relayMetatransaction(relayHub, to, gasLimit, signingData, v, r, s)
 	if err != nil {
-		HandleError(id, err)
+		return HandleError(id, err)
+	}
+
+	service.incrementTransactionCount(relayHub, sender, nonce)
+
+	result := new(rpc.JsonrpcMessage)
+
+	result.ID = id
+	return result.Response(tx)
+}
+
+// relayMetatransaction sends the metatransaction to relayHub with the next nonce of the writer node
+func (service *RelaySignerService) relayMetatransaction(relayHub common.Address, to *common.Address, gasLimit uint64, signingData []byte, v uint8, r, s [32]byte) (*common.Hash, error) {
+	client, err := service.chain()
+	if err != nil {
+		return nil, err
 	}
 	defer client.Close()
 
 	privateKey, err := crypto.HexToECDSA(service.Config.Application.Key)
 	if err != nil {
-		HandleError(id, err)
+		return nil, err
 	}
 
-	optionsSendTransaction, err := client.ConfigTransaction(privateKey, gasLimit, true)
+	writerNonce, err := service.nonces.acquire(client, crypto.PubkeyToAddress(privateKey.PublicKey))
 	if err != nil {
-		return HandleError(id, err)
+		return nil, err
 	}
-	tx, err := client.SendMetatransaction(*service.Config.Application.RelayHubContractAddress, optionsSendTransaction, to, signingData, v, r, s)
+	optionsSendTransaction := bl.NewTransactOpts(privateKey, gasLimit, writerNonce)
+	tx, err := client.SendMetatransaction(relayHub, optionsSendTransaction, to, signingData, v, r, s)
+	service.nonces.release()
 	if err != nil {
-		return HandleError(id, err)
+		return nil, err
 	}
 
 	log.GeneralLogger.Println("transaction", tx)
-
-	service.incrementTransactionCount(sender, nonce)
-
-	result := new(rpc.JsonrpcMessage)
-
-	result.ID = id
-	return result.Response(tx)
+	return tx, nil
 }
 
 // GetTransactionReceipt from blockchain
 func (service *RelaySignerService) GetTransactionReceipt(id json.RawMessage, transactionID string) *rpc.JsonrpcMessage {
-	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.NodeURL)
+	client, err := service.chain()
 	if err != nil {
-		HandleError(id, err)
+		return HandleError(id, err)
 	}
 	defer client.Close()
 
@@ -193,16 +266,16 @@ func (service *RelaySignerService) GetTransactionReceipt(id json.RawMessage, tra
 
 }
 
-// GetTransactionCount of account
-func (service *RelaySignerService) GetTransactionCount(id json.RawMessage, from string, isPending bool) *rpc.JsonrpcMessage {
+// GetTransactionCount of account in relayHub
+func (service *RelaySignerService) GetTransactionCount(id json.RawMessage, relayHub common.Address, from string, isPending bool) *rpc.JsonrpcMessage {
 	var count *big.Int
-	if isPending && (service.senders[from] != nil) {
-		count = service.senders[from]
-	} else {
-		client := new(bl.Client)
-		err := client.Connect(service.Config.Application.NodeURL)
+	if isPending {
+		count = service.pendingTransactionCount(relayHub, from)
+	}
+	if count == nil {
+		client, err := service.chain()
 		if err != nil {
-			HandleError(id, err)
+			return HandleError(id, err)
 		}
 		defer client.Close()
 
@@ -222,7 +295,7 @@ func (service *RelaySignerService) GetTransactionCount(id json.RawMessage, from
 
 		address := common.HexToAddress(from)
 
-		count, err = client.GetTransactionCount(*service.Config.Application.RelayHubContractAddress, address, nodeAddress)
+		count, err = client.GetTransactionCount(relayHub, address, nodeAddress)
 		if err != nil {
 			HandleError(id, err)
 		}
@@ -234,12 +307,12 @@ func (service *RelaySignerService) GetTransactionCount(id json.RawMessage, from
 	return result.Response(fmt.Sprintf("0x%x", count))
 }
 
-// VerifyGasLimit sent a transaction
-func (service *RelaySignerService) VerifyGasLimit(gasLimit uint64, id json.RawMessage) (bool, error) {
-	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.NodeURL)
+// VerifyGasLimit reserves gasLimit in the node allowance of relayHub left in the current block for the tier of tenant,
+// it also returns the node allowance of a whole block
+func (service *RelaySignerService) VerifyGasLimit(relayHub common.Address, gasLimit uint64, tenant string, id json.RawMessage) (bool, uint64, error) {
+	client, err := service.chain()
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 	defer client.Close()
 
@@ -257,25 +330,28 @@ func (service *RelaySignerService) VerifyGasLimit(gasLimit uint64, id json.RawMe
 
 	nodeAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
-	currentGasLimit, err := client.GetNodeGasLimit(*service.Config.Application.RelayHubContractAddress, nodeAddress)
+	currentGasLimit, err := client.GetNodeGasLimit(relayHub, nodeAddress)
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 
 	if currentGasLimit != nil {
 		log.GeneralLogger.Println("current gasLimit assigned:", currentGasLimit.Uint64())
 	}
-	if increment(gasLimit) > currentGasLimit.Uint64() {
-		return false, nil
-	}
-
-	return true, nil
+	nodeGasLimit := currentGasLimit.Uint64()
+	return service.reserveGas(gasLimit, nodeGasLimit, service.tiers.name(tenant)), nodeGasLimit, nil
 }
 
 // VerifySender sent a transaction
 func (service *RelaySignerService) VerifySender(sender common.Address, id json.RawMessage) (bool, error) {
-	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.NodeURL)
+	if service.permissions != nil {
+		if isPermitted, fresh := service.permissions.accountPermitted(sender, service.permissionsStaleness()); fresh {
+			log.GeneralLogger.Println("sender is permitted (cached):", isPermitted)
+			return isPermitted, nil
+		}
+	}
+
+	client, err := service.chain()
 	if err != nil {
 		return false, err
 	}
@@ -293,31 +369,59 @@ func (service *RelaySignerService) VerifySender(sender common.Address, id json.R
 	return isPermitted, nil
 }
 
-// DecreaseGasUsed by node
-func (service *RelaySignerService) DecreaseGasUsed(id json.RawMessage) bool {
-	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.NodeURL)
+// VerifyTransaction checks the destination and the whole transaction against the permissioning rules before relaying
+func (service *RelaySignerService) VerifyTransaction(sender common.Address, to *common.Address, value, gasPrice *big.Int, gasLimit uint64, data []byte, id json.RawMessage) error {
+	security := service.Config.Security
+	if !security.DestinationPermissionsEnabled && !security.TransactionPermissionsEnabled {
+		return nil
+	}
+
+	client, err := service.chain()
 	if err != nil {
-		HandleError(id, err)
+		return err
 	}
 	defer client.Close()
 
-	privateKey, err := crypto.HexToECDSA(service.Config.Application.Key)
-	if err != nil {
-		log.GeneralLogger.Fatal(err)
+	contractAddress := common.HexToAddress(security.AccountContractAddress)
+
+	var target common.Address
+	if to != nil {
+		target = *to
 	}
 
-	options, err := client.ConfigTransaction(privateKey, 30000, false)
-	if err != nil {
-		HandleError(id, err)
+	if security.DestinationPermissionsEnabled && to != nil {
+		isPermitted, fresh := false, false
+		if service.permissions != nil {
+			isPermitted, fresh = service.permissions.destinationPermitted(target, service.permissionsStaleness())
+		}
+		if !fresh {
+			isPermitted, err = client.DestinationPermitted(contractAddress, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		log.GeneralLogger.Println("destination is permitted:", isPermitted)
+
+		if !isPermitted {
+			return errors.NotPermitted.New("destination is not allowed", -32611)
+		}
 	}
 
-	_, err = client.DecreaseGasUsed(*service.Config.Application.RelayHubContractAddress, options, new(big.Int).SetUint64(25000))
-	if err != nil {
-		HandleError(id, err)
+	if security.TransactionPermissionsEnabled {
+		isAllowed, err := client.TransactionAllowed(contractAddress, sender, target, value, gasPrice, gasLimit, data)
+		if err != nil {
+			return err
+		}
+
+		log.GeneralLogger.Println("transaction is allowed:", isAllowed)
+
+		if !isAllowed {
+			return errors.NotPermitted.New("transaction is not allowed by account permissioning rules", -32612)
+		}
 	}
 
-	return true
+	return nil
 }
 
 func transactionRelayedFailed(id json.RawMessage, data []byte) (bool, []byte) {
@@ -385,27 +489,25 @@ func getBadTransaction(id json.RawMessage, data []byte) []byte {
 
 func (service *RelaySignerService) ProcessNewBlocks(done <-chan interface{}) {
 	fmt.Println("Initiating process BLOCKSSS")
-	client := new(bl.Client)
-	err := client.Connect(service.Config.Application.WSURL)
+	client, err := service.websocket()
 	if err != nil {
-		log.GeneralLogger.Fatal(err)
+		log.ErrorLogger.Fatal(err)
 	}
 	defer client.Close()
 
 	headers := make(chan *types.Header)
-	sub, err := client.GetEthclient().SubscribeNewHead(context.Background(), headers)
+	sub, err := client.SubscribeNewHeads(headers)
 	if err != nil {
-		log.GeneralLogger.Fatal(err)
+		log.ErrorLogger.Fatal(err)
 	}
 
 	for {
 		select {
 		case err := <-sub.Err():
-			log.GeneralLogger.Println("WebSocket Failed")
-			log.GeneralLogger.Fatal(err)
+			log.ErrorLogger.Println("WebSocket Failed")
+			log.ErrorLogger.Fatal(err)
 		case header := <-headers:
-			log.GeneralLogger.Println("new block generated:", header.Hash().Hex())
-			decrement()
+			service.NewBlock(header)
 		case <-done:
 			log.GeneralLogger.Println("quit signal received...exiting from processing blocks")
 			return
@@ -413,22 +515,43 @@ func (service *RelaySignerService) ProcessNewBlocks(done <-chan interface{}) {
 	}
 }
 
-func increment(gasLimit uint64) uint64 {
-	lock.Lock()
-	defer lock.Unlock()
-	GAS_LIMIT = GAS_LIMIT + gasLimit
-	log.GeneralLogger.Println("gasLimit used in currently block:", GAS_LIMIT)
-	return GAS_LIMIT
+// NewBlock resets the gas used in the current block and lets the queued transactions try again
+func (service *RelaySignerService) NewBlock(header *types.Header) {
+	log.GeneralLogger.Println("new block generated:", header.Hash().Hex())
+	setLastHeader(header)
+	decrement()
+	if service.queue != nil {
+		service.queue.newBlock(time.Now())
+	}
 }
 
 func decrement() {
 	lock.Lock()
 	defer lock.Unlock()
 	GAS_LIMIT = 0
+	tierGasUsed = make(map[string]uint64)
 	log.GeneralLogger.Println("gas limit was reseted to 0")
 }
 
-func (service *RelaySignerService) incrementTransactionCount(from string, nonce uint64) {
+// pendingTransactionCount is the last nonce relayed through relayHub for from since the service started, nil when there
+// is none or relayHub is no longer the current one
+func (service *RelaySignerService) pendingTransactionCount(relayHub common.Address, from string) *big.Int {
+	service.sendersLock.Lock()
+	defer service.sendersLock.Unlock()
+	if relayHub != service.sendersHub || service.senders[from] == nil {
+		return nil
+	}
+	return new(big.Int).Set(service.senders[from])
+}
+
+// incrementTransactionCount counts a transaction relayed through relayHub, one relayed through a RelayHub that was
+// replaced meanwhile is not counted
+func (service *RelaySignerService) incrementTransactionCount(relayHub common.Address, from string, nonce uint64) {
+	service.sendersLock.Lock()
+	defer service.sendersLock.Unlock()
+	if relayHub != service.sendersHub {
+		return
+	}
 	if service.senders[from] != nil {
 		newNonce := service.senders[from].Uint64() + 1
 		service.senders[from].SetUint64(newNonce)
@@ -437,9 +560,26 @@ func (service *RelaySignerService) incrementTransactionCount(from string, nonce
 	}
 }
 
+// forgetTransactionCount drops the nonce counted for from, the next one is read from relayHub again
+func (service *RelaySignerService) forgetTransactionCount(relayHub common.Address, from string) {
+	service.sendersLock.Lock()
+	defer service.sendersLock.Unlock()
+	if relayHub == service.sendersHub {
+		delete(service.senders, from)
+	}
+}
+
+// resetTransactionCounts drops every nonce counted and counts the next ones for relayHub
+func (service *RelaySignerService) resetTransactionCounts(relayHub common.Address) {
+	service.sendersLock.Lock()
+	defer service.sendersLock.Unlock()
+	service.senders = make(map[string]*big.Int)
+	service.sendersHub = relayHub
+}
+
 // HandleError
 func HandleError(id json.RawMessage, err error) *rpc.JsonrpcMessage {
-	log.GeneralLogger.Println(err.Error())
+	log.ErrorLogger.Println(err.Error())
 	result := new(rpc.JsonrpcMessage)
 	result.ID = id
 	return result.ErrorResponse(err)
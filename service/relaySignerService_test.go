@@ -1,20 +1,34 @@
 package service
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
 	"github.com/LACNetNetworks/gas-relay-signer/model"
 	"github.com/LACNetNetworks/gas-relay-signer/rpc"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 var sequence uint8 = 0
@@ -88,7 +102,7 @@ func TestGetTransactionCount(t *testing.T) {
 	relayHubAddress := common.HexToAddress("0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E")
 	relaySignerService.Config.Application.RelayHubContractAddress = &relayHubAddress
 	relaySignerService.Config.Application.ContractAddress = "0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E"
-	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], false)
+	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, relaySignerService.RelayHubAddress(), params[0], false)
 
 	fmt.Println(jsonResponse)
 
@@ -117,7 +131,7 @@ func TestGetTransactionCountLatest(t *testing.T) {
 	relayHubAddress := common.HexToAddress("0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E")
 	relaySignerService.Config.Application.RelayHubContractAddress = &relayHubAddress
 	relaySignerService.Config.Application.ContractAddress = "0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E"
-	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], false)
+	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, relaySignerService.RelayHubAddress(), params[0], false)
 
 	fmt.Println(jsonResponse)
 
@@ -142,9 +156,9 @@ func TestGetTransactionCountPending(t *testing.T) {
 	config := model.Config{Application: applicationConfig}
 	relaySignerService := new(RelaySignerService)
 	_ = relaySignerService.Init(&config)
-	relaySignerService.senders = make(map[string]*big.Int)
-	relaySignerService.senders["0x92c9885663f6e84127c857d3137936c424b7e07555d2bc7d8bd781b3f0847ac8"] = new(big.Int).SetUint64(200)
-	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], true)
+	relaySignerService.resetTransactionCounts(relaySignerService.RelayHubAddress())
+	relaySignerService.incrementTransactionCount(relaySignerService.RelayHubAddress(), "0x92c9885663f6e84127c857d3137936c424b7e07555d2bc7d8bd781b3f0847ac8", 200)
+	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, relaySignerService.RelayHubAddress(), params[0], true)
 
 	if jsonResponse.String() != `{"jsonrpc":"2.0","id":53,"result":"0xc8"}` {
 		t.Errorf("Incorrect nonce was gotten")
@@ -170,8 +184,8 @@ func TestGetTransactionCountPendingNoValue(t *testing.T) {
 	relayHubAddress := common.HexToAddress("0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E")
 	relaySignerService.Config.Application.RelayHubContractAddress = &relayHubAddress
 	relaySignerService.Config.Application.ContractAddress = "0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E"
-	relaySignerService.senders = make(map[string]*big.Int)
-	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, params[0], true)
+	relaySignerService.resetTransactionCounts(relayHubAddress)
+	jsonResponse := relaySignerService.GetTransactionCount(rpcMessage.ID, relaySignerService.RelayHubAddress(), params[0], true)
 
 	if jsonResponse.String() != `{"jsonrpc":"2.0","id":53,"result":"0x159"}` {
 		t.Errorf("Incorrect nonce was gotten")
@@ -270,7 +284,7 @@ func TestSendMetatransaction(t *testing.T) {
 
 	sender := "0x92c9885663f6e84127c857d3137936c424b7e07555d2bc7d8bd781b3f0847ac8"
 
-	jsonResponse := relaySignerService.SendMetatransaction(rpcMessage.ID, &to, gasLimit, encodedFunction, 27, r, s, sender, 34)
+	jsonResponse := relaySignerService.SendMetatransaction(rpcMessage.ID, relaySignerService.RelayHubAddress(), &to, gasLimit, encodedFunction, 27, r, s, sender, 34)
 
 	err := os.Remove("keyMock")
 	if err != nil {
@@ -321,12 +335,12 @@ func TestNonceAfterTransactions(t *testing.T) {
 	sender := "0x92c9885663f6e84127c857d3137936c424b7e07555d2bc7d8bd781b3f0847ac8"
 
 	for i := 34; i < 45; i++ {
-		jsonResponse := relaySignerService.SendMetatransaction(rpcMessage.ID, &to, gasLimit, encodedFunction, 27, r, s, sender, uint64(i))
+		jsonResponse := relaySignerService.SendMetatransaction(rpcMessage.ID, relaySignerService.RelayHubAddress(), &to, gasLimit, encodedFunction, 27, r, s, sender, uint64(i))
 		if jsonResponse.String() != `{"jsonrpc":"2.0","id":2914410858336929,"result":"0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc"}` {
 			t.Errorf("Incorrect transactionHash was gotten")
 		}
 
-		jsonResponseNonce := relaySignerService.GetTransactionCount(rpcMessage.ID, sender, true)
+		jsonResponseNonce := relaySignerService.GetTransactionCount(rpcMessage.ID, relaySignerService.RelayHubAddress(), sender, true)
 
 		fmt.Println(jsonResponseNonce)
 
@@ -347,6 +361,142 @@ func TestNonceAfterTransactions(t *testing.T) {
 	}
 }
 
+func TestCheckReadiness(t *testing.T) {
+	srv := serverMock()
+	defer srv.Close()
+
+	relayHubAddress := common.HexToAddress("0xfF6D55d01FB12695EA00c071aD8aF3CE44cF3A91")
+	applicationConfig := model.ApplicationConfig{NodeURL: srv.URL + "/health", Key: "b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0", RelayHubContractAddress: &relayHubAddress}
+	config := model.Config{Application: applicationConfig, Health: model.HealthConfig{MaxHeaderAge: 60, MinGasAllowance: 300000}}
+	relaySignerService := &RelaySignerService{Config: &config}
+
+	status := relaySignerService.CheckReadiness()
+	if status.Status != STATUS_NOT_READY {
+		t.Errorf("Relay signer shouldn't be ready before receiving a block header")
+	}
+
+	setLastHeader(&types.Header{Number: big.NewInt(100)})
+	defer setLastHeader(nil)
+
+	status = relaySignerService.CheckReadiness()
+	if status.Status != STATUS_READY {
+		t.Errorf("Relay signer should be ready, checks: %+v", status.Checks)
+	}
+
+	relaySignerService.Config.Health.MinGasAllowance = 20000000
+	status = relaySignerService.CheckReadiness()
+	if status.Status != STATUS_NOT_READY {
+		t.Errorf("Relay signer shouldn't be ready when gas allowance is below threshold")
+	}
+}
+
+func TestRelayHistory(t *testing.T) {
+	config := model.Config{Admin: model.AdminConfig{HistorySize: 3}}
+	relaySignerService := &RelaySignerService{Config: &config, history: newRelayHistory(config.Admin.HistorySize)}
+
+	for i := 0; i < 5; i++ {
+		relaySignerService.RecordRelay(model.RelayRecord{Nonce: uint64(i)})
+	}
+
+	records := relaySignerService.GetRelayHistory()
+	if len(records) != 3 {
+		t.Fatalf("History should keep the last 3 records, got %d", len(records))
+	}
+	if records[0].Nonce != 4 || records[2].Nonce != 2 {
+		t.Errorf("History should be returned from the newest to the oldest record")
+	}
+}
+
+func TestVerifyTransactionDestinationNotAllowed(t *testing.T) {
+	srv := serverMock()
+	defer srv.Close()
+
+	applicationConfig := model.ApplicationConfig{NodeURL: srv.URL + "/notPermitted"}
+	securityConfig := model.SecurityConfig{PermissionsEnabled: true, AccountContractAddress: "0x4683519EF834572017Cb583246B717449A4B752c", DestinationPermissionsEnabled: true}
+	config := model.Config{Application: applicationConfig, Security: securityConfig}
+	relaySignerService := &RelaySignerService{Config: &config}
+
+	sender := common.HexToAddress("0x173cf75f0905338597fcd38f5ce13e6840b230e9")
+	to := common.HexToAddress("0x82a978b3f5962a5b0957d9ee9eef472ee55b42f1")
+
+	err := relaySignerService.VerifyTransaction(sender, &to, big.NewInt(0), big.NewInt(0), 100000, nil, json.RawMessage("1"))
+	if err == nil {
+		t.Fatalf("Destination shouldn't be allowed")
+	}
+
+	response := HandleError(json.RawMessage("1"), err)
+	if response.String() != `{"jsonrpc":"2.0","id":1,"error":{"code":-32611,"message":"destination is not allowed"}}` {
+		t.Errorf("Destination rejection should have its own error code, got %s", response.String())
+	}
+
+	err = relaySignerService.VerifyTransaction(sender, nil, big.NewInt(0), big.NewInt(0), 100000, nil, json.RawMessage("1"))
+	if err != nil {
+		t.Errorf("Contract deployments have no destination to check")
+	}
+}
+
+func TestPermissionCacheStaleness(t *testing.T) {
+	cache := newPermissionCache()
+	account := common.HexToAddress("0x173cf75f0905338597fcd38f5ce13e6840b230e9")
+
+	if _, fresh := cache.accountPermitted(account, time.Minute); fresh {
+		t.Errorf("Cache shouldn't be used before it is seeded")
+	}
+
+	cache.seed([]common.Address{account}, nil)
+	if permitted, fresh := cache.accountPermitted(account, time.Minute); !fresh || !permitted {
+		t.Errorf("Seeded account should be permitted from the cache")
+	}
+
+	cache.setAccount(account, false)
+	if permitted, _ := cache.accountPermitted(account, time.Minute); permitted {
+		t.Errorf("Removed account shouldn't be permitted")
+	}
+
+	cache.invalidate()
+	if _, fresh := cache.accountPermitted(account, time.Minute); fresh {
+		t.Errorf("Cache shouldn't be used after the subscription fails")
+	}
+}
+
+type permissionsReaderMock struct {
+	accounts []common.Address
+	err      error
+}
+
+func (reader *permissionsReaderMock) GetAccounts(common.Address) ([]common.Address, error) {
+	return reader.accounts, reader.err
+}
+
+func (reader *permissionsReaderMock) GetTargets(common.Address) ([]common.Address, error) {
+	return nil, reader.err
+}
+
+func TestSyncPermissions(t *testing.T) {
+	account := common.HexToAddress("0x173cf75f0905338597fcd38f5ce13e6840b230e9")
+	relaySignerService := &RelaySignerService{permissions: newPermissionCache()}
+	reader := &permissionsReaderMock{accounts: []common.Address{account}}
+
+	if err := relaySignerService.syncPermissions(reader, common.Address{}); err != nil {
+		t.Fatal(err)
+	}
+	if permitted, fresh := relaySignerService.permissions.accountPermitted(account, time.Minute); !fresh || !permitted {
+		t.Errorf("Synced account should be permitted from the cache")
+	}
+
+	syncedAt := relaySignerService.permissions.syncedAt
+	reader.err = errors.New("node unavailable", -32100)
+	if err := relaySignerService.syncPermissions(reader, common.Address{}); err == nil {
+		t.Fatalf("Failed resync should be reported")
+	}
+	if relaySignerService.permissions.syncedAt != syncedAt {
+		t.Errorf("Failed resync shouldn't extend the cache freshness")
+	}
+	if _, fresh := relaySignerService.permissions.accountPermitted(account, time.Nanosecond); fresh {
+		t.Errorf("Cache should go stale when it can't be resynced")
+	}
+}
+
 func serverMock() *httptest.Server {
 	handler := http.NewServeMux()
 	handler.HandleFunc("/getTransactionCount", mockGetNonce)
@@ -354,6 +504,8 @@ func serverMock() *httptest.Server {
 	handler.HandleFunc("/getReceiptRevertReason", mockGetReceiptRevertReason)
 	handler.HandleFunc("/sendMetatransaction", mockSendMetatransaction)
 	handler.HandleFunc("/getRelayHubContract", mockGetRelayHubContract)
+	handler.HandleFunc("/health", mockHealth)
+	handler.HandleFunc("/notPermitted", mockNotPermitted)
 
 	srv := httptest.NewServer(handler)
 
@@ -558,6 +710,39 @@ func mockGetRelayHubContract(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`{"jsonrpc" : "2.0","id" : 53,"result" : "0x000000000000000000000000ff6d55d01fb12695ea00c071ad8af3ce44cf3a91"}`))
 }
 
+func mockHealth(w http.ResponseWriter, r *http.Request) {
+	var rpcMessage rpc.JsonrpcMessage
+	_ = json.NewDecoder(r.Body).Decode(&rpcMessage)
+
+	switch rpcMessage.Method {
+	case "eth_getBlockByNumber":
+		_, _ = w.Write([]byte(`{"jsonrpc" : "2.0","id" : 1,"result" : {
+			"number" : "0x64",
+			"hash" : "0x6e3aa24e261e61832624749b64049104c6105ba870d3375484548ffdb133eeea",
+			"parentHash" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"sha3Uncles" : "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+			"miner" : "0x0000000000000000000000000000000000000000",
+			"stateRoot" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"transactionsRoot" : "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+			"receiptsRoot" : "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+			"logsBloom" : "0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+			"difficulty" : "0x1",
+			"gasLimit" : "0x1fffffffffffff",
+			"gasUsed" : "0x0",
+			"timestamp" : "0x5f5e100",
+			"extraData" : "0x",
+			"mixHash" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"nonce" : "0x0000000000000000"
+		}}`))
+	default:
+		_, _ = w.Write([]byte(`{"jsonrpc" : "2.0","id" : 1,"result" : "0x0000000000000000000000000000000000000000000000000000000000989680"}`))
+	}
+}
+
+func mockNotPermitted(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(`{"jsonrpc" : "2.0","id" : 1,"result" : "0x0000000000000000000000000000000000000000000000000000000000000000"}`))
+}
+
 func createKeyMock(path string) {
 	d1 := []byte("0xb3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0")
 	err := ioutil.WriteFile(path, d1, 0644)
@@ -569,3 +754,493 @@ func createKeyMock(path string) {
 func setKeyMock() {
 	os.Setenv("WRITER_KEY", "0xb3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0")
 }
+
+func TestAllowSenderLimits(t *testing.T) {
+	rateLimitConfig := model.RateLimitConfig{Enabled: true, SenderRate: 1, SenderBurst: 2, SenderGasQuota: 500000, GasQuotaWindow: 60}
+	config := model.Config{RateLimit: rateLimitConfig}
+	relaySignerService := &RelaySignerService{Config: &config, limits: newLimits(rateLimitConfig)}
+
+	sender := common.HexToAddress("0x173cf75f0905338597fcd38f5ce13e6840b230e9")
+
+	if _, err := relaySignerService.AllowSender(sender, 200000); err != nil {
+		t.Fatalf("First transaction should be allowed: %s", err)
+	}
+	if _, err := relaySignerService.AllowSender(sender, 400000); err == nil {
+		t.Errorf("Transaction exceeding the gas quota should be rejected")
+	}
+
+	// the transaction rejected by the gas quota keeps no token
+	charge, err := relaySignerService.AllowSender(sender, 1000)
+	if err != nil {
+		t.Fatalf("Second transaction within the burst should be allowed: %s", err)
+	}
+	relaySignerService.RefundSender(charge)
+
+	charge, err = relaySignerService.AllowSender(sender, 1000)
+	if err != nil {
+		t.Fatalf("Refunded transaction shouldn't count against the burst: %s", err)
+	}
+	_, err = relaySignerService.AllowSender(sender, 1000)
+	if err == nil {
+		t.Fatalf("Transaction beyond the burst should be rejected")
+	}
+
+	response := HandleError(json.RawMessage("1"), err)
+	if response.String() != `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"too many transactions from this sender","data":{"retryAfter":1}}}` {
+		t.Errorf("Rate limit error should carry a retry hint, got %s", response.String())
+	}
+
+	state := relaySignerService.GetLimiterState()
+	if state.SenderGasUsed[sender.Hex()] != 201000 {
+		t.Errorf("Gas used by sender should be visible in limiter state without refunded transactions, got %d", state.SenderGasUsed[sender.Hex()])
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	authConfig := model.AuthConfig{Enabled: true, APIKeys: []string{"acme:secret-key"}, JWTIssuer: "https://issuer.example", JWTAudience: "relay", JWTSecret: "jwt-secret"}
+	auth, err := newAuthenticator(authConfig)
+	if err != nil {
+		t.Fatalf("Authenticator should be created: %s", err)
+	}
+	relaySignerService := &RelaySignerService{Config: &model.Config{Auth: authConfig}, auth: auth}
+
+	tenant, err := relaySignerService.Authenticate("secret-key", "")
+	if err != nil || tenant.ID != "acme" || tenant.Method != model.AUTH_METHOD_API_KEY {
+		t.Errorf("API key should resolve to tenant acme, got %v %v", tenant, err)
+	}
+	if _, err := relaySignerService.Authenticate("wrong-key", ""); err == nil {
+		t.Errorf("Unknown API key should be rejected")
+	}
+
+	claims := jwt.MapClaims{"iss": "https://issuer.example", "aud": "relay", "sub": "globex", "exp": time.Now().Add(time.Minute).Unix()}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("jwt-secret"))
+	tenant, err = relaySignerService.Authenticate("", token)
+	if err != nil || tenant.ID != "globex" || tenant.Method != model.AUTH_METHOD_JWT {
+		t.Errorf("JWT should resolve to tenant globex, got %v %v", tenant, err)
+	}
+
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("jwt-secret"))
+	if _, err := relaySignerService.Authenticate("", expired); err == nil {
+		t.Errorf("Expired JWT should be rejected")
+	}
+
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+	claims["aud"] = "other"
+	wrongAudience, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("jwt-secret"))
+	if _, err := relaySignerService.Authenticate("", wrongAudience); err == nil {
+		t.Errorf("JWT for another audience should be rejected")
+	}
+
+	_, err = relaySignerService.Authenticate("", "")
+	response := HandleError(json.RawMessage("1"), err)
+	if response.String() != `{"jsonrpc":"2.0","id":1,"error":{"code":-32001,"message":"missing API key or bearer token"}}` {
+		t.Errorf("Missing credentials should be a JSON-RPC error, got %s", response.String())
+	}
+}
+
+func writeTestCertificate(t *testing.T, dir, name string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	certificate, _ := x509.ParseCertificate(der)
+	return certificate, key
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tls")
+	defer os.RemoveAll(dir)
+
+	ca, caKey := writeTestCertificate(t, dir, "ca", 1, nil, nil)
+	writeTestCertificate(t, dir, "server", 2, ca, caKey)
+	writeTestCertificate(t, dir, "client", 3, ca, caKey)
+
+	tlsConfig := model.TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), MinVersion: "1.2", ClientCAFile: filepath.Join(dir, "ca.crt"), RequireClientCert: true}
+	reloader, err := NewCertificateReloader(tlsConfig)
+	if err != nil {
+		t.Fatalf("Reloader should load the certificate: %s", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCertificate, _ := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+
+	serverSerial := func(certificates ...tls.Certificate) (int64, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+		response, err := client.Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		response.Body.Close()
+		return response.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+
+	if _, err := serverSerial(); err == nil {
+		t.Errorf("Client without certificate should be rejected")
+	}
+	if serial, err := serverSerial(clientCertificate); err != nil || serial != 2 {
+		t.Fatalf("Client with certificate should get the server certificate, got %d %v", serial, err)
+	}
+
+	done := make(chan interface{})
+	defer close(done)
+	go reloader.Watch(done)
+	time.Sleep(100 * time.Millisecond)
+	writeTestCertificate(t, dir, "server", 5, ca, caKey)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		serial, err := serverSerial(clientCertificate)
+		if err == nil && serial == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Certificate should be reloaded after the files change, got %d %v", serial, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestAccountPrivateTransaction(t *testing.T) {
+	failures := 1
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rpcMessage rpc.JsonrpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&rpcMessage)
+
+		switch rpcMessage.Method {
+		case "eth_getTransactionCount":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x6"}`))
+		case "eth_getCode":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x6080"}`))
+		case "eth_estimateGas":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x5408"}`))
+		case "eth_sendRawTransaction":
+			if failures > 0 {
+				failures--
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"node is syncing"}}`))
+				return
+			}
+			sent = append(sent, string(rpcMessage.Params))
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc"}`))
+		}
+	}))
+	defer srv.Close()
+
+	relayHubAddress := common.HexToAddress("0xfF6D55d01FB12695EA00c071aD8aF3CE44cF3A91")
+	applicationConfig := model.ApplicationConfig{NodeURL: srv.URL, Key: "b3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0", RelayHubContractAddress: &relayHubAddress}
+	config := model.Config{Application: applicationConfig, Privacy: model.PrivacyConfig{AccountingRetries: 2, AccountingRetryDelay: 1}}
+	relaySignerService := &RelaySignerService{Config: &config, nonces: new(nonceManager)}
+
+	gas, err := relaySignerService.EstimatePrivateTransactionGas()
+	if err != nil || gas != 21512 {
+		t.Fatalf("Privacy marker gas should be estimated by the node, got %d %v", gas, err)
+	}
+
+	if err := relaySignerService.chargePrivateTransaction(relayHubAddress, gas); err != nil {
+		t.Fatalf("Accounting should be retried after a failed send: %s", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Accounting transaction should be sent once, got %d", len(sent))
+	}
+
+	failures = 3
+	err = relaySignerService.chargePrivateTransaction(relayHubAddress, gas)
+	if err == nil {
+		t.Errorf("Accounting failure should be surfaced after the retries")
+	}
+
+	relaySignerService.history = newRelayHistory(10)
+	relaySignerService.accounting = make(chan privateAccounting, 1)
+	done := make(chan interface{})
+	defer close(done)
+	go relaySignerService.ProcessPrivateAccounting(done)
+
+	failures = 3
+	relaySignerService.AccountPrivateTransaction(relayHubAddress, model.RelayRecord{TransactionHash: "0x9c2fb4956ce18491021a534106fe50e7cfe86bcc373b1626623fa0366f4cc3bc", GasLimit: gas})
+	deadline := time.Now().Add(time.Second)
+	for len(relaySignerService.history.list()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	records := relaySignerService.history.list()
+	if len(records) != 1 || records[0].Error == "" {
+		t.Errorf("Accounting failure in the background should be recorded in the history, got %+v", records)
+	}
+}
+
+func TestRefreshRelayHubAddress(t *testing.T) {
+	result := `"0x000000000000000000000000ff6d55d01fb12695ea00c071ad8af3ce44cf3a91"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rpcMessage rpc.JsonrpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&rpcMessage)
+		if rpcMessage.Method == "eth_getCode" {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	defer srv.Close()
+
+	applicationConfig := model.ApplicationConfig{NodeURL: srv.URL, ContractAddress: "0xdD37c69fF29C4b93A346Ed6dF184f48A71800b7E"}
+	relaySignerService := &RelaySignerService{Config: &model.Config{Application: applicationConfig}}
+
+	changed, err := relaySignerService.RefreshRelayHubAddress()
+	if err != nil || !changed {
+		t.Fatalf("expected the RelayHub address to be resolved, got %v %v", changed, err)
+	}
+
+	changed, err = relaySignerService.RefreshRelayHubAddress()
+	if err != nil || changed {
+		t.Errorf("expected the same RelayHub address, got %v %v", changed, err)
+	}
+
+	sender := "0x173CF75f0905338597fcd38F5cE13E6840b230e9"
+	previous := relaySignerService.RelayHubAddress()
+	relaySignerService.incrementTransactionCount(previous, sender, 7)
+	lock.Lock()
+	GAS_LIMIT = 300000
+	lock.Unlock()
+
+	result = `"0x0000000000000000000000000ae2da68515ef8dc4bbca1fa1bce00c508b2af4b"`
+	changed, err = relaySignerService.RefreshRelayHubAddress()
+	if err != nil || !changed || relaySignerService.relayHubAddress().Hex() != "0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B" {
+		t.Errorf("expected a switch to the new RelayHub, got %v %v %s", changed, err, relaySignerService.relayHubAddress().Hex())
+	}
+	current := relaySignerService.RelayHubAddress()
+	if count := relaySignerService.pendingTransactionCount(current, sender); count != nil {
+		t.Errorf("nonces counted in the previous RelayHub should be dropped, got %s", count)
+	}
+	lock.Lock()
+	gasUsed := GAS_LIMIT
+	lock.Unlock()
+	if gasUsed != 0 {
+		t.Errorf("gas used in the previous RelayHub should be reset, got %d", gasUsed)
+	}
+
+	// a relay that started with the previous RelayHub finishes after the switch
+	relaySignerService.incrementTransactionCount(previous, sender, 8)
+	if count := relaySignerService.pendingTransactionCount(current, sender); count != nil {
+		t.Errorf("a nonce relayed through the previous RelayHub should not be counted, got %s", count)
+	}
+
+	for _, invalid := range []string{`"0x"`, `"0x1234"`, `"0x0000000000000000000000000000000000000000000000000000000000000000"`} {
+		result = invalid
+		if _, err := relaySignerService.RefreshRelayHubAddress(); err == nil {
+			t.Errorf("expected an error for result %s", invalid)
+		}
+		if relaySignerService.relayHubAddress().Hex() != "0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B" {
+			t.Errorf("RelayHub address changed on an invalid result %s", invalid)
+		}
+	}
+}
+
+func TestAdmissionQueueOrder(t *testing.T) {
+	tiers := newGasTiers([]model.TierConfig{{Name: "critical", Priority: 10, Tenants: []string{"registry"}}, {Name: "batch", Priority: 1, Tenants: []string{"*"}}})
+	queue, err := newAdmissionQueue(model.QueueConfig{Enabled: true, Order: QUEUE_ORDER_PRIORITY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relaySignerService := &RelaySignerService{queue: queue, tiers: tiers}
+
+	batch, _ := relaySignerService.EnterQueue(model.QueuedTransaction{Tenant: "batch", Nonce: 0})
+	if err := relaySignerService.AwaitTurn(batch); err != nil {
+		t.Fatal(err)
+	}
+	queue.deferToNextBlock(batch)
+
+	other, _ := relaySignerService.EnterQueue(model.QueuedTransaction{Nonce: 1})
+	registry, _ := relaySignerService.EnterQueue(model.QueuedTransaction{Tenant: "registry", Hash: common.HexToHash("0x01")})
+
+	status := relaySignerService.GetQueueStatus()
+	if len(status.Transactions) != 3 || status.Transactions[0].Tenant != "registry" || status.Transactions[1].Nonce != 0 || status.Transactions[2].Nonce != 1 {
+		t.Fatalf("Higher priority should pass a deferred transaction and keep arrival order otherwise, got %+v", status.Transactions)
+	}
+	if transaction, ok := relaySignerService.GetQueuedTransaction(common.HexToHash("0x01")); !ok || transaction.Priority != 10 || transaction.Tier != "critical" || transaction.Position != 1 {
+		t.Errorf("Queued transaction should report its tier, priority and position, got %+v", transaction)
+	}
+	if queue.turn(batch) || queue.turn(other) || !queue.turn(registry) {
+		t.Errorf("Only the head should have its turn")
+	}
+
+	relaySignerService.LeaveQueue(registry)
+	if queue.turn(batch) || queue.turn(other) {
+		t.Errorf("Transactions of a deferred tier should wait for the next block")
+	}
+	queue.newBlock(time.Now())
+	if !queue.turn(batch) || queue.turn(other) {
+		t.Errorf("Deferred transaction should have its turn in the next block")
+	}
+
+	status = relaySignerService.GetQueueStatus()
+	if status.Depth != 2 || status.Admitted != 1 || status.Transactions[0].Blocks != 1 {
+		t.Errorf("Queue status should count admitted transactions and blocks waited, got %+v", status)
+	}
+}
+
+func TestAdmissionQueuePassesDeferredTiers(t *testing.T) {
+	tiers := newGasTiers([]model.TierConfig{{Name: "critical", ReservedShare: 0.5, Tenants: []string{"registry"}}})
+	queue, _ := newAdmissionQueue(model.QueueConfig{Enabled: true})
+	relaySignerService := &RelaySignerService{queue: queue, tiers: tiers}
+
+	batch, _ := relaySignerService.EnterQueue(model.QueuedTransaction{Tenant: "batch"})
+	registry, _ := relaySignerService.EnterQueue(model.QueuedTransaction{Tenant: "registry"})
+	if !queue.turn(batch) || queue.turn(registry) {
+		t.Fatalf("Transactions should take their turn in arrival order")
+	}
+	queue.deferToNextBlock(batch)
+
+	select {
+	case <-registry.wake:
+	default:
+		t.Errorf("Deferring a transaction should wake the next one")
+	}
+	if !queue.turn(registry) {
+		t.Errorf("Transaction should pass the deferred transactions of other tiers")
+	}
+}
+
+func TestReserveGasTiers(t *testing.T) {
+	decrement()
+	defer decrement()
+
+	tiers := newGasTiers([]model.TierConfig{{Name: "critical", ReservedShare: 0.3, Tenants: []string{"registry"}}, {Name: "batch", Tenants: []string{"*"}}})
+	relaySignerService := &RelaySignerService{tiers: tiers}
+
+	steps := []struct {
+		tenant   string
+		gasLimit uint64
+		want     bool
+	}{
+		{tenant: "batch", gasLimit: 600, want: true},
+		{tenant: "", gasLimit: 200, want: false},
+		{tenant: "batch", gasLimit: 100, want: true},
+		{tenant: "registry", gasLimit: 300, want: true},
+		{tenant: "registry", gasLimit: 1, want: false},
+	}
+	for _, step := range steps {
+		if got := relaySignerService.reserveGas(step.gasLimit, 1000, tiers.name(step.tenant)); got != step.want {
+			t.Errorf("Reserving %d for %q should be %v with 300 of 1000 reserved for registry", step.gasLimit, step.tenant, step.want)
+		}
+	}
+	if getGasUsed() != 1000 {
+		t.Errorf("Rejected transactions shouldn't use gas, got %d used", getGasUsed())
+	}
+
+	status := relaySignerService.tierStatus(1000)
+	if len(status) != 2 || status[0].ReservedGas != 300 || status[0].GasUsedInBlock != 300 || status[1].GasUsedInBlock != 700 {
+		t.Errorf("Tier status should report reservations and gas used, got %+v", status)
+	}
+	if relaySignerService.fitsEmptyBlock(800, 1000, "batch") || !relaySignerService.fitsEmptyBlock(700, 1000, "batch") || !relaySignerService.fitsEmptyBlock(1000, 1000, "critical") {
+		t.Errorf("Transactions should fit in an empty block only outside the reservations of other tiers")
+	}
+}
+
+func TestRelayedTransactions(t *testing.T) {
+	relayed := newRelayedTransactions(model.DeduplicationConfig{Enabled: true, Window: 60})
+	hash := common.HexToHash("0x01")
+	relayHash := common.HexToHash("0x02")
+	now := time.Now()
+
+	ctx := context.Background()
+
+	if claimed, err := relayed.claim(ctx, hash, now); claimed != nil || err != nil {
+		t.Fatalf("First submission should be relayed, got %v %v", claimed, err)
+	}
+
+	// a retry whose client gives up while the first submission is relayed gets a retryable error
+	cancelled, cancel := context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	claimed, err := relayed.claim(cancelled, hash, now)
+	if limitErr, ok := err.(*RateLimitError); claimed != nil || !ok || limitErr.ErrorCode() != RATE_LIMIT_ERROR_CODE || limitErr.RetryAfter != DEDUPLICATION_RETRY_AFTER {
+		t.Fatalf("Cancelled retry should stop waiting with a rate limit error, got %v %v", claimed, err)
+	}
+
+	relayed.complete(hash, nil, now)
+	if claimed, _ := relayed.claim(ctx, hash, now); claimed != nil {
+		t.Fatalf("Submission should be relayed again after a rejection, got %s", claimed.Hex())
+	}
+
+	// a retry arriving while the first submission is relayed waits for its outcome
+	retry := make(chan *common.Hash)
+	go func() {
+		claimed, _ := relayed.claim(ctx, hash, now)
+		retry <- claimed
+	}()
+	relayed.complete(hash, &relayHash, now)
+	if claimed := <-retry; claimed == nil || *claimed != relayHash {
+		t.Fatalf("Retry should get the original relay transaction, got %v", claimed)
+	}
+	if claimed, _ := relayed.claim(ctx, hash, now.Add(time.Minute)); claimed == nil || *claimed != relayHash {
+		t.Fatalf("Submission within the window should get the original relay transaction, got %v", claimed)
+	}
+	if transaction, ok := relayed.find(hash, now); !ok || transaction.RelayHash != relayHash || transaction.Duplicates != 2 {
+		t.Errorf("Relayed transaction should map to its relay transaction, got %+v", transaction)
+	}
+
+	if claimed, _ := relayed.claim(ctx, hash, now.Add(time.Minute+time.Second)); claimed != nil {
+		t.Errorf("Submission after the window should be relayed, got %s", claimed.Hex())
+	}
+	if _, ok := relayed.find(hash, now.Add(time.Minute+time.Second)); ok {
+		t.Errorf("Transaction in progress shouldn't be found")
+	}
+
+	if newRelayedTransactions(model.DeduplicationConfig{}) != nil {
+		t.Errorf("Deduplication should be disabled by default")
+	}
+}
+
+func TestReloadWhileServing(t *testing.T) {
+	tenant := &model.Tenant{ID: "acme"}
+	config := model.Config{Privacy: model.PrivacyConfig{EnforceGroups: true, Groups: []string{"acme:group1"}}}
+	groups, _ := newPrivacyGroups(config.Privacy)
+	relaySignerService := &RelaySignerService{Config: &config, privacyGroups: groups}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			relaySignerService.PrivacyGroupAllowed(tenant, "group2")
+			_ = relaySignerService.CurrentConfig().RateLimit
+		}
+	}()
+
+	next := config
+	next.Privacy.Groups = []string{"acme:group2"}
+	next.RateLimit.IPRate = 5
+	if err := relaySignerService.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if !relaySignerService.PrivacyGroupAllowed(tenant, "group2") || relaySignerService.PrivacyGroupAllowed(tenant, "group1") {
+		t.Errorf("Reloaded privacy groups should replace the previous ones")
+	}
+	if relaySignerService.CurrentConfig().RateLimit.IPRate != 5 {
+		t.Errorf("Reloaded rate limits should be visible through CurrentConfig")
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+)
+
+// Reload applies the fields of config that are safe to change while serving: rate limits, the RPC method policy,
+// API keys and JWT settings, privacy groups and the log level. Rate limit buckets start full again.
+// Authentication can't be switched on or off without a restart. The reloaded sections are written under reloadLock,
+// so they must only be read through CurrentConfig or while holding it.
+func (service *RelaySignerService) Reload(config *model.Config) error {
+	service.reloadLock.RLock()
+	authEnabled := service.Config.Auth.Enabled
+	service.reloadLock.RUnlock()
+
+	var auth *authenticator
+	var err error
+	if authEnabled {
+		auth, err = newAuthenticator(config.Auth)
+		if err != nil {
+			return err
+		}
+	}
+	groups, err := newPrivacyGroups(config.Privacy)
+	if err != nil {
+		return err
+	}
+	err = log.SetLevel(config.Log.Level)
+	if err != nil {
+		return err
+	}
+
+	service.reloadLock.Lock()
+	defer service.reloadLock.Unlock()
+
+	service.limits = newLimits(config.RateLimit)
+	service.auth = auth
+	service.privacyGroups = groups
+	service.Config.RateLimit = config.RateLimit
+	service.Config.RPCPolicy = config.RPCPolicy
+	service.Config.Auth = config.Auth
+	service.Config.Auth.Enabled = authEnabled
+	service.Config.Privacy.EnforceGroups = config.Privacy.EnforceGroups
+	service.Config.Privacy.Groups = config.Privacy.Groups
+	service.Config.Log = config.Log
+	return nil
+}
+
+// CurrentConfig returns a copy of the configuration safe to read while it is being reloaded
+func (service *RelaySignerService) CurrentConfig() model.Config {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+	return *service.Config
+}
+
+func (service *RelaySignerService) currentLimits() *limits {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+	return service.limits
+}
+
+func (service *RelaySignerService) currentAuth() *authenticator {
+	service.reloadLock.RLock()
+	defer service.reloadLock.RUnlock()
+	return service.auth
+}
@@ -0,0 +1,335 @@
+// Package servicetest provides test support for the packages built on the relay signer service.
+package servicetest
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DEFAULT_PRIVACY_MARKER_GAS is the gas EstimatePrivacyMarkerGas returns by default
+const DEFAULT_PRIVACY_MARKER_GAS uint64 = 21512
+
+// FakeChainBackend is an in-memory service.ChainBackend for tests. It mines every metatransaction in its own block,
+// recovering the sender of homestead signed signingData and emitting TransactionRelayed as the RelayHub does
+type FakeChainBackend struct {
+	// RelayHub is the address returned by the proxy
+	RelayHub common.Address
+	// NodeGasLimit is the allowance of the writer node returned by GetNodeGasLimit and GetGasLimit
+	NodeGasLimit uint64
+	// BlockGasLimit is the hub-wide limit returned by GetMaxBlockGasLimit and GetCurrentGasLimit
+	BlockGasLimit uint64
+	// PrivacyMarkerGas is returned by EstimatePrivacyMarkerGas
+	PrivacyMarkerGas uint64
+	// ChainID is returned by GetChainID
+	ChainID *big.Int
+	// Reverts makes relayed calls to the address fail with the given output
+	Reverts map[common.Address][]byte
+	// Err is returned by every call when set
+	Err error
+
+	lock      sync.Mutex
+	permitted map[common.Address]bool
+	targets   map[common.Address]bool
+	pending   map[common.Address]uint64
+	nonces    map[common.Address]uint64
+	receipts  map[common.Hash]*types.Receipt
+	relayed   []common.Hash
+	gasUsed   uint64
+	heads     event.Feed
+	logs      event.Feed
+}
+
+// NewFakeChainBackend creates a fake chain giving nodeGasLimit to the writer node
+func NewFakeChainBackend(nodeGasLimit uint64) *FakeChainBackend {
+	return &FakeChainBackend{
+		RelayHub:         common.HexToAddress("0xff6d55d01fb12695ea00c071ad8af3ce44cf3a91"),
+		NodeGasLimit:     nodeGasLimit,
+		BlockGasLimit:    nodeGasLimit,
+		PrivacyMarkerGas: DEFAULT_PRIVACY_MARKER_GAS,
+		ChainID:          big.NewInt(648529),
+		Reverts:          make(map[common.Address][]byte),
+		permitted:        make(map[common.Address]bool),
+		targets:          make(map[common.Address]bool),
+		pending:          make(map[common.Address]uint64),
+		nonces:           make(map[common.Address]uint64),
+		receipts:         make(map[common.Hash]*types.Receipt),
+	}
+}
+
+// Dial is the service.ChainDialer of the fake, every request and subscription shares the same chain
+func (fake *FakeChainBackend) Dial(url string) (service.ChainBackend, error) {
+	return fake, nil
+}
+
+// Permit adds account to the accounts AccountPermitted and TransactionAllowed accept
+func (fake *FakeChainBackend) Permit(account common.Address) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.permitted[account] = true
+}
+
+// PermitTarget adds target to the destinations DestinationPermitted accepts
+func (fake *FakeChainBackend) PermitTarget(target common.Address) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.targets[target] = true
+}
+
+// EmitHeader sends header to the SubscribeNewHeads subscriptions
+func (fake *FakeChainBackend) EmitHeader(header *types.Header) {
+	fake.heads.Send(header)
+}
+
+// EmitLog sends event to the SubscribeContractLogs subscriptions of its address
+func (fake *FakeChainBackend) EmitLog(event types.Log) {
+	fake.logs.Send(event)
+}
+
+// Relayed returns the hashes of the metatransactions sent, in order
+func (fake *FakeChainBackend) Relayed() []common.Hash {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]common.Hash{}, fake.relayed...)
+}
+
+// GasAccounted returns the gas charged to the writer node with DecreaseGasUsed
+func (fake *FakeChainBackend) GasAccounted() uint64 {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.gasUsed
+}
+
+func (fake *FakeChainBackend) Close() {}
+
+func (fake *FakeChainBackend) GetChainID() (*big.Int, error) {
+	return fake.ChainID, fake.Err
+}
+
+func (fake *FakeChainBackend) GetPendingNonce(address common.Address) (uint64, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.pending[address], fake.Err
+}
+
+func (fake *FakeChainBackend) GetLatestHeader() (*types.Header, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return &types.Header{Number: big.NewInt(int64(len(fake.relayed))), GasLimit: fake.BlockGasLimit}, fake.Err
+}
+
+func (fake *FakeChainBackend) GetRelayHubAddress(proxyAddress common.Address) (common.Address, error) {
+	return fake.RelayHub, fake.Err
+}
+
+func (fake *FakeChainBackend) SendMetatransaction(contractAddress common.Address, options *bind.TransactOpts, to *common.Address, signingData []byte, v uint8, r [32]byte, s [32]byte) (*common.Hash, error) {
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+
+	var signed model.RawTransaction
+	if err := rlp.DecodeBytes(signingData, &signed.Data); err != nil {
+		return nil, errors.FailedTransaction.Wrapf(err, "invalid signingData", -32603)
+	}
+	publicKey, err := crypto.SigToPub(crypto.Keccak256(signingData), append(append(r[:], s[:]...), (v+1)%2))
+	if err != nil {
+		return nil, errors.FailedTransaction.Wrapf(err, "invalid signature", -32603)
+	}
+	from := crypto.PubkeyToAddress(*publicKey)
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	tx, err := options.Signer(types.HomesteadSigner{}, options.From, types.NewTransaction(options.Nonce.Uint64(), contractAddress, new(big.Int), options.GasLimit, options.GasPrice, signingData))
+	if err != nil {
+		return nil, err
+	}
+	fake.pending[options.From] = options.Nonce.Uint64() + 1
+	fake.nonces[from]++
+
+	var target common.Address
+	if signed.Data.Recipient != nil {
+		target = *signed.Data.Recipient
+	}
+	output, reverted := fake.Reverts[target]
+	if output == nil {
+		output = []byte{}
+	}
+	data, err := fakeRelayHubABI.Events["TransactionRelayed"].Inputs.NonIndexed().Pack(!reverted, output)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := tx.Hash()
+	fake.relayed = append(fake.relayed, hash)
+	blockNumber := big.NewInt(int64(len(fake.relayed)))
+	fake.receipts[hash] = &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: options.GasLimit,
+		GasUsed:           options.GasLimit,
+		TxHash:            hash,
+		BlockNumber:       blockNumber,
+		BlockHash:         common.BigToHash(blockNumber),
+		Logs: []*types.Log{{
+			Address:     contractAddress,
+			Topics:      []common.Hash{fakeRelayHubABI.Events["TransactionRelayed"].ID, options.From.Hash(), from.Hash(), target.Hash()},
+			Data:        data,
+			BlockNumber: blockNumber.Uint64(),
+			TxHash:      hash,
+			BlockHash:   common.BigToHash(blockNumber),
+		}},
+	}
+	return &hash, nil
+}
+
+func (fake *FakeChainBackend) GetTransactionReceipt(transactionHash common.Hash) (*types.Receipt, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	receipt, ok := fake.receipts[transactionHash]
+	if !ok {
+		return nil, fake.Err
+	}
+	copied := *receipt
+	return &copied, fake.Err
+}
+
+func (fake *FakeChainBackend) GetTransactionCount(contractAddress common.Address, address common.Address, nodeAddress common.Address) (*big.Int, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return new(big.Int).SetUint64(fake.nonces[address]), fake.Err
+}
+
+func (fake *FakeChainBackend) GetNodeGasLimit(contractAddress, nodeAddress common.Address) (*big.Int, error) {
+	return new(big.Int).SetUint64(fake.NodeGasLimit), fake.Err
+}
+
+func (fake *FakeChainBackend) EstimatePrivacyMarkerGas(from, precompileAddress common.Address, payloadSize int) (uint64, error) {
+	return fake.PrivacyMarkerGas, fake.Err
+}
+
+func (fake *FakeChainBackend) GetGasLimit(contractAddress, nodeAddress common.Address) (*big.Int, error) {
+	return new(big.Int).SetUint64(fake.NodeGasLimit), fake.Err
+}
+
+func (fake *FakeChainBackend) GetMaxBlockGasLimit(contractAddress common.Address) (*big.Int, error) {
+	return new(big.Int).SetUint64(fake.BlockGasLimit), fake.Err
+}
+
+func (fake *FakeChainBackend) GetCurrentGasLimit(contractAddress common.Address) (*big.Int, error) {
+	return new(big.Int).SetUint64(fake.BlockGasLimit), fake.Err
+}
+
+// GetGasUsedLastBlocks returns the gas limit of every metatransaction relayed
+func (fake *FakeChainBackend) GetGasUsedLastBlocks(contractAddress common.Address) (*big.Int, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	used := new(big.Int)
+	for _, receipt := range fake.receipts {
+		used.Add(used, new(big.Int).SetUint64(receipt.GasUsed))
+	}
+	return used, fake.Err
+}
+
+func (fake *FakeChainBackend) GetNodes(contractAddress common.Address) (*big.Int, error) {
+	return big.NewInt(1), fake.Err
+}
+
+func (fake *FakeChainBackend) AccountPermitted(contractAddress, senderAddress common.Address) (bool, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.permitted[senderAddress], fake.Err
+}
+
+func (fake *FakeChainBackend) DestinationPermitted(contractAddress, targetAddress common.Address) (bool, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.targets[targetAddress], fake.Err
+}
+
+func (fake *FakeChainBackend) TransactionAllowed(contractAddress, senderAddress, targetAddress common.Address, value, gasPrice *big.Int, gasLimit uint64, payload []byte) (bool, error) {
+	return fake.AccountPermitted(contractAddress, senderAddress)
+}
+
+func (fake *FakeChainBackend) GetAccounts(contractAddress common.Address) ([]common.Address, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return addresses(fake.permitted), fake.Err
+}
+
+func (fake *FakeChainBackend) GetTargets(contractAddress common.Address) ([]common.Address, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return addresses(fake.targets), fake.Err
+}
+
+func (fake *FakeChainBackend) SubscribeNewHeads(headers chan<- *types.Header) (ethereum.Subscription, error) {
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+	return fake.heads.Subscribe(headers), nil
+}
+
+func (fake *FakeChainBackend) SubscribeContractLogs(contractAddress common.Address, logs chan<- types.Log) (ethereum.Subscription, error) {
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+
+	all := make(chan types.Log)
+	subscription := fake.logs.Subscribe(all)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer subscription.Unsubscribe()
+		for {
+			select {
+			case emitted := <-all:
+				if emitted.Address != contractAddress {
+					continue
+				}
+				select {
+				case logs <- emitted:
+				case <-quit:
+					return nil
+				}
+			case err := <-subscription.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+func (fake *FakeChainBackend) DecreaseGasUsed(contractAddress common.Address, options *bind.TransactOpts, gasUsed *big.Int) (*common.Hash, error) {
+	if fake.Err != nil {
+		return nil, fake.Err
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.pending[options.From] = options.Nonce.Uint64() + 1
+	fake.gasUsed += gasUsed.Uint64()
+	hash := crypto.Keccak256Hash(options.From.Bytes(), options.Nonce.Bytes())
+	return &hash, nil
+}
+
+func addresses(set map[common.Address]bool) []common.Address {
+	list := make([]common.Address, 0, len(set))
+	for address, ok := range set {
+		if ok {
+			list = append(list, address)
+		}
+	}
+	return list
+}
+
+var fakeRelayHubABI, _ = abi.JSON(strings.NewReader(service.RelayABI))
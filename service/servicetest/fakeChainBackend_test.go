@@ -0,0 +1,89 @@
+package servicetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	relay "github.com/LACNetNetworks/gas-relay-signer/blockchain/contracts"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var accountContract = common.HexToAddress("0x0000000000000000000000000000000000009999")
+
+func newService(t *testing.T, chain *FakeChainBackend) *service.RelaySignerService {
+	relaySignerService, _ := NewService(t, chain, func(config *model.Config) {
+		config.Security = model.SecurityConfig{PermissionsEnabled: true, PermissionsCacheEnabled: true, AccountContractAddress: accountContract.Hex()}
+	})
+	return relaySignerService
+}
+
+// eventually retries emit until check holds
+func eventually(emit func(), check func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		emit()
+		if check() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestPermissionEvents(t *testing.T) {
+	chain := NewFakeChainBackend(1 << 30)
+	relaySignerService := newService(t, chain)
+	sender := common.HexToAddress("0x173CF75f0905338597fcd38F5cE13E6840b230e9")
+	chain.Permit(sender)
+
+	done := make(chan interface{})
+	defer close(done)
+	go relaySignerService.ProcessPermissionEvents(done)
+
+	accountABI, _ := abi.JSON(strings.NewReader(relay.AccountABI))
+	data, err := accountABI.Events["AccountRemoved"].Inputs.NonIndexed().Pack(true, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := types.Log{Address: accountContract, Topics: []common.Hash{accountABI.Events["AccountRemoved"].ID}, Data: data}
+
+	// the contract still permits the sender, only the event can remove it from the cache
+	ok := eventually(func() { chain.EmitLog(removed) }, func() bool {
+		permitted, err := relaySignerService.VerifySender(sender, nil)
+		return err == nil && !permitted
+	})
+	if !ok {
+		t.Errorf("AccountRemoved event should remove the sender from the permission cache")
+	}
+}
+
+func TestNewBlocks(t *testing.T) {
+	chain := NewFakeChainBackend(1 << 30)
+	relaySignerService := newService(t, chain)
+
+	done := make(chan interface{})
+	defer close(done)
+	go relaySignerService.ProcessNewBlocks(done)
+
+	fits, nodeGasLimit, err := relaySignerService.VerifyGasLimit(relaySignerService.RelayHubAddress(), 300000, "", nil)
+	if err != nil || !fits || nodeGasLimit != 1<<30 {
+		t.Fatalf("gas limit should fit the node allowance, got %v %d %v", fits, nodeGasLimit, err)
+	}
+	status, err := relaySignerService.GetRelayHubStatus()
+	if err != nil || status.GasUsedInBlock != 300000 || status.NodeGasLimit != 1<<30 || status.RegisteredNodes != 1 {
+		t.Fatalf("unexpected RelayHub status %+v %v", status, err)
+	}
+
+	ok := eventually(func() { chain.EmitHeader(&types.Header{Number: common.Big1}) }, func() bool {
+		status, err := relaySignerService.GetRelayHubStatus()
+		return err == nil && status.GasUsedInBlock == 0 && status.LastBlockNumber == 1
+	})
+	if !ok {
+		t.Errorf("a new block header should reset the gas used in the block")
+	}
+}
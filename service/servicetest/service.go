@@ -0,0 +1,26 @@
+package servicetest
+
+import (
+	"testing"
+
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/LACNetNetworks/gas-relay-signer/service"
+)
+
+// WRITER_KEY is the writer node key of the services created by NewService
+const WRITER_KEY = "0xb3e7374dca5ca90c3899dbb2c978051437fb15534c945bf59df16d6c80be27c0"
+
+// NewService initializes a relay signer service on chain for the test, configure adjusts the configuration when not nil
+func NewService(t *testing.T, chain *FakeChainBackend, configure func(config *model.Config)) (*service.RelaySignerService, *model.Config) {
+	t.Setenv(service.ENVIRONMENT_KEY_NAME, WRITER_KEY)
+
+	config := &model.Config{Application: model.ApplicationConfig{NodeURL: "http://127.0.0.1:8545", ContractAddress: "0x0ae2Da68515Ef8DC4bBCa1fA1bcE00C508b2Af4B"}}
+	if configure != nil {
+		configure(config)
+	}
+	relaySignerService := service.NewRelaySignerService(chain.Dial)
+	if err := relaySignerService.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	return relaySignerService, config
+}
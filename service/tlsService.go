@@ -0,0 +1,164 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/LACNetNetworks/gas-relay-signer/audit"
+	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
+	"github.com/fsnotify/fsnotify"
+)
+
+const CERTIFICATE_RELOAD_DELAY = 500 * time.Millisecond
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CertificateReloader serves the relay listener certificate and client CAs, reloading them when the files change
+type CertificateReloader struct {
+	config      model.TLSConfig
+	minVersion  uint16
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// NewCertificateReloader loads the configured certificate, key and client CA
+func NewCertificateReloader(config model.TLSConfig) (*CertificateReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.FailedKeyConfig.New("TLS certificate and key files must be configured", -32602)
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if config.MinVersion != "" {
+		version, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, errors.FailedKeyConfig.New("unsupported TLS minimum version "+config.MinVersion, -32602)
+		}
+		minVersion = version
+	}
+
+	reloader := &CertificateReloader{config: config, minVersion: minVersion}
+	err := reloader.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload reads the certificate, key and client CA files, keeping the previous ones if any of them is invalid
+func (reloader *CertificateReloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(reloader.config.CertFile, reloader.config.KeyFile)
+	if err != nil {
+		return errors.FailedReadFile.Wrapf(err, "can't load TLS certificate %s", -32602, reloader.config.CertFile)
+	}
+
+	var clientCAs *x509.CertPool
+	if reloader.config.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(reloader.config.ClientCAFile)
+		if err != nil {
+			return errors.FailedReadFile.Wrapf(err, "can't read client CA %s", -32602, reloader.config.ClientCAFile)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.FailedReadFile.New("no certificates found in client CA "+reloader.config.ClientCAFile, -32602)
+		}
+	}
+
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+	reloader.certificate = &certificate
+	reloader.clientCAs = clientCAs
+	return nil
+}
+
+// TLSConfig returns a server configuration that always uses the last loaded certificate and client CA
+func (reloader *CertificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     reloader.minVersion,
+		GetCertificate: reloader.getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			reloader.lock.RLock()
+			defer reloader.lock.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:     reloader.minVersion,
+				GetCertificate: reloader.getCertificate,
+			}
+			if reloader.clientCAs != nil {
+				config.ClientCAs = reloader.clientCAs
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				if reloader.config.RequireClientCert {
+					config.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return config, nil
+		},
+	}
+}
+
+func (reloader *CertificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+	return reloader.certificate, nil
+}
+
+// Watch reloads the certificate when the certificate, key or client CA files change until done is closed
+func (reloader *CertificateReloader) Watch(done <-chan interface{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.ErrorLogger.Println("can't watch TLS certificate files, hot-reload disabled:", err)
+		return
+	}
+	defer watcher.Close()
+
+	// watch the directories so certificates replaced by rename or symlink swap are also detected
+	files := make(map[string]bool)
+	for _, file := range []string{reloader.config.CertFile, reloader.config.KeyFile, reloader.config.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		files[filepath.Clean(file)] = true
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			log.ErrorLogger.Println("can't watch", filepath.Dir(file), ":", err)
+		}
+	}
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-done:
+			log.GeneralLogger.Println("quit signal received...exiting from watching TLS certificates")
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if files[filepath.Clean(event.Name)] || filepath.Base(event.Name) == "..data" {
+				// certificate and key are usually written one after the other, wait for both
+				reload = time.After(CERTIFICATE_RELOAD_DELAY)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.ErrorLogger.Println("TLS certificate watcher error:", err)
+		case <-reload:
+			reload = nil
+			if err := reloader.Reload(); err != nil {
+				log.ErrorLogger.Println("TLS certificate reload failed, keeping the previous one:", err)
+				continue
+			}
+			log.GeneralLogger.Println("TLS certificate reloaded")
+		}
+	}
+}
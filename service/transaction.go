@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/LACNetNetworks/gas-relay-signer/errors"
+	"github.com/LACNetNetworks/gas-relay-signer/model"
 	"github.com/LACNetNetworks/gas-relay-signer/sha3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -131,6 +132,22 @@ func Sign(hash []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
 	return signature, nil
 }
 
+// MetaTxGasLimit is the gas limit of the meta transaction relaying tx, its own gas plus the RelayHub overhead
+func MetaTxGasLimit(tx *types.Transaction) uint64 {
+	return uint64((len(tx.Data())*105)+300000) + tx.Gas()
+}
+
+// SigningData encodes the fields of tx signed by its sender, the signingData of relayMetaTx and deployMetaTx
+func SigningData(tx *types.Transaction) ([]byte, error) {
+	var signingDataTx *model.RawTransaction
+	if tx.To() != nil {
+		signingDataTx = model.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data())
+	} else {
+		signingDataTx = model.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data())
+	}
+	return rlp.EncodeToBytes(signingDataTx.Data)
+}
+
 // GetTransaction ...
 func GetTransaction(rawTx string) (*types.Transaction, error) {
 	rawTxBytes, err := hex.DecodeString(rawTx)